@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ArchiveBackend writes placed files into a single tar or zip archive instead of a directory tree.
+// Entries are written one at a time, so workers take turns streaming their files in.
+type ArchiveBackend struct {
+	mu   sync.Mutex
+	file *os.File
+	tar  *tar.Writer
+	zip  *zip.Writer
+	dirs map[string]bool
+}
+
+// NewArchiveBackend creates the archive at path. The format is chosen by the extension: .tar or .zip.
+func NewArchiveBackend(path string) (*ArchiveBackend, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".tar" && ext != ".zip" {
+		return nil, fmt.Errorf("unsupported archive %s: expected a .tar or .zip file", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ArchiveBackend{file: f, dirs: make(map[string]bool)}
+	if ext == ".tar" {
+		a.tar = tar.NewWriter(f)
+	} else {
+		a.zip = zip.NewWriter(f)
+	}
+	return a, nil
+}
+
+// EnsureDir adds directory entries for dir and its parents, once each.
+func (a *ArchiveBackend) EnsureDir(dir string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ensureDir(filepath.ToSlash(dir))
+}
+
+func (a *ArchiveBackend) ensureDir(dir string) error {
+	if dir == "." || dir == "/" || dir == "" || a.dirs[dir] {
+		return nil
+	}
+	if err := a.ensureDir(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	name := dir + "/"
+	var err error
+	if a.tar != nil {
+		err = a.tar.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755})
+	} else {
+		_, err = a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add directory %s to archive: %w", name, err)
+	}
+	a.dirs[dir] = true
+	return nil
+}
+
+// Upload streams the file at src into the archive as name.
+func (a *ArchiveBackend) Upload(src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	name = filepath.ToSlash(name)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var w io.Writer
+	if a.tar != nil {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err := a.tar.WriteHeader(header); err != nil {
+			return err
+		}
+		w = a.tar
+	} else {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		// Already compressed media gains nothing from deflate.
+		header.Method = zip.Store
+		if isCompressible(src) {
+			header.Method = zip.Deflate
+		}
+		if w, err = a.zip.CreateHeader(header); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", src, err)
+	}
+	return nil
+}
+
+// Close finishes the archive and closes the file.
+func (a *ArchiveBackend) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var err error
+	if a.tar != nil {
+		err = a.tar.Close()
+	} else {
+		err = a.zip.Close()
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArchiveBackend(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a/IMG_0001.jpg", "b/IMG_0001.jpg", "notes.txt")
+	exif := &fakeExif{dates: map[string]time.Time{
+		"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		"notes.txt":    time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+	}}
+	expected := map[string]string{
+		"2021/":                  "",
+		"2021/07/":               "",
+		"2021/07/IMG_0001.jpg":   "a/IMG_0001.jpg",
+		"2021/07/IMG_0001-1.jpg": "b/IMG_0001.jpg",
+		"2022/":                  "",
+		"2022/01/":               "",
+		"2022/01/notes.txt":      "notes.txt",
+	}
+
+	for _, name := range []string{"out.tar", "out.zip"} {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), name)
+			archive, err := NewArchiveBackend(archivePath)
+			if err != nil {
+				t.Fatalf("NewArchiveBackend failed: %v", err)
+			}
+			app := &App{
+				Config:      &Config{InputPath: inputDir, Workers: 2, Buffer: 1, Deterministic: true, Archive: archivePath},
+				ExifService: exif,
+				Archive:     archive,
+			}
+			app.targets.inMemory = true
+			app.Run()
+			if err := archive.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			if got := readArchive(t, archivePath); !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected archive entries %v, but got %v", expected, got)
+			}
+			if tree := readTree(t, inputDir); len(tree) != 3 {
+				t.Errorf("Expected input files to be left in place, but got %v", tree)
+			}
+		})
+	}
+
+	if _, err := NewArchiveBackend(filepath.Join(t.TempDir(), "out.rar")); err == nil {
+		t.Errorf("Expected an error for an unsupported archive type, but got nil")
+	}
+}
+
+// readArchive returns the entries of a tar or zip archive and their content.
+func readArchive(t *testing.T, path string) map[string]string {
+	t.Helper()
+	entries := make(map[string]string)
+	if filepath.Ext(path) == ".zip" {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("Failed to open zip: %v", err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", f.Name, err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			entries[f.Name] = string(data)
+		}
+		return entries
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open tar: %v", err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[header.Name] = string(data)
+	}
+	return entries
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// auditMissingDates runs the configured date sources on every path and writes the files that
+// have no usable date to report, one path per line in sorted order. No file is moved or copied.
+// It returns how many files were listed.
+func (app *App) auditMissingDates(paths []string, report string) (int, error) {
+	var mu sync.Mutex
+	var missing []string
+
+	auditing := progressbar.NewOptions(len(paths),
+		progressbar.OptionSetDescription("Auditing"),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowCount(),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
+	)
+	app.runPool(paths, auditing, func(path string) error {
+		if _, err := app.extractDate(path); err != nil {
+			mu.Lock()
+			missing = append(missing, path)
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	sort.Strings(missing)
+	var b strings.Builder
+	for _, path := range missing {
+		b.WriteString(path)
+		b.WriteByte('\n')
+	}
+	return len(missing), os.WriteFile(report, []byte(b.String()), 0644)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOnlyMissingDate(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "dated.jpg", "undated.jpg", "sub/undated.mov", "IMG_20210704_103000.jpg")
+	before := readTree(t, inputDir)
+	report := filepath.Join(t.TempDir(), "missing.txt")
+
+	app := &App{
+		Config: &Config{
+			InputPath:       inputDir,
+			Workers:         2,
+			Buffer:          1,
+			DateSources:     []string{sourceExif, sourceFilename},
+			OnlyMissingDate: report,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{"dated.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	expected := filepath.Join(inputDir, "sub", "undated.mov") + "\n" + filepath.Join(inputDir, "undated.jpg") + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected only the dateless files:\n%s\nbut got:\n%s", expected, data)
+	}
+	if after := readTree(t, inputDir); len(after) != len(before) {
+		t.Errorf("Expected the input to be left alone, but got %v", after)
+	}
+}
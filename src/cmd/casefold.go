@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// matchExistingCase returns dir with every missing component replaced by an existing
+// directory that differs from it only by case, so 2021/jul reuses an existing 2021/Jul.
+func matchExistingCase(dir string) string {
+	dir = filepath.Clean(dir)
+	if _, err := os.Lstat(dir); err == nil {
+		return dir
+	}
+	parent, name := filepath.Dir(dir), filepath.Base(dir)
+	if parent == dir {
+		return dir
+	}
+
+	parent = matchExistingCase(parent)
+	if entries, err := os.ReadDir(parent); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && strings.EqualFold(entry.Name(), name) {
+				return filepath.Join(parent, entry.Name())
+			}
+		}
+	}
+	return filepath.Join(parent, name)
+}
+
+// canonicalDir returns the directory to use for targetDir under -merge-duplicate-dirs:
+// an existing directory differing only by case, or the first spelling seen in this run.
+// The output tree is only read for a spelling not seen before.
+func (app *App) canonicalDir(targetDir string) string {
+	key := strings.ToLower(filepath.Clean(targetDir))
+	if dir, ok := app.dirCase.Load(key); ok {
+		return dir.(string)
+	}
+	dir, _ := app.dirCase.LoadOrStore(key, matchExistingCase(targetDir))
+	return dir.(string)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchExistingCase(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "2021", "Jul"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		dir      string
+		expected string
+	}{
+		{filepath.Join(root, "2021", "jul"), filepath.Join(root, "2021", "Jul")},
+		{filepath.Join(root, "2021", "JUL", "Event-001"), filepath.Join(root, "2021", "Jul", "Event-001")},
+		{filepath.Join(root, "2021", "Aug"), filepath.Join(root, "2021", "Aug")},
+		{filepath.Join(root, "2021", "Jul"), filepath.Join(root, "2021", "Jul")},
+	}
+	for _, tc := range testCases {
+		if got := matchExistingCase(tc.dir); got != tc.expected {
+			t.Errorf("Expected %s for %s, but got %s", tc.expected, tc.dir, got)
+		}
+	}
+}
+
+func TestMergeDuplicateDirsOnCaseInsensitiveFS(t *testing.T) {
+	originalMkdirAll := mkdirAll
+	defer func() { mkdirAll = originalMkdirAll }()
+
+	// Simulate a case-insensitive file system that refuses a directory whose name only
+	// differs by case from an existing one.
+	mkdirAll = func(path string, perm os.FileMode) error {
+		for dir := path; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			entries, _ := os.ReadDir(filepath.Dir(dir))
+			for _, entry := range entries {
+				if entry.Name() != filepath.Base(dir) && strings.EqualFold(entry.Name(), filepath.Base(dir)) {
+					return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+				}
+			}
+		}
+		return originalMkdirAll(path, perm)
+	}
+
+	for _, merge := range []bool{false, true} {
+		inputDir := t.TempDir()
+		outputDir := t.TempDir()
+		writeFiles(t, inputDir, "IMG_0001.jpg")
+		if err := os.MkdirAll(filepath.Join(outputDir, "Adobe-Photoshop", "2021"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		app := &App{Config: &Config{OutputPath: outputDir, CopyMode: true, MergeDuplicateDirs: merge}}
+		err := app.placeFile(filepath.Join(inputDir, "IMG_0001.jpg"), []string{"adobe-photoshop", "2021", "07"})
+		if !merge {
+			if err == nil {
+				t.Errorf("Expected the case collision to fail without -merge-duplicate-dirs")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected the existing directory to be reused, but got %v", err)
+		}
+		if tree := readTree(t, outputDir); tree["Adobe-Photoshop/2021/07/IMG_0001.jpg"] != "IMG_0001.jpg" {
+			t.Errorf("Expected the file under the existing Adobe-Photoshop folder, but got %v", tree)
+		}
+	}
+}
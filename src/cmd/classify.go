@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// noExtensionLabel stands for files without an extension in the -classify histogram.
+const noExtensionLabel = "(none)"
+
+// extensionCount is one row of the -classify histogram.
+type extensionCount struct {
+	Ext       string
+	MediaType string
+	Files     int
+	Bytes     int64
+}
+
+// classifyFiles counts paths and their bytes by lowercased extension and media type,
+// most common first.
+func (app *App) classifyFiles(paths []string) []extensionCount {
+	type key struct{ ext, mediaType string }
+	counts := make(map[key]*extensionCount)
+	for _, path := range paths {
+		k := key{ext: strings.ToLower(filepath.Ext(path)), mediaType: app.inputMediaType(path)}
+		if k.ext == "" {
+			k.ext = noExtensionLabel
+		}
+		c, ok := counts[k]
+		if !ok {
+			c = &extensionCount{Ext: k.ext, MediaType: k.mediaType}
+			counts[k] = c
+		}
+		c.Files++
+		if size, err := app.inputSize(path); err == nil {
+			c.Bytes += size
+		} else {
+			logrus.Warnf("Cannot stat %s: %v", path, err)
+		}
+	}
+
+	list := make([]extensionCount, 0, len(counts))
+	for _, c := range counts {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Files != list[j].Files {
+			return list[i].Files > list[j].Files
+		}
+		if list[i].Ext != list[j].Ext {
+			return list[i].Ext < list[j].Ext
+		}
+		return list[i].MediaType < list[j].MediaType
+	})
+	return list
+}
+
+// printClassification writes the histogram, one extension and media type per line, and the totals.
+func printClassification(w io.Writer, list []extensionCount) {
+	var files int
+	var bytes int64
+	for _, c := range list {
+		fmt.Fprintf(w, "%-10s %-9s %7d files %14d bytes\n", c.Ext, c.MediaType, c.Files, c.Bytes)
+		files += c.Files
+		bytes += c.Bytes
+	}
+	fmt.Fprintf(w, "Total: %d files, %d bytes\n", files, bytes)
+}
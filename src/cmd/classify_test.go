@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestClassifyFiles(t *testing.T) {
+	in := t.TempDir()
+	writeFiles(t, in, "a.jpg", "2021/b.JPG", "c.jpg", "clip.mov", "notes.txt", "README")
+	app := &App{Config: &Config{InputPath: in}}
+
+	paths, _ := app.collectFiles()
+	list := app.classifyFiles(paths)
+
+	expected := []extensionCount{
+		{Ext: ".jpg", MediaType: "image", Files: 3, Bytes: int64(len("a.jpg") + len("2021/b.JPG") + len("c.jpg"))},
+		{Ext: noExtensionLabel, MediaType: "other", Files: 1, Bytes: int64(len("README"))},
+		{Ext: ".mov", MediaType: "video", Files: 1, Bytes: int64(len("clip.mov"))},
+		{Ext: ".txt", MediaType: "other", Files: 1, Bytes: int64(len("notes.txt"))},
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %d rows, but got %v", len(expected), list)
+	}
+	for i, want := range expected {
+		if list[i] != want {
+			t.Errorf("Expected row %d to be %+v, but got %+v", i, want, list[i])
+		}
+	}
+
+	var out bytes.Buffer
+	printClassification(&out, list)
+	if !strings.Contains(out.String(), "Total: 6 files") {
+		t.Errorf("Expected a total of 6 files, but got %q", out.String())
+	}
+}
+
+func TestClassifyLeavesInputAlone(t *testing.T) {
+	in := t.TempDir()
+	writeFiles(t, in, "a.jpg", "b.mov")
+	app := &App{Config: &Config{InputPath: in, Classify: true, Workers: 2, Buffer: 10}, ExifService: &fakeExif{}}
+
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	tree := readTree(t, in)
+	if len(tree) != 2 || tree["a.jpg"] != "a.jpg" || tree["b.mov"] != "b.mov" {
+		t.Errorf("Expected the input to be untouched, but got %v", tree)
+	}
+}
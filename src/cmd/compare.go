@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// Kinds of difference reported by -dry-run-compare.
+const (
+	compareUnchanged = "unchanged"
+	compareMoved     = "moved"
+	compareNew       = "new"
+)
+
+// compareEntry is one input file compared with an existing organized tree.
+// From is the folder the file has in the tree and To the folder the current settings would
+// give it, both relative to the output root; From is empty for new files.
+type compareEntry struct {
+	Kind string
+	Name string
+	From string
+	To   string
+}
+
+// treeFile identifies a file in an organized tree by name and size.
+type treeFile struct {
+	name string
+	size int64
+}
+
+// indexTree maps every file under root to the folders, relative to root, it is found in.
+func indexTree(root string) (map[treeFile][]string, error) {
+	index := make(map[treeFile][]string)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == outputMarker {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		key := treeFile{name: d.Name(), size: info.Size()}
+		index[key] = append(index[key], filepath.ToSlash(rel))
+		return nil
+	})
+	return index, err
+}
+
+// compareWithTree plans paths with the current settings and compares the result with the
+// organized tree at root: a file of the same name and size already in its planned folder is
+// unchanged, one found in another folder would move, and one not found at all is new.
+// Entries are sorted by kind and name; it also returns the number of files that could not be planned.
+func (app *App) compareWithTree(paths []string, root string) ([]compareEntry, int, error) {
+	index, err := indexTree(root)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resolved, failed := app.resolveAll(paths, "Comparing")
+	entries := make([]compareEntry, 0, len(resolved))
+	for path, r := range resolved {
+		size, err := app.inputSize(path)
+		if err != nil {
+			failed++
+			continue
+		}
+		entry := compareEntry{Name: app.targetName(path), To: filepath.ToSlash(filepath.Join(r.Dirs...))}
+		if entry.To == "" {
+			entry.To = "."
+		}
+		found := index[treeFile{name: entry.Name, size: size}]
+		switch {
+		case len(found) == 0:
+			entry.Kind = compareNew
+		case slices.Contains(found, entry.To):
+			entry.Kind = compareUnchanged
+			entry.From = entry.To
+		default:
+			entry.Kind = compareMoved
+			entry.From = found[0]
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].To < entries[j].To
+	})
+	return entries, failed, nil
+}
+
+// printComparison lists the files that would move and the new files, then the totals.
+// Unchanged files are only counted.
+func printComparison(w io.Writer, root string, entries []compareEntry, failed int) {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Kind]++
+		switch e.Kind {
+		case compareMoved:
+			fmt.Fprintf(w, "moved  %s: %s -> %s\n", e.Name, e.From, e.To)
+		case compareNew:
+			fmt.Fprintf(w, "new    %s: %s\n", e.Name, e.To)
+		}
+	}
+	fmt.Fprintf(w, "Compared with %s: %d unchanged, %d moved, %d new\n", root, counts[compareUnchanged], counts[compareMoved], counts[compareNew])
+	if failed > 0 {
+		fmt.Fprintf(w, "%d files could not be planned\n", failed)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRunCompare(t *testing.T) {
+	inputDir := t.TempDir()
+	treeDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg")
+	// The existing tree holds a and b from an earlier run, filed by their old dates.
+	for rel, name := range map[string]string{"2021/07/a.jpg": "a.jpg", "2021/07/b.jpg": "b.jpg"} {
+		path := filepath.Join(treeDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	before := readTree(t, treeDir)
+
+	app := &App{
+		Config: &Config{InputPath: inputDir, Workers: 2, Buffer: 10, DryRunCompare: treeDir},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"a.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+			"b.jpg": time.Date(2021, 8, 1, 10, 0, 0, 0, time.UTC),
+			"c.jpg": time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	paths, _ := app.collectFiles()
+	entries, failed, err := app.compareWithTree(paths, treeDir)
+	if err != nil {
+		t.Fatalf("compareWithTree failed: %v", err)
+	}
+
+	expected := []compareEntry{
+		{Kind: compareMoved, Name: "b.jpg", From: "2021/07", To: "2021/08"},
+		{Kind: compareNew, Name: "c.jpg", To: "2022/01"},
+		{Kind: compareUnchanged, Name: "a.jpg", From: "2021/07", To: "2021/07"},
+	}
+	if !reflect.DeepEqual(entries, expected) || failed != 0 {
+		t.Errorf("Expected %+v, but got %+v (%d failed)", expected, entries, failed)
+	}
+
+	var out bytes.Buffer
+	printComparison(&out, treeDir, entries, failed)
+	for _, want := range []string{"moved  b.jpg: 2021/07 -> 2021/08", "new    c.jpg: 2022/01", "1 unchanged, 1 moved, 1 new"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the output to contain %q, but got:\n%s", want, out.String())
+		}
+	}
+
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if after := readTree(t, treeDir); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected the tree to be left alone, but got %v", after)
+	}
+	if input := readTree(t, inputDir); len(input) != 3 {
+		t.Errorf("Expected the input to be left alone, but got %v", input)
+	}
+}
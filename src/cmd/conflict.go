@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"media_organizer/src/internal"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Policies accepted by -on-conflict for a target that is already taken.
+const (
+	onConflictSuffix        = "suffix"
+	onConflictConflictsDir  = "conflicts-dir"
+	onConflictKeepNewerExif = "keep-newer-exif"
+)
+
+// conflictsDirName is the folder, inside the target directory, that -on-conflict conflicts-dir
+// moves colliding files into.
+const conflictsDirName = "_conflicts"
+
+// targetReserver hands out unique local target paths so concurrent workers never overwrite
+// an existing file or each other's output. The zero value is ready to use.
+type targetReserver struct {
+	mu       sync.Mutex
+	reserved map[string]bool
+	// inMemory skips the disk check, for targets that are not files on disk (archive entries).
+	inMemory bool
+	// conflictsDir sends a taken target to the _conflicts folder next to it, keeping its name.
+	conflictsDir bool
+}
+
+// Reserve returns target if it is free, otherwise the first free "name-N.ext" variant,
+// and marks the returned path as taken for the rest of the run. With conflictsDir a taken
+// target moves to _conflicts/name.ext first, and only gets a suffix if that is taken too.
+func (r *targetReserver) Reserve(target string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reserved == nil {
+		r.reserved = make(map[string]bool)
+	}
+
+	candidate := target
+	if r.conflictsDir && r.taken(candidate) {
+		target = filepath.Join(filepath.Dir(target), conflictsDirName, filepath.Base(target))
+		candidate = target
+	}
+	for n := 1; r.taken(candidate); n++ {
+		candidate = suffixedPath(target, n)
+	}
+	r.reserved[candidate] = true
+	return candidate
+}
+
+// ClaimExisting marks a target that exists on disk, and that this run has not reserved yet,
+// as taken by the caller. It returns false for any other target.
+func (r *targetReserver) ClaimExisting(target string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reserved == nil {
+		r.reserved = make(map[string]bool)
+	}
+	if r.reserved[target] {
+		return false
+	}
+	if _, err := os.Lstat(target); err != nil {
+		return false
+	}
+	r.reserved[target] = true
+	return true
+}
+
+// taken reports whether a path is already reserved or exists on disk.
+func (r *targetReserver) taken(path string) bool {
+	if r.reserved[path] {
+		return true
+	}
+	if r.inMemory {
+		return false
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// suffixedPath inserts "-n" before the extension: /a/IMG.jpg → /a/IMG-1.jpg.
+func suffixedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// reserveTarget reserves a unique local target path and records it in the collision report
+// when the file had to be renamed.
+func (app *App) reserveTarget(target string) string {
+	final := app.targets.Reserve(target)
+	if final != target && app.Collisions != nil {
+		if err := app.Collisions.Record(target, final); err != nil {
+			logrus.Errorf("Failed to record collision for %s: %v", target, err)
+		}
+	}
+	return final
+}
+
+// errKeptExisting is returned by claimTarget for a file left in place because the existing
+// target has a newer EXIF ModifyDate. Such files are counted as skipped, not processed.
+var errKeptExisting = errors.New("existing target is newer")
+
+// claimTarget is reserveTarget for the file at path, applying -on-conflict keep-newer-exif:
+// when target already exists on disk, the file with the newer EXIF ModifyDate wins. An older
+// incoming file is skipped with errKeptExisting and a newer one replaces the target, whose
+// hash is forgotten. Files without both dates, or with equal ones, get a suffix as usual.
+func (app *App) claimTarget(path, target string) (string, error) {
+	if app.Config.OnConflict == onConflictKeepNewerExif && app.targets.ClaimExisting(target) {
+		incoming, okIncoming := app.exifModifyDate(path)
+		existing, okExisting := app.exifModifyDate(target)
+		switch {
+		case okIncoming && okExisting && incoming.After(existing):
+			logrus.Infof("Replacing %s with %s: its EXIF ModifyDate %s is newer than %s", target, path, incoming, existing)
+			app.destHashes.Remove(target)
+			return target, nil
+		case okIncoming && okExisting && incoming.Before(existing):
+			logrus.Infof("Skipping %s: %s has a newer EXIF ModifyDate (%s, not %s)", path, target, existing, incoming)
+			return "", errKeptExisting
+		}
+	}
+	return app.reserveTarget(target), nil
+}
+
+// exifModifyDate returns the EXIF ModifyDate of a file, if it has one.
+func (app *App) exifModifyDate(path string) (time.Time, bool) {
+	fields, err := app.ExifService.ExtractFields(path)
+	if err != nil {
+		logrus.Debugf("Cannot read ModifyDate of %s: %v", path, err)
+		return time.Time{}, false
+	}
+	t, err := internal.ParseExifDate(fieldString(fields, "ModifyDate"))
+	return t, err == nil
+}
+
+// CollisionReport writes a CSV row for every file renamed because its target was taken.
+type CollisionReport struct {
+	out *SafeWriter
+}
+
+// NewCollisionReport creates the report at path and writes the header row.
+func NewCollisionReport(path string, checkpointEvery int) (*CollisionReport, error) {
+	out, err := CreateSafeWriter(path, 0644, checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteRecord([]string{"original_basename", "final_basename", "target_dir"}); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return &CollisionReport{out: out}, nil
+}
+
+// Record adds the rename of target to final.
+func (r *CollisionReport) Record(target, final string) error {
+	return r.out.WriteRecord([]string{filepath.Base(target), filepath.Base(final), filepath.Dir(final)})
+}
+
+// Close closes the report file.
+func (r *CollisionReport) Close() error {
+	return r.out.Close()
+}
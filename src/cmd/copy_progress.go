@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// newCopyProgress returns the writer that tracks the bytes copied to a file of the given size.
+// It is a variable so tests can count the bytes.
+var newCopyProgress = func(name string, size int64) io.WriteCloser {
+	return progressbar.NewOptions64(size,
+		progressbar.OptionSetDescription(filepath.Base(name)),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
+	)
+}
+
+// withCopyProgress adds a per-file progress bar to out when the file is at least -copy-with-progress
+// bytes; 0 means never. The returned function closes the bar.
+func (app *App) withCopyProgress(out io.Writer, name string, size int64) (io.Writer, func()) {
+	if threshold := app.Config.CopyWithProgress; threshold <= 0 || size < threshold {
+		return out, func() {}
+	}
+	bar := newCopyProgress(name, size)
+	return io.MultiWriter(out, bar), func() { bar.Close() }
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countingProgress counts the bytes written to a per-file progress bar.
+type countingProgress struct {
+	name   string
+	size   int64
+	n      int64
+	closed bool
+}
+
+func (c *countingProgress) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func (c *countingProgress) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCopyWithProgress(t *testing.T) {
+	defer func(orig func(string, int64) io.WriteCloser) { newCopyProgress = orig }(newCopyProgress)
+	var bars []*countingProgress
+	newCopyProgress = func(name string, size int64) io.WriteCloser {
+		bar := &countingProgress{name: name, size: size}
+		bars = append(bars, bar)
+		return bar
+	}
+
+	app := &App{Config: &Config{CopyWithProgress: 100 << 10}}
+	dir := t.TempDir()
+	large := bytes.Repeat([]byte("v"), 300<<10)
+	small := bytes.Repeat([]byte("s"), 10<<10)
+	if err := os.WriteFile(filepath.Join(dir, "large.mp4"), large, 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "small.jpg"), small, 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	for _, name := range []string{"large.mp4", "small.jpg"} {
+		if err := app.copyFile(filepath.Join(dir, name), filepath.Join(dir, name+".copy")); err != nil {
+			t.Fatalf("copyFile failed: %v", err)
+		}
+	}
+
+	if len(bars) != 1 {
+		t.Fatalf("Expected a progress bar for the large file only, but got %d", len(bars))
+	}
+	bar := bars[0]
+	if filepath.Base(bar.name) != "large.mp4" || bar.size != int64(len(large)) {
+		t.Errorf("Expected a bar for large.mp4 of %d bytes, but got %s of %d", len(large), bar.name, bar.size)
+	}
+	if bar.n != int64(len(large)) {
+		t.Errorf("Expected the bar to receive %d bytes, but got %d", len(large), bar.n)
+	}
+	if !bar.closed {
+		t.Errorf("Expected the bar to be closed after the copy")
+	}
+	copied, err := os.ReadFile(filepath.Join(dir, "large.mp4.copy"))
+	if err != nil || !bytes.Equal(copied, large) {
+		t.Errorf("Expected the copy to match its source, but got %d bytes (%v)", len(copied), err)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copySparse copies src to dst, skipping over holes so that sparse files stay sparse, for -copy
+// and for moves that cannot rename across file systems. It uses SEEK_DATA/SEEK_HOLE to find the
+// data regions and falls back to copyFile when the source filesystem does not support them.
+func (app *App) copySparse(src, dst string) error {
+	defer app.acquireFiles(2)()
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	// Probe for SEEK_DATA support before creating the destination.
+	if _, err := unix.Seek(int(in.Fd()), 0, unix.SEEK_DATA); err != nil && !errors.Is(err, unix.ENXIO) {
+		return app.copyContents(src, dst)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var offset int64
+	for offset < size {
+		dataStart, err := unix.Seek(int(in.Fd()), offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// No more data after offset: the rest of the file is a hole.
+			break
+		}
+		if err != nil {
+			return err
+		}
+		holeStart, err := unix.Seek(int(in.Fd()), dataStart, unix.SEEK_HOLE)
+		if err != nil {
+			return err
+		}
+
+		if _, err := in.Seek(dataStart, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := out.Seek(dataStart, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, app.throttled(in), holeStart-dataStart); err != nil {
+			return err
+		}
+		offset = holeStart
+	}
+
+	// Extend the destination to the full size so a trailing hole is preserved.
+	if err := out.Truncate(size); err != nil {
+		return err
+	}
+
+	return out.Sync()
+}
@@ -0,0 +1,99 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopySparse(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "disk.img")
+	dst := filepath.Join(tempDir, "copy.img")
+
+	const size = 16 << 20
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatalf("Failed to truncate source file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("head"), 0); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("tail"), size/2); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	f.Close()
+
+	if err := (&App{Config: &Config{}}).copySparse(src, dst); err != nil {
+		t.Fatalf("copySparse failed: %v", err)
+	}
+
+	srcData, _ := os.ReadFile(src)
+	dstData, _ := os.ReadFile(dst)
+	if !bytes.Equal(srcData, dstData) {
+		t.Fatalf("Expected destination content to match source")
+	}
+
+	srcInfo, _ := os.Stat(src)
+	dstInfo, _ := os.Stat(dst)
+	srcBlocks := srcInfo.Sys().(*syscall.Stat_t).Blocks
+	dstBlocks := dstInfo.Sys().(*syscall.Stat_t).Blocks
+	if srcBlocks*512 >= size {
+		t.Skipf("Skipping test: filesystem does not support sparse files")
+	}
+	if dstBlocks*512 >= size {
+		t.Errorf("Expected sparse destination, but got %d allocated blocks for %d bytes", dstBlocks, size)
+	}
+}
+
+func TestMoveSparseAcrossFileSystems(t *testing.T) {
+	originalRename := renameFile
+	defer func() { renameFile = originalRename }()
+	renameFile = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "disk.img")
+	dst := filepath.Join(tempDir, "moved.img")
+
+	const size = 16 << 20
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatalf("Failed to truncate source file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("tail"), size/2); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	f.Close()
+	srcInfo, _ := os.Stat(src)
+	if srcInfo.Sys().(*syscall.Stat_t).Blocks*512 >= size {
+		t.Skipf("Skipping test: filesystem does not support sparse files")
+	}
+
+	app := &App{Config: &Config{Sparse: true}}
+	if err := app.moveFile(src, dst); err != nil {
+		t.Fatalf("moveFile failed: %v", err)
+	}
+
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("Expected the source to be removed, but got %v", err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil || dstInfo.Size() != size {
+		t.Fatalf("Expected a %d byte destination, but got %v", size, err)
+	}
+	if blocks := dstInfo.Sys().(*syscall.Stat_t).Blocks; blocks*512 >= size {
+		t.Errorf("Expected the moved file to stay sparse, but got %d allocated blocks for %d bytes", blocks, size)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// copySparse, used by -sparse copies and cross-file-system moves, falls back to a regular copy
+// on platforms without SEEK_DATA/SEEK_HOLE support.
+func (app *App) copySparse(src, dst string) error {
+	return app.copyFile(src, dst)
+}
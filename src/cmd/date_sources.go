@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"media_organizer/src/internal"
+)
+
+// DateExtractor is one source of a file's capture date.
+// Extract returns the date and a label naming where it came from, or the zero time when the
+// source has no date for the file. An error means the source could not be read at all.
+type DateExtractor interface {
+	Extract(path string) (time.Time, string, error)
+}
+
+// Date source names accepted by -date-sources.
+const (
+	sourceExif     = "exif"
+	sourceFilename = "filename"
+	sourceSidecar  = "sidecar"
+	sourceMtime    = "mtime"
+	sourceHEIF     = "heif"
+	sourceDir      = "dir"
+)
+
+// defaultDateSources is used when -date-sources is not given.
+var defaultDateSources = []string{sourceExif}
+
+// parseDateSources splits and validates a comma-separated -date-sources value.
+func parseDateSources(value string) ([]string, error) {
+	var sources []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case sourceExif, sourceFilename, sourceSidecar, sourceMtime, sourceHEIF, sourceDir:
+			sources = append(sources, name)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown date source %q: expected %s, %s, %s, %s, %s or %s", name, sourceExif, sourceFilename, sourceSidecar, sourceMtime, sourceHEIF, sourceDir)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no date sources given")
+	}
+	return sources, nil
+}
+
+// dateChain builds the extractors for the configured date sources, in order.
+func (app *App) dateChain() []DateExtractor {
+	sources := app.Config.DateSources
+	if len(sources) == 0 {
+		sources = defaultDateSources
+	}
+
+	chain := make([]DateExtractor, 0, len(sources)+1)
+	hasDir := false
+	for _, name := range sources {
+		switch name {
+		case sourceExif:
+			if app.Config.VideoUseMtime {
+				chain = append(chain, videoMtimeExtractor{classify: app.classifyMedia})
+			}
+			chain = append(chain,
+				exifExtractor{exif: app.ExifService, debug: app.Config.Debug, useFileModifyDate: app.Config.UseFileModifyDate, badDates: &app.badDates},
+				thmExtractor{exif: app.ExifService, debug: app.Config.Debug})
+		case sourceFilename:
+			chain = append(chain, filenameExtractor{})
+		case sourceSidecar:
+			chain = append(chain, sidecarExtractor{exif: app.ExifService, debug: app.Config.Debug})
+		case sourceMtime:
+			chain = append(chain, mtimeExtractor{})
+		case sourceHEIF:
+			chain = append(chain, heifExtractor{})
+		case sourceDir:
+			chain = append(chain, dirExtractor{layouts: app.Config.DirDateLayouts})
+			hasDir = true
+		}
+	}
+	if app.Config.DateFromDir && !hasDir {
+		chain = append(chain, dirExtractor{layouts: app.Config.DirDateLayouts})
+	}
+	return chain
+}
+
+// isInferredDate reports whether a date source label names a date that did not come from the
+// file's own date tags: its name, folder or modification time, or a sidecar file.
+func isInferredDate(source string) bool {
+	switch source {
+	case "Filename", "Dir", "FileModTime", "FileModifyDate":
+		return true
+	}
+	return strings.HasPrefix(source, "Sidecar:") || strings.HasPrefix(source, "THM:")
+}
+
+// extractFromChain returns the first date found by the extractors, in order. When none has a
+// date, it returns the last error an extractor reported, so a failed read is not mistaken for
+// a file without a date.
+func extractFromChain(chain []DateExtractor, path string) (time.Time, string, error) {
+	var lastErr error
+	for _, extractor := range chain {
+		t, source, err := extractor.Extract(path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !t.IsZero() {
+			return t, source, nil
+		}
+	}
+	return time.Time{}, "", lastErr
+}
+
+// exifExtractor reads the date tags of the file itself through exiftool.
+// Files whose date tags cannot be parsed are recorded in badDates, for -quarantine-unparseable-dates.
+type exifExtractor struct {
+	exif              ExifReader
+	debug             bool
+	useFileModifyDate bool
+	badDates          *sync.Map
+}
+
+func (e exifExtractor) Extract(path string) (time.Time, string, error) {
+	t, tag, err := e.exif.ExtractDate(path, e.debug, e.useFileModifyDate)
+	var bad *internal.UnparseableDateError
+	if errors.As(err, &bad) {
+		logrus.Warnf("Cannot parse the %s date %q of %s", bad.Tag, bad.Value, path)
+		if e.badDates != nil {
+			e.badDates.Store(path, bad)
+		}
+		return time.Time{}, "", err
+	}
+	if err != nil {
+		logrus.Errorf("Failed to extract date for %s: %v", path, err)
+		return time.Time{}, "", fmt.Errorf("exiftool: %w", err)
+	}
+	return t, tag, nil
+}
+
+// videoMtimeExtractor uses the modification time of videos, whose embedded dates -video-use-mtime
+// does not trust, ahead of their EXIF. Other files are left to the next source in the chain.
+type videoMtimeExtractor struct {
+	classify func(path string) string
+}
+
+func (e videoMtimeExtractor) Extract(path string) (time.Time, string, error) {
+	if e.classify(path) != internal.MediaVideo {
+		return time.Time{}, "", nil
+	}
+	return mtimeExtractor{}.Extract(path)
+}
+
+// heifExtractor reads the EXIF date of HEIC, HEIF and AVIF files natively, without starting exiftool.
+// Other files, and files it cannot parse, are left to the next source in the chain.
+type heifExtractor struct{}
+
+// heifExtensions are the file types heifExtractor reads.
+var heifExtensions = map[string]bool{".heic": true, ".heif": true, ".avif": true}
+
+func (heifExtractor) Extract(path string) (time.Time, string, error) {
+	if !heifExtensions[strings.ToLower(filepath.Ext(path))] {
+		return time.Time{}, "", nil
+	}
+	t, tag, err := internal.ReadHEIFDate(path)
+	if err != nil {
+		logrus.Debugf("Native HEIF reader failed for %s: %v", path, err)
+		return time.Time{}, "", fmt.Errorf("native HEIF reader: %w", err)
+	}
+	return t, "HEIF:" + tag, nil
+}
+
+// filenameExtractor parses a date embedded in the file name, such as IMG_20210704_103000.jpg.
+type filenameExtractor struct{}
+
+func (filenameExtractor) Extract(path string) (time.Time, string, error) {
+	t, _ := internal.ParseFilenameDate(filepath.Base(path))
+	return t, "Filename", nil
+}
+
+// sidecarExtractor reads the date from an XMP sidecar next to the file
+// (IMG_0001.jpg.xmp or IMG_0001.xmp).
+type sidecarExtractor struct {
+	exif  ExifReader
+	debug bool
+}
+
+func (e sidecarExtractor) Extract(path string) (time.Time, string, error) {
+	return extractFromSidecars(e.exif, e.debug, sidecarPaths(path), "Sidecar:")
+}
+
+// extractFromSidecars returns the first date exiftool finds in the existing files among paths.
+// The tag it came from is returned with prefix. Without a date, it returns the last error.
+func extractFromSidecars(exif ExifReader, debug bool, paths []string, prefix string) (time.Time, string, error) {
+	var lastErr error
+	for _, sidecar := range paths {
+		if _, err := os.Stat(sidecar); err != nil {
+			continue
+		}
+		t, tag, err := exif.ExtractDate(sidecar, debug, false)
+		if err != nil {
+			logrus.Warnf("Failed to extract date from sidecar %s: %v", sidecar, err)
+			lastErr = fmt.Errorf("sidecar %s: %w", sidecar, err)
+			continue
+		}
+		if !t.IsZero() {
+			return t, prefix + tag, nil
+		}
+	}
+	return time.Time{}, "", lastErr
+}
+
+// sidecarPaths lists the candidate sidecar files for path, in lookup order.
+func sidecarPaths(path string) []string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return []string{path + ".xmp", base + ".xmp", base + ".XMP"}
+}
+
+// dirExtractor parses a date from the name of the file's parent folder, such as "2021-07-04 Trip".
+type dirExtractor struct {
+	layouts []string
+}
+
+func (e dirExtractor) Extract(path string) (time.Time, string, error) {
+	t, _ := parseDateFromDir(filepath.Dir(path), e.layouts...)
+	return t, "Dir", nil
+}
+
+// defaultDirDateLayouts are the folder name date layouts tried when -dir-date-layouts is not given.
+var defaultDirDateLayouts = []string{"2006-01-02", "2006_01_02", "2006.01.02", "20060102", "2006-01"}
+
+// parseDateFromDir finds a date in the last element of dir using the given time layouts,
+// or defaultDirDateLayouts if none are given. The date may appear anywhere in the name
+// but must not be part of a longer run of digits.
+func parseDateFromDir(dir string, layouts ...string) (time.Time, bool) {
+	if len(layouts) == 0 {
+		layouts = defaultDirDateLayouts
+	}
+	name := filepath.Base(dir)
+	isDigit := func(i int) bool { return i >= 0 && i < len(name) && name[i] >= '0' && name[i] <= '9' }
+
+	for start := 0; start < len(name); start++ {
+		if !isDigit(start) || isDigit(start-1) {
+			continue
+		}
+		for _, layout := range layouts {
+			end := start + len(layout)
+			if end > len(name) || isDigit(end) {
+				continue
+			}
+			if t, err := time.Parse(layout, name[start:end]); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// thmExtractor reads the date of a video from the THM thumbnail old camcorders write
+// next to it (MVI_0001.AVI and MVI_0001.THM), when the video itself has none.
+type thmExtractor struct {
+	exif  ExifReader
+	debug bool
+}
+
+func (e thmExtractor) Extract(path string) (time.Time, string, error) {
+	if internal.ClassifyMedia(path) != internal.MediaVideo {
+		return time.Time{}, "", nil
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return extractFromSidecars(e.exif, e.debug, []string{base + ".THM", base + ".thm"}, "THM:")
+}
+
+// mtimeExtractor uses the file system modification time.
+type mtimeExtractor struct{}
+
+func (mtimeExtractor) Extract(path string) (time.Time, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		logrus.Warnf("Cannot stat %s: %v", path, err)
+		return time.Time{}, "", err
+	}
+	return info.ModTime(), "FileModTime", nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"media_organizer/src/internal"
+)
+
+func TestDateChain(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "IMG_20200101_080000.jpg", "DSC0001.jpg", "DSC0002.jpg", "DSC0002.xmp", "scan.tif", "IMG_0003.heic", "MVI_0001.MTS", "MVI_0001.THM", "scan.THM")
+
+	mtime := time.Date(2019, 5, 6, 7, 8, 9, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(dir, "scan.tif"), mtime, mtime); err != nil {
+		t.Fatalf("Failed to set file time: %v", err)
+	}
+
+	exifDate := time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)
+	sidecarDate := time.Date(2018, 3, 2, 1, 0, 0, 0, time.UTC)
+	thmDate := time.Date(2009, 8, 15, 14, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{
+		"IMG_20200101_080000.jpg": exifDate,
+		"DSC0001.jpg":             exifDate,
+		"DSC0002.xmp":             sidecarDate,
+		"IMG_0003.heic":           exifDate,
+		"MVI_0001.THM":            thmDate,
+		"scan.THM":                thmDate,
+	}}
+
+	testCases := []struct {
+		name     string
+		sources  []string
+		file     string
+		expected time.Time
+		source   string
+		ok       bool
+	}{
+		{"EXIF first", []string{sourceExif, sourceFilename}, "IMG_20200101_080000.jpg", exifDate, "DateTimeOriginal", true},
+		{"Filename first", []string{sourceFilename, sourceExif}, "IMG_20200101_080000.jpg", time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), "Filename", true},
+		{"Falls through to EXIF", []string{sourceFilename, sourceExif}, "DSC0001.jpg", exifDate, "DateTimeOriginal", true},
+		{"Sidecar", []string{sourceExif, sourceSidecar}, "DSC0002.jpg", sidecarDate, "Sidecar:DateTimeOriginal", true},
+		{"Mtime fallback", []string{sourceExif, sourceFilename, sourceMtime}, "scan.tif", mtime, "FileModTime", true},
+		{"HEIF skips other types", []string{sourceHEIF, sourceExif}, "DSC0001.jpg", exifDate, "DateTimeOriginal", true},
+		{"HEIF falls back to EXIF", []string{sourceHEIF, sourceExif}, "IMG_0003.heic", exifDate, "DateTimeOriginal", true},
+		{"Video date from THM", []string{sourceExif}, "MVI_0001.MTS", thmDate, "THM:DateTimeOriginal", true},
+		{"THM ignored for non-videos", []string{sourceExif}, "scan.tif", time.Time{}, "", false},
+		{"No source matches", []string{sourceExif, sourceFilename, sourceSidecar}, "scan.tif", time.Time{}, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &App{Config: &Config{DateSources: tc.sources}, ExifService: exif}
+			got, source, err := extractFromChain(app.dateChain(), filepath.Join(dir, tc.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ok := !got.IsZero(); ok != tc.ok {
+				t.Fatalf("Expected ok %v, but got %v", tc.ok, ok)
+			}
+			if !got.Equal(tc.expected) || source != tc.source {
+				t.Errorf("Expected %v from %q, but got %v from %q", tc.expected, tc.source, got, source)
+			}
+		})
+	}
+}
+
+func TestParseDateSources(t *testing.T) {
+	sources, err := parseDateSources("exif, filename,sidecar,mtime,heif")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"exif", "filename", "sidecar", "mtime", "heif"}; !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected %v, but got %v", expected, sources)
+	}
+
+	for _, value := range []string{"", "exif,gps"} {
+		if _, err := parseDateSources(value); err == nil {
+			t.Errorf("Expected an error for %q, but got nil", value)
+		}
+	}
+}
+
+func TestParseDateFromDir(t *testing.T) {
+	testCases := []struct {
+		dir      string
+		expected time.Time
+		ok       bool
+	}{
+		{"/archive/2021-07-04 Trip", time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC), true},
+		{"/archive/Trip 2021_07_04", time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC), true},
+		{"/archive/20210704", time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC), true},
+		{"/archive/2021-07 Vacation", time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		{"/2021-07-04/Misc", time.Time{}, false},
+		{"/archive/Vacation", time.Time{}, false},
+		{"/archive/Scans 120210704", time.Time{}, false},
+		{"/archive/2021-13-40 Bad", time.Time{}, false},
+	}
+
+	for _, tc := range testCases {
+		got, ok := parseDateFromDir(tc.dir)
+		if ok != tc.ok || !got.Equal(tc.expected) {
+			t.Errorf("Expected %v, %v for %s, but got %v, %v", tc.expected, tc.ok, tc.dir, got, ok)
+		}
+	}
+
+	if got, ok := parseDateFromDir("/archive/04.07.2021 Party", "02.01.2006"); !ok || !got.Equal(time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a custom layout to match, but got %v, %v", got, ok)
+	}
+}
+
+func TestDateFromDirFallback(t *testing.T) {
+	exifDate := time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)
+	app := &App{
+		Config:      &Config{DateFromDir: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{"tagged.jpg": exifDate}},
+	}
+
+	if got, source, _ := extractFromChain(app.dateChain(), "/archive/2019-05-06 Trip/tagged.jpg"); !got.Equal(exifDate) || source != "DateTimeOriginal" {
+		t.Errorf("Expected EXIF to take priority, but got %v from %q", got, source)
+	}
+	got, source, err := extractFromChain(app.dateChain(), "/archive/2019-05-06 Trip/scan.jpg")
+	if err != nil || !got.Equal(time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC)) || source != "Dir" {
+		t.Errorf("Expected the folder date, but got %v from %q", got, source)
+	}
+}
+
+func TestVideoUseMtime(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "clip.mp4", "photo.jpg")
+	mtime := time.Date(2019, 5, 6, 7, 8, 9, 0, time.Local)
+	for _, name := range []string{"clip.mp4", "photo.jpg"} {
+		if err := os.Chtimes(filepath.Join(inputDir, name), mtime, mtime); err != nil {
+			t.Fatalf("Failed to set file time: %v", err)
+		}
+	}
+
+	exifDate := time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 10, CopyMode: true, VideoUseMtime: true},
+		// Both files carry an EXIF date; only the photo's is trusted.
+		ExifService: &fakeExif{dates: map[string]time.Time{"clip.mp4": exifDate, "photo.jpg": exifDate}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{"2019/05/clip.mp4": "clip.mp4", "2021/07/photo.jpg": "photo.jpg"}
+	if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+// badDateExif is fakeExif where some files have a date tag in an unknown format.
+type badDateExif struct {
+	fakeExif
+	bad map[string]string
+}
+
+func (b *badDateExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	if value, ok := b.bad[filepath.Base(path)]; ok {
+		return time.Time{}, "", &internal.UnparseableDateError{Tag: "DateTimeOriginal", Value: value}
+	}
+	return b.fakeExif.ExtractDate(path, debug, useFileModifyDate)
+}
+
+// failingExif is fakeExif where exiftool fails for some files.
+type failingExif struct {
+	fakeExif
+	errs map[string]error
+}
+
+func (f *failingExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	if err, ok := f.errs[filepath.Base(path)]; ok {
+		return time.Time{}, "", err
+	}
+	return f.fakeExif.ExtractDate(path, debug, useFileModifyDate)
+}
+
+func TestExtractDateKeepsExtractorError(t *testing.T) {
+	errExiftool := errors.New("exiftool exited")
+	app := &App{
+		Config: &Config{DateSources: []string{sourceExif, sourceFilename}},
+		ExifService: &failingExif{
+			fakeExif: fakeExif{dates: map[string]time.Time{"IMG_20200101_080000.jpg": time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)}},
+			errs:     map[string]error{"broken.jpg": errExiftool, "IMG_20200101_080000.jpg": errExiftool},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{"Failed read without a date", "/in/broken.jpg", errExiftool},
+		{"Later source has a date", "/in/IMG_20200101_080000.jpg", nil},
+		{"No date at all", "/in/nodate.jpg", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := extractFromChain(app.dateChain(), tc.path)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected chain error %v, but got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := app.extractDate("/in/broken.jpg"); err == nil || !errors.Is(err, errExiftool) {
+		t.Errorf("Expected the exiftool error to be reported, but got %v", err)
+	}
+}
+
+func TestQuarantineUnparseableDates(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "good.jpg", "bad.jpg", "nodate.jpg")
+
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 10, CopyMode: true, QuarantineBadDates: true},
+		ExifService: &badDateExif{
+			fakeExif: fakeExif{dates: map[string]time.Time{"good.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)}},
+			bad:      map[string]string{"bad.jpg": "04/07/2021 10:30"},
+		},
+	}
+	app.Run()
+
+	// The file with a bad date is quarantined; the file without any date tag still fails.
+	expected := map[string]string{"2021/07/good.jpg": "good.jpg", badDatesDirName + "/bad.jpg": "bad.jpg"}
+	if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+	if failed := app.stats.failed.Load(); failed != 1 {
+		t.Errorf("Expected only nodate.jpg to fail, but got %d failures", failed)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// indexDestination hashes every file already in the local output roots into app.destHashes,
+// so -dedupe-across-dest can recognize content that is filed anywhere in the tree.
+func (app *App) indexDestination() {
+	var existing []string
+	for _, root := range app.Config.localRoots() {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				logrus.Warnf("⚠️ Cannot index %s: %v", path, err)
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && d.Name() != outputMarker {
+				existing = append(existing, path)
+			}
+			return nil
+		})
+	}
+
+	indexing := progressbar.NewOptions(len(existing),
+		progressbar.OptionSetDescription("Indexing output"),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowCount(),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
+	)
+	app.runPool(existing, indexing, func(path string) error {
+		hash, err := app.hashFile(path)
+		if err != nil {
+			logrus.Warnf("⚠️ Cannot index %s: %v", path, err)
+			return nil
+		}
+		app.destHashes.Add(path, hash)
+		return nil
+	})
+	logrus.Infof("Indexed %d existing files in the output", app.destHashes.Len())
+}
+
+// duplicateInDest returns an existing output file with the same content as path, if any.
+// The file itself does not count, so re-filing a tree in place keeps its files.
+func (app *App) duplicateInDest(path string) (string, bool) {
+	hash, ok := app.hashes.Hash(path)
+	if !ok {
+		var err error
+		if hash, err = app.hashFile(path); err != nil {
+			logrus.Warnf("Cannot hash %s to check the output for duplicates: %v", path, err)
+			return "", false
+		}
+	}
+	for _, existing := range app.destHashes.Paths(hash) {
+		if existing != path {
+			return existing, true
+		}
+	}
+	return "", false
+}
+
+// skipDuplicate reports whether -dedupe-across-dest should skip path, logging why.
+// With -dedupe-dry-run the decision is only recorded and the file is processed normally.
+func (app *App) skipDuplicate(path string) bool {
+	if !app.Config.DedupeAcrossDest && app.Duplicates == nil {
+		return false
+	}
+	existing, ok := app.duplicateInDest(path)
+	if !ok {
+		return false
+	}
+	if app.Duplicates != nil {
+		if err := app.Duplicates.Record(path, existing); err != nil {
+			logrus.Errorf("Failed to record duplicate %s: %v", path, err)
+		}
+	}
+	if !app.Config.DedupeAcrossDest {
+		logrus.Infof("[DEDUPE-DRY-RUN] %s would be skipped: already in the output as %s", path, existing)
+		return false
+	}
+	logrus.Infof("Skipping %s: already in the output as %s", path, existing)
+	return true
+}
+
+// linkFile creates hard links; tests replace it to inject failures.
+var linkFile = os.Link
+
+// canonicalCopy returns an output file with the same content as path for -dedupe-hardlink,
+// or "" if there is none yet. The returned function must be called once path is placed:
+// until then other files with the same content wait, so only one becomes the canonical copy.
+func (app *App) canonicalCopy(path string) (string, func()) {
+	hash, ok := app.hashes.Hash(path)
+	if !ok {
+		var err error
+		if hash, err = app.hashFile(path); err != nil {
+			logrus.Warnf("Cannot hash %s to look for duplicates in the output: %v", path, err)
+			return "", func() {}
+		}
+		app.hashes.Add(path, hash)
+	}
+	release := app.contentSlots.Acquire(hash, 1)
+	for _, existing := range app.destHashes.Paths(hash) {
+		if existing != path {
+			return existing, release
+		}
+	}
+	return "", release
+}
+
+// linkDuplicate places path at targetPath as a hard link to canonical, an output file with the
+// same content, removing the source unless -copy is set. A canonical copy on another file system
+// cannot be linked to, so the file is skipped with errSkipFile.
+func (app *App) linkDuplicate(path, canonical, targetPath string) error {
+	if err := linkFile(canonical, targetPath); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			logrus.Infof("Skipping %s: its duplicate %s is on another file system", path, canonical)
+			return errSkipFile
+		}
+		return fmt.Errorf("failed to link %s to %s: %w", targetPath, canonical, err)
+	}
+	logrus.Infof("Link: %s → %s (duplicate of %s)", path, targetPath, canonical)
+	if !app.Config.CopyMode {
+		if err := app.removeSource(path); err != nil {
+			return fmt.Errorf("linked %s but failed to remove the source: %w", targetPath, err)
+		}
+		app.recordMoved(path)
+	}
+	app.afterPlace(path, targetPath)
+	return nil
+}
+
+// DedupeReport writes a CSV row for every file -dedupe-across-dest skips, or would skip,
+// with the output file kept in its place.
+type DedupeReport struct {
+	out *SafeWriter
+}
+
+// NewDedupeReport creates the report at path and writes the header row.
+func NewDedupeReport(path string, checkpointEvery int) (*DedupeReport, error) {
+	out, err := CreateSafeWriter(path, 0644, checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteRecord([]string{"skipped", "kept"}); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return &DedupeReport{out: out}, nil
+}
+
+// Record adds a skipped file and the existing copy that is kept.
+func (r *DedupeReport) Record(skipped, kept string) error {
+	return r.out.WriteRecord([]string{skipped, kept})
+}
+
+// Close closes the report file.
+func (r *DedupeReport) Close() error {
+	return r.out.Close()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDedupeAcrossDest(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "dup.jpg", "new.jpg")
+		outputDir := t.TempDir()
+		// The same content is already filed under another month.
+		existing := filepath.Join(outputDir, "2020", "03", "IMG_0420.jpg")
+		if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+			t.Fatalf("Failed to create output dir: %v", err)
+		}
+		if err := os.WriteFile(existing, []byte("dup.jpg"), 0644); err != nil {
+			t.Fatalf("Failed to write existing file: %v", err)
+		}
+
+		date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+		app := &App{
+			Config: &Config{
+				InputPath:        inputDir,
+				OutputPath:       outputDir,
+				Workers:          2,
+				Buffer:           1,
+				DedupeAcrossDest: true,
+				Deterministic:    deterministic,
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"dup.jpg": date, "new.jpg": date}},
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		tree := readTree(t, outputDir)
+		expected := map[string]string{"2020/03/IMG_0420.jpg": "dup.jpg", "2021/07/new.jpg": "new.jpg"}
+		if len(tree) != len(expected) || tree["2020/03/IMG_0420.jpg"] != "dup.jpg" || tree["2021/07/new.jpg"] != "new.jpg" {
+			t.Errorf("Expected %v with deterministic=%v, but got %v", expected, deterministic, tree)
+		}
+		if _, err := os.Stat(filepath.Join(inputDir, "dup.jpg")); err != nil {
+			t.Errorf("Expected the duplicate source to be left in place, but got %v", err)
+		}
+	}
+}
+
+func TestDedupeDryRun(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "dup.jpg", "new.jpg")
+	outputDir := t.TempDir()
+	existing := filepath.Join(outputDir, "2020", "03", "IMG_0420.jpg")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatalf("Failed to create output dir: %v", err)
+	}
+	if err := os.WriteFile(existing, []byte("dup.jpg"), 0644); err != nil {
+		t.Fatalf("Failed to write existing file: %v", err)
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "dedupe.csv")
+	report, err := NewDedupeReport(reportPath, 1)
+	if err != nil {
+		t.Fatalf("NewDedupeReport failed: %v", err)
+	}
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{
+			InputPath:  inputDir,
+			OutputPath: outputDir,
+			Workers:    2,
+			Buffer:     1,
+			CopyMode:   true,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{"dup.jpg": date, "new.jpg": date}},
+		Duplicates:  report,
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	report.Close()
+
+	f, err := os.Open(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to open report: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	expectedRows := [][]string{{"skipped", "kept"}, {filepath.Join(inputDir, "dup.jpg"), existing}}
+	if !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("Expected report %v, but got %v", expectedRows, rows)
+	}
+
+	// Nothing is skipped: the duplicate is still copied.
+	expectedTree := map[string]string{"2020/03/IMG_0420.jpg": "dup.jpg", "2021/07/dup.jpg": "dup.jpg", "2021/07/new.jpg": "new.jpg"}
+	if tree := readTree(t, outputDir); !reflect.DeepEqual(tree, expectedTree) {
+		t.Errorf("Expected %v, but got %v", expectedTree, tree)
+	}
+}
+
+func TestDedupeHardlink(t *testing.T) {
+	originalLink := linkFile
+	defer func() { linkFile = originalLink }()
+
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{"a.jpg": date, "c.jpg": date}}
+
+	for _, tc := range []struct {
+		name          string
+		deterministic bool
+		crossDevice   bool
+	}{
+		{"Links duplicates", false, false},
+		{"Links duplicates in a planned run", true, false},
+		{"Skips duplicates on another file system", false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			linkFile = originalLink
+			if tc.crossDevice {
+				linkFile = func(oldname, newname string) error {
+					return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EXDEV}
+				}
+			}
+
+			inputDir := t.TempDir()
+			outputDir := t.TempDir()
+			writeFiles(t, inputDir, "c.jpg")
+			for _, path := range []string{filepath.Join(inputDir, "a.jpg"), filepath.Join(inputDir, "b", "a.jpg"), filepath.Join(outputDir, "2020", "03", "old.jpg")} {
+				os.MkdirAll(filepath.Dir(path), 0755)
+				if err := os.WriteFile(path, []byte("same"), 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", path, err)
+				}
+			}
+
+			app := &App{
+				Config: &Config{
+					InputPath:      inputDir,
+					OutputPath:     outputDir,
+					Workers:        2,
+					Buffer:         1,
+					CopyMode:       true,
+					Deterministic:  tc.deterministic,
+					DedupeHardlink: true,
+				},
+				ExifService: exif,
+			}
+			if err := app.Run(); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			expected := map[string]string{"2020/03/old.jpg": "same", "2021/07/a.jpg": "same", "2021/07/a-1.jpg": "same", "2021/07/c.jpg": "c.jpg"}
+			if tc.crossDevice {
+				expected = map[string]string{"2020/03/old.jpg": "same", "2021/07/c.jpg": "c.jpg"}
+			}
+			if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("Expected %v, but got %v", expected, got)
+			}
+
+			canonical, err := os.Stat(filepath.Join(outputDir, "2020", "03", "old.jpg"))
+			if err != nil {
+				t.Fatalf("Failed to stat the canonical copy: %v", err)
+			}
+			for rel, content := range expected {
+				info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(rel)))
+				if err != nil {
+					t.Fatalf("Failed to stat %s: %v", rel, err)
+				}
+				if shared := os.SameFile(canonical, info); shared != (content == "same") {
+					t.Errorf("Expected %s to share the inode of the canonical copy: %v, but got %v", rel, content == "same", shared)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// destination is one target folder found by -list-destinations.
+type destination struct {
+	Dir   string
+	Files int
+}
+
+// resolvedTarget is the destination of one file, relative to its output root.
+type resolvedTarget struct {
+	Dirs []string
+	Date time.Time
+}
+
+// resolveAll resolves the destination folders of every path without placing anything.
+// Skipped files are left out; it returns the destinations and the number of files that failed.
+func (app *App) resolveAll(paths []string, description string) (map[string]resolvedTarget, int) {
+	var mu sync.Mutex
+	resolved := make(map[string]resolvedTarget, len(paths))
+	failed := 0
+
+	bar := progressbar.NewOptions(len(paths),
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowCount(),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
+	)
+	app.runPool(paths, bar, func(path string) error {
+		dirs, date, err := app.resolveDirs(path)
+		if errors.Is(err, errSkipFile) {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			logrus.Debugf("Cannot resolve a destination for %s: %v", path, err)
+			failed++
+			return nil
+		}
+		resolved[path] = resolvedTarget{Dirs: dirs, Date: date}
+		return nil
+	})
+	return resolved, failed
+}
+
+// listDestinations returns the distinct target folders of paths in sorted order, with the
+// number of files going to each, along with the number of files that could not be resolved.
+func (app *App) listDestinations(paths []string) ([]destination, int) {
+	resolved, failed := app.resolveAll(paths, "Listing")
+	counts := make(map[string]int)
+	for path, r := range resolved {
+		dir, _ := app.targetFor(app.rootFor(path, r.Date), path, r.Dirs)
+		counts[dir]++
+	}
+
+	list := make([]destination, 0, len(counts))
+	for dir, n := range counts {
+		list = append(list, destination{Dir: dir, Files: n})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Dir < list[j].Dir })
+	return list, failed
+}
+
+// printDestinations writes one line per target folder with its file count.
+func printDestinations(w io.Writer, list []destination, failed int) {
+	fmt.Fprintf(w, "%d destination folders:\n", len(list))
+	for _, d := range list {
+		fmt.Fprintf(w, "%7d  %s\n", d.Files, d.Dir)
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "%d files have no destination (no date found)\n", failed)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListDestinations(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg", "d.mov", "undated.jpg")
+	before := readTree(t, inputDir)
+
+	july := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 10, ListDestinations: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"a.jpg": july,
+			"b.jpg": july,
+			"c.jpg": time.Date(2020, 12, 25, 9, 0, 0, 0, time.UTC),
+			"d.mov": time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+	paths, _ := app.collectFiles()
+	list, failed := app.listDestinations(paths)
+
+	expected := []destination{
+		{Dir: filepath.Join(outputDir, "2020", "12"), Files: 1},
+		{Dir: filepath.Join(outputDir, "2021", "01"), Files: 1},
+		{Dir: filepath.Join(outputDir, "2021", "07"), Files: 2},
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Expected %v, but got %v", expected, list)
+	}
+	if failed != 1 {
+		t.Errorf("Expected 1 file without a destination, but got %d", failed)
+	}
+
+	var out bytes.Buffer
+	printDestinations(&out, list, failed)
+	if !strings.HasPrefix(out.String(), "3 destination folders:\n") || !strings.Contains(out.String(), "2  "+filepath.Join(outputDir, "2021", "07")) {
+		t.Errorf("Expected a compact listing, but got:\n%s", out.String())
+	}
+
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if after := readTree(t, inputDir); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected the input to be left alone, but got %v", after)
+	}
+	if placed := readTree(t, outputDir); len(placed) != 0 {
+		t.Errorf("Expected nothing to be placed, but got %v", placed)
+	}
+}
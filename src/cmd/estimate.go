@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// estimate is the projected cost of a full run, extrapolated from a timed sample.
+type estimate struct {
+	Sampled        int
+	PerFile        time.Duration
+	Total          time.Duration
+	BytesPerSecond float64
+}
+
+// extrapolate projects the duration of processing total files with the given number of workers
+// from the time it took to process a sample sequentially.
+func extrapolate(sampled int, sampledBytes int64, elapsed time.Duration, total, workers int) estimate {
+	if sampled == 0 {
+		return estimate{}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	e := estimate{Sampled: sampled, PerFile: elapsed / time.Duration(sampled)}
+	e.Total = e.PerFile * time.Duration(total) / time.Duration(workers)
+	if elapsed > 0 {
+		e.BytesPerSecond = float64(sampledBytes) / elapsed.Seconds()
+	}
+	return e
+}
+
+// runEstimate times date extraction and a local copy of up to n random files into a
+// temporary directory, which is removed afterwards, and extrapolates to all paths.
+func (app *App) runEstimate(paths []string, n int) (estimate, error) {
+	tempDir, err := os.MkdirTemp("", "media_organizer_estimate")
+	if err != nil {
+		return estimate{}, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	if n > len(paths) {
+		n = len(paths)
+	}
+
+	var sampled int
+	var sampledBytes int64
+	start := time.Now()
+	for i, idx := range rand.Perm(len(paths))[:n] {
+		path := paths[idx]
+		release, err := app.acquireInput(path)
+		if err != nil {
+			logrus.Warnf("Estimate: %v", err)
+			continue
+		}
+		if _, _, err := app.resolveDirs(path); err != nil {
+			logrus.Debugf("Estimate: cannot resolve %s: %v", path, err)
+		}
+		err = app.copyFile(path, filepath.Join(tempDir, fmt.Sprintf("%d-%s", i, filepath.Base(path))))
+		if info, statErr := os.Stat(path); err == nil && statErr == nil {
+			sampledBytes += info.Size()
+		}
+		release()
+		if err != nil {
+			logrus.Warnf("Estimate: failed to copy %s: %v", path, err)
+			continue
+		}
+		sampled++
+	}
+
+	return extrapolate(sampled, sampledBytes, time.Since(start), len(paths), app.Config.Workers), nil
+}
+
+// printEstimate reports an estimate on stdout and in the log.
+func printEstimate(e estimate, total int) {
+	msg := fmt.Sprintf("Estimate from %d sampled files: %s per file, ~%s for %d files, %.1f MB/s",
+		e.Sampled, e.PerFile.Round(time.Millisecond), e.Total.Round(time.Second), total, e.BytesPerSecond/(1<<20))
+	fmt.Println(msg)
+	logrus.Info(msg)
+}
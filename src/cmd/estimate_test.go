@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtrapolate(t *testing.T) {
+	// 4 files totalling 40 MB took 2s: 500ms per file, 20 MB/s.
+	e := extrapolate(4, 40<<20, 2*time.Second, 1000, 4)
+
+	if e.PerFile != 500*time.Millisecond {
+		t.Errorf("Expected 500ms per file, but got %v", e.PerFile)
+	}
+	if e.Total != 125*time.Second {
+		t.Errorf("Expected 125s total with 4 workers, but got %v", e.Total)
+	}
+	if e.BytesPerSecond != 20<<20 {
+		t.Errorf("Expected 20 MB/s, but got %v", e.BytesPerSecond)
+	}
+
+	if empty := extrapolate(0, 0, 0, 1000, 4); empty.Total != 0 {
+		t.Errorf("Expected zero estimate for an empty sample, but got %+v", empty)
+	}
+}
+
+func TestRunEstimateCleansUp(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg")
+	paths := []string{filepath.Join(inputDir, "a.jpg"), filepath.Join(inputDir, "b.jpg"), filepath.Join(inputDir, "c.jpg")}
+
+	app := &App{Config: &Config{Workers: 1}, ExifService: &fakeExif{}}
+	e, err := app.runEstimate(paths, 2)
+	if err != nil {
+		t.Fatalf("runEstimate failed: %v", err)
+	}
+	if e.Sampled != 2 {
+		t.Errorf("Expected 2 sampled files, but got %d", e.Sampled)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(os.TempDir(), "media_organizer_estimate*"))
+	if len(matches) != 0 {
+		t.Errorf("Expected temp files to be removed, but found %v", matches)
+	}
+	if tree := readTree(t, inputDir); len(tree) != 3 {
+		t.Errorf("Expected input to be untouched, but got %v", tree)
+	}
+}
+
+func TestEstimateLeavesOutputRoot(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg")
+	outputDir := filepath.Join(t.TempDir(), "photos")
+
+	config := &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 1, Buffer: 1, CopyMode: true, Estimate: 2}
+	if writesOutputRoot(config) {
+		t.Errorf("Expected -estimate not to check or mark the output root")
+	}
+	app := &App{Config: config, ExifService: &fakeExif{}}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the output root not to be created, but got %v", err)
+	}
+}
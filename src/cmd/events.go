@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// timedPath is a file and its capture date, the input to event clustering.
+type timedPath struct {
+	Path string
+	Time time.Time
+}
+
+// event is a run of files whose consecutive capture times are less than the gap apart.
+type event struct {
+	Start time.Time
+	Paths []string
+}
+
+// clusterByGap sorts files by time and starts a new event wherever the gap to the
+// previous file is at least gap.
+func clusterByGap(times []timedPath, gap time.Duration) []event {
+	sorted := append([]timedPath(nil), times...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	var events []event
+	for i, tp := range sorted {
+		if i == 0 || tp.Time.Sub(sorted[i-1].Time) >= gap {
+			events = append(events, event{Start: tp.Time})
+		}
+		current := &events[len(events)-1]
+		current.Paths = append(current.Paths, tp.Path)
+	}
+	return events
+}
+
+// eventFolder names the n-th event (1-based).
+func eventFolder(n int) string {
+	return fmt.Sprintf("Event-%03d", n)
+}
+
+// assignEvents appends an event folder to the dirs of every dated plan entry.
+// Events are numbered chronologically across the whole run.
+func assignEvents(plan []planEntry, gap time.Duration) {
+	index := make(map[string]int, len(plan))
+	var times []timedPath
+	for i, entry := range plan {
+		if entry.Date.IsZero() {
+			continue
+		}
+		index[entry.Source] = i
+		times = append(times, timedPath{Path: entry.Source, Time: entry.Date})
+	}
+
+	for n, ev := range clusterByGap(times, gap) {
+		for _, path := range ev.Paths {
+			entry := &plan[index[path]]
+			entry.Dirs = append(append([]string(nil), entry.Dirs...), eventFolder(n+1))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClusterByGap(t *testing.T) {
+	base := time.Date(2021, 7, 4, 9, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) time.Time { return base.Add(offset) }
+
+	testCases := []struct {
+		name     string
+		times    []timedPath
+		expected [][]string
+	}{
+		{
+			name: "Clear gaps",
+			times: []timedPath{
+				{"c.jpg", at(10 * time.Hour)},
+				{"a.jpg", at(0)},
+				{"b.jpg", at(30 * time.Minute)},
+				{"d.jpg", at(10*time.Hour + time.Minute)},
+			},
+			expected: [][]string{{"a.jpg", "b.jpg"}, {"c.jpg", "d.jpg"}},
+		},
+		{
+			name: "Gap equal to threshold splits",
+			times: []timedPath{
+				{"a.jpg", at(0)},
+				{"b.jpg", at(4 * time.Hour)},
+			},
+			expected: [][]string{{"a.jpg"}, {"b.jpg"}},
+		},
+		{
+			name: "Chained small gaps stay together",
+			times: []timedPath{
+				{"a.jpg", at(0)},
+				{"b.jpg", at(3 * time.Hour)},
+				{"c.jpg", at(6 * time.Hour)},
+				{"d.jpg", at(9*time.Hour + 59*time.Minute)},
+			},
+			expected: [][]string{{"a.jpg", "b.jpg", "c.jpg", "d.jpg"}},
+		},
+		{
+			name:     "No files",
+			times:    nil,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got [][]string
+			for _, ev := range clusterByGap(tc.times, 4*time.Hour) {
+				got = append(got, ev.Paths)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected events %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAssignEvents(t *testing.T) {
+	day := time.Date(2021, 7, 4, 9, 0, 0, 0, time.UTC)
+	plan := []planEntry{
+		{Source: "a.jpg", Dirs: []string{"2021", "07"}, Date: day},
+		{Source: "b.jpg", Dirs: []string{"2021", "07"}, Date: day.Add(8 * time.Hour)},
+		{Source: "song.mp3", Dirs: []string{"Artist", "Album"}},
+	}
+	assignEvents(plan, 4*time.Hour)
+
+	expected := []string{"2021/07/Event-001", "2021/07/Event-002", "Artist/Album"}
+	for i, entry := range plan {
+		if got := strings.Join(entry.Dirs, "/"); got != expected[i] {
+			t.Errorf("Expected %v for %s, but got %v", expected[i], entry.Source, got)
+		}
+	}
+}
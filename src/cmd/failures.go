@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorReport writes a CSV row for every file that failed, so the run can be retried
+// later with -reprocess-errors.
+type ErrorReport struct {
+	out *SafeWriter
+}
+
+// NewErrorReport creates the report at path and writes the header row.
+func NewErrorReport(path string, checkpointEvery int) (*ErrorReport, error) {
+	out, err := CreateSafeWriter(path, 0644, checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteRecord([]string{"path", "error"}); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return &ErrorReport{out: out}, nil
+}
+
+// Record adds a failed file and its error.
+func (r *ErrorReport) Record(path string, failure error) error {
+	return r.out.WriteRecord([]string{path, failure.Error()})
+}
+
+// Close closes the report file.
+func (r *ErrorReport) Close() error {
+	return r.out.Close()
+}
+
+// readFailureList returns the paths in the first column of a failure report,
+// skipping the header row.
+func readFailureList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	var paths []string
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if (line == 1 && row[0] == "path") || row[0] == "" {
+			continue
+		}
+		paths = append(paths, row[0])
+	}
+}
+
+// failedFiles returns the files listed in a failure report that still exist.
+func (app *App) failedFiles(report string) ([]string, error) {
+	listed, err := readFailureList(report)
+	if err != nil {
+		return nil, err
+	}
+	return existingFiles(listed, report), nil
+}
+
+// existingFiles returns the listed paths that are regular files, warning about the rest.
+// source names where the list came from.
+func existingFiles(listed []string, source string) []string {
+	var paths []string
+	for _, path := range listed {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			logrus.Warnf("Skipping %s from %s: no longer a file", path, source)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
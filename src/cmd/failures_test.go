@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorReportRoundTrip(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a_undated.jpg", "b.jpg", "sub/c_undated.jpg")
+	reportPath := filepath.Join(t.TempDir(), "failures.csv")
+
+	report, err := NewErrorReport(reportPath, 1)
+	if err != nil {
+		t.Fatalf("NewErrorReport failed: %v", err)
+	}
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: t.TempDir(), Workers: 2, Buffer: 1, CopyMode: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{"b.jpg": date}},
+		Errors:      report,
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	report.Close()
+
+	listed, err := readFailureList(reportPath)
+	if err != nil {
+		t.Fatalf("readFailureList failed: %v", err)
+	}
+	expected := []string{filepath.Join(inputDir, "a_undated.jpg"), filepath.Join(inputDir, "sub", "c_undated.jpg")}
+	if len(listed) != len(expected) {
+		t.Fatalf("Expected %v, but got %v", expected, listed)
+	}
+	for _, path := range expected {
+		if !strings.Contains(strings.Join(listed, "\n"), path) {
+			t.Errorf("Expected %s in the failure report, but got %v", path, listed)
+		}
+	}
+}
+
+func TestReprocessErrors(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "sub/c.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+
+	reportPath := filepath.Join(t.TempDir(), "failures.csv")
+	csv := "path,error\n" +
+		filepath.Join(inputDir, "a.jpg") + ",no date found\n" +
+		filepath.Join(inputDir, "sub", "c.jpg") + ",\"exiftool: timeout, retry\"\n" +
+		filepath.Join(inputDir, "gone.jpg") + ",no date found\n"
+	if err := os.WriteFile(reportPath, []byte(csv), 0644); err != nil {
+		t.Fatalf("Failed to write failure report: %v", err)
+	}
+
+	outputDir := t.TempDir()
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 1, CopyMode: true, ReprocessErrors: reportPath},
+		ExifService: &fakeExif{dates: map[string]time.Time{"a.jpg": date, "b.jpg": date, "c.jpg": date}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	tree := readTree(t, outputDir)
+	if len(tree) != 2 || tree["2021/07/a.jpg"] != "a.jpg" || tree["2021/07/c.jpg"] != "sub/c.jpg" {
+		t.Errorf("Expected only a.jpg and c.jpg to be processed, but got %v", tree)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// yearDirPattern and monthDirPattern match the folders of the YYYY/MM layout.
+var (
+	yearDirPattern  = regexp.MustCompile(`^\d{4}$`)
+	monthDirPattern = regexp.MustCompile(`^(0[1-9]|1[0-2])$`)
+)
+
+// sparseYear is a year folder with few enough month folders to flatten.
+type sparseYear struct {
+	dir    string
+	months []string
+}
+
+// findSparseYears returns the year folders directly under root that hold at most maxMonths
+// month folders. Years whose month folders contain subfolders are left alone.
+func findSparseYears(root string, maxMonths int) ([]sparseYear, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var sparse []sparseYear
+	for _, entry := range entries {
+		if !entry.IsDir() || !yearDirPattern.MatchString(entry.Name()) {
+			continue
+		}
+		year := sparseYear{dir: filepath.Join(root, entry.Name())}
+		flat, err := collectMonths(&year)
+		if err != nil {
+			logrus.Warnf("Cannot inspect %s for flattening: %v", year.dir, err)
+			continue
+		}
+		if flat && len(year.months) > 0 && len(year.months) <= maxMonths {
+			sparse = append(sparse, year)
+		}
+	}
+	return sparse, nil
+}
+
+// collectMonths fills year.months and reports whether every month folder holds only files.
+func collectMonths(year *sparseYear) (bool, error) {
+	entries, err := os.ReadDir(year.dir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if !monthDirPattern.MatchString(entry.Name()) {
+			return false, nil
+		}
+		month := filepath.Join(year.dir, entry.Name())
+		files, err := os.ReadDir(month)
+		if err != nil {
+			return false, err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				return false, nil
+			}
+		}
+		year.months = append(year.months, month)
+	}
+	return true, nil
+}
+
+// flattenSparseYears moves the files of sparse years up into the year folder and removes the
+// emptied month folders. In dry-run mode it only reports them.
+func (app *App) flattenSparseYears() {
+	for _, root := range app.Config.localRoots() {
+		sparse, err := findSparseYears(root, app.Config.FlattenSparseYears)
+		if err != nil {
+			logrus.Errorf("Failed to look for sparse years in %s: %v", root, err)
+			continue
+		}
+		for _, year := range sparse {
+			if app.Config.DryRun {
+				logrus.Infof("Would flatten %s: only %d month folder(s)", year.dir, len(year.months))
+				continue
+			}
+			if err := app.flattenYear(year); err != nil {
+				logrus.Errorf("Failed to flatten %s: %v", year.dir, err)
+				continue
+			}
+			logrus.Infof("Flattened %s: moved the files of %d month folder(s) into it", year.dir, len(year.months))
+		}
+	}
+}
+
+// flattenYear moves every file of the year's month folders into the year folder, renaming
+// on collision, and removes each month folder once it is empty.
+func (app *App) flattenYear(year sparseYear) error {
+	for _, month := range year.months {
+		files, err := os.ReadDir(month)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			target := app.reserveTarget(filepath.Join(year.dir, file.Name()))
+			if err := renameFile(filepath.Join(month, file.Name()), target); err != nil {
+				return fmt.Errorf("failed to move %s: %w", file.Name(), err)
+			}
+		}
+		if err := removeFile(month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
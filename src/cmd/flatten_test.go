@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlattenSparseYears(t *testing.T) {
+	dates := map[string]time.Time{
+		"sparse1.jpg": time.Date(2019, 7, 4, 10, 0, 0, 0, time.UTC),
+		"sparse2.jpg": time.Date(2019, 7, 5, 10, 0, 0, 0, time.UTC),
+		"dense1.jpg":  time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC),
+		"dense2.jpg":  time.Date(2021, 5, 4, 10, 0, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		name     string
+		dryRun   bool
+		existing []string
+		expected map[string]string
+	}{
+		{
+			name: "Sparse year flattened",
+			expected: map[string]string{
+				"2019/sparse1.jpg":    "sparse1.jpg",
+				"2019/sparse2.jpg":    "sparse2.jpg",
+				"2021/01/dense1.jpg":  "dense1.jpg",
+				"2021/05/dense2.jpg":  "dense2.jpg",
+				"2017/03/Event/x.jpg": "2017/03/Event/x.jpg",
+			},
+			existing: []string{"2017/03/Event/x.jpg"},
+		},
+		{
+			name:     "Collision in year folder",
+			existing: []string{"2019/sparse1.jpg"},
+			expected: map[string]string{
+				"2019/sparse1.jpg":   "2019/sparse1.jpg",
+				"2019/sparse1-1.jpg": "sparse1.jpg",
+				"2019/sparse2.jpg":   "sparse2.jpg",
+				"2021/01/dense1.jpg": "dense1.jpg",
+				"2021/05/dense2.jpg": "dense2.jpg",
+			},
+		},
+		{
+			name:     "Dry run only reports",
+			dryRun:   true,
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			writeFiles(t, inputDir, "sparse1.jpg", "sparse2.jpg", "dense1.jpg", "dense2.jpg")
+			outputDir := t.TempDir()
+			writeFiles(t, outputDir, tc.existing...)
+
+			app := &App{
+				Config: &Config{
+					InputPath:          inputDir,
+					OutputPath:         outputDir,
+					Workers:            2,
+					Buffer:             1,
+					CopyMode:           true,
+					DryRun:             tc.dryRun,
+					FlattenSparseYears: 1,
+				},
+				ExifService: &fakeExif{dates: dates},
+			}
+			if err := app.Run(); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if got := readTree(t, outputDir); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
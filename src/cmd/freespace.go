@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// freeSpaceInterval is how often workers check -min-free-percent; tests shorten it.
+var freeSpaceInterval = 10 * time.Second
+
+// diskUsage returns the bytes available to unprivileged users and the total size of the
+// filesystem holding path; tests substitute a fake.
+var diskUsage = statDisk
+
+// checkFreeSpace aborts the run when a local output root has less than -min-free-percent free.
+// At most one worker checks per freeSpaceInterval; the others return immediately.
+func (app *App) checkFreeSpace() {
+	if app.Config.MinFreePercent <= 0 || app.Config.IsRemote || app.Config.Archive != "" || app.Config.DryRun {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := app.spaceChecked.Load()
+	if last != 0 && now-last < int64(freeSpaceInterval) {
+		return
+	}
+	if !app.spaceChecked.CompareAndSwap(last, now) {
+		return
+	}
+
+	for _, root := range app.Config.localRoots() {
+		free, total, err := diskUsage(root)
+		if err != nil || total == 0 {
+			logrus.Debugf("Cannot check free space on %s: %v", root, err)
+			continue
+		}
+		percent := float64(free) / float64(total) * 100
+		if percent < app.Config.MinFreePercent {
+			app.abort(fmt.Errorf("free space on %s dropped to %.1f%%, below -min-free-percent %g", root, percent, app.Config.MinFreePercent))
+			return
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+// statDisk is not supported on this platform, so -min-free-percent never aborts.
+func statDisk(path string) (uint64, uint64, error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDisk reports plenty of free space for the first healthy calls, then almost none.
+func fakeDisk(healthy int64, calls *atomic.Int64) func(string) (uint64, uint64, error) {
+	return func(path string) (uint64, uint64, error) {
+		if calls.Add(1) <= healthy {
+			return 50, 100, nil
+		}
+		return 1, 100, nil
+	}
+}
+
+func TestMinFreePercentAbortsRun(t *testing.T) {
+	oldUsage, oldInterval := diskUsage, freeSpaceInterval
+	defer func() { diskUsage, freeSpaceInterval = oldUsage, oldInterval }()
+	var calls atomic.Int64
+	diskUsage = fakeDisk(2, &calls)
+	freeSpaceInterval = 0
+
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{"a.jpg": date, "b.jpg": date, "c.jpg": date, "d.jpg": date, "e.jpg": date}}
+
+	outputDir := t.TempDir()
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 1, Buffer: 1, CopyMode: true, MinFreePercent: 10},
+		ExifService: exif,
+	}
+	err := app.Run()
+	if err == nil || !strings.Contains(err.Error(), "-min-free-percent") {
+		t.Errorf("Expected the run to stop for low free space, but got %v", err)
+	}
+	if placed := len(readTree(t, outputDir)); placed != 2 {
+		t.Errorf("Expected 2 files placed before free space ran low, but got %d", placed)
+	}
+}
+
+func TestMinFreePercentThrottled(t *testing.T) {
+	oldUsage, oldInterval := diskUsage, freeSpaceInterval
+	defer func() { diskUsage, freeSpaceInterval = oldUsage, oldInterval }()
+	var calls atomic.Int64
+	diskUsage = fakeDisk(1, &calls)
+	freeSpaceInterval = time.Hour
+
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg", "d.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: t.TempDir(), Workers: 2, Buffer: 1, CopyMode: true, MinFreePercent: 10},
+		ExifService: &fakeExif{dates: map[string]time.Time{"a.jpg": date, "b.jpg": date, "c.jpg": date, "d.jpg": date}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected free space to be checked once within the interval, but got %d checks", got)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// hashIndex maps source files to their content hash, and each hash to the files that have it.
+// The zero value is ready to use.
+type hashIndex struct {
+	mu     sync.Mutex
+	byPath map[string]string
+	byHash map[string][]string
+}
+
+// Add records the hash of path.
+func (ix *hashIndex) Add(path, hash string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.byPath == nil {
+		ix.byPath = make(map[string]string)
+		ix.byHash = make(map[string][]string)
+	}
+	if _, ok := ix.byPath[path]; ok {
+		return
+	}
+	ix.byPath[path] = hash
+	ix.byHash[hash] = append(ix.byHash[hash], path)
+}
+
+// Remove forgets the hash of path, e.g. when the file is overwritten.
+func (ix *hashIndex) Remove(path string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	hash, ok := ix.byPath[path]
+	if !ok {
+		return
+	}
+	delete(ix.byPath, path)
+	ix.byHash[hash] = slices.DeleteFunc(ix.byHash[hash], func(p string) bool { return p == path })
+	if len(ix.byHash[hash]) == 0 {
+		delete(ix.byHash, hash)
+	}
+}
+
+// Hash returns the recorded hash of path.
+func (ix *hashIndex) Hash(path string) (string, bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	hash, ok := ix.byPath[path]
+	return hash, ok
+}
+
+// Paths returns the files recorded with hash, in the order they were added.
+func (ix *hashIndex) Paths(hash string) []string {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return append([]string(nil), ix.byHash[hash]...)
+}
+
+// Len returns the number of hashed files.
+func (ix *hashIndex) Len() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return len(ix.byPath)
+}
+
+// prehash hashes every planned source on the worker pool and fills app.hashes, so the
+// transfer phase never waits on hashing. Files that cannot be hashed are hashed again
+// inline when they are needed.
+func (app *App) prehash(plan []planEntry) {
+	sources := make([]string, 0, len(plan))
+	for _, entry := range plan {
+		sources = append(sources, entry.Source)
+	}
+
+	hashing := progressbar.NewOptions(len(sources),
+		progressbar.OptionSetDescription("Hashing"),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowCount(),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
+	)
+	app.runPool(sources, hashing, func(path string) error {
+		hash, err := app.hashFile(path)
+		if err != nil {
+			return err
+		}
+		app.hashes.Add(path, hash)
+		return nil
+	})
+	logrus.Infof("Hashed %d of %d files", app.hashes.Len(), len(sources))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashIndex(t *testing.T) {
+	var ix hashIndex
+	ix.Add("/in/a.jpg", "h1")
+	ix.Add("/in/b.jpg", "h1")
+	ix.Add("/in/c.jpg", "h2")
+	ix.Add("/in/a.jpg", "h3")
+
+	if hash, ok := ix.Hash("/in/a.jpg"); !ok || hash != "h1" {
+		t.Errorf("Expected h1 for a.jpg, but got %q, %v", hash, ok)
+	}
+	if got := ix.Paths("h1"); !reflect.DeepEqual(got, []string{"/in/a.jpg", "/in/b.jpg"}) {
+		t.Errorf("Expected a.jpg and b.jpg for h1, but got %v", got)
+	}
+	if ix.Len() != 3 {
+		t.Errorf("Expected 3 hashed files, but got %d", ix.Len())
+	}
+
+	// An overwritten file is removed and can be added again with its new hash.
+	ix.Remove("/in/a.jpg")
+	ix.Add("/in/a.jpg", "h2")
+	if hash, ok := ix.Hash("/in/a.jpg"); !ok || hash != "h2" {
+		t.Errorf("Expected h2 for a.jpg after Remove, but got %q, %v", hash, ok)
+	}
+	if got := ix.Paths("h1"); !reflect.DeepEqual(got, []string{"/in/b.jpg"}) {
+		t.Errorf("Expected only b.jpg for h1 after Remove, but got %v", got)
+	}
+	if got := ix.Paths("h2"); !reflect.DeepEqual(got, []string{"/in/c.jpg", "/in/a.jpg"}) {
+		t.Errorf("Expected c.jpg and a.jpg for h2 after Remove, but got %v", got)
+	}
+}
+
+func TestParallelHashCompletesBeforeMoves(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	names := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg", "f.jpg"}
+	writeFiles(t, inputDir, names...)
+	dates := make(map[string]time.Time)
+	for _, name := range names {
+		dates[name] = time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	}
+
+	manifest, err := NewManifest(filepath.Join(t.TempDir(), "SHA256SUMS"), 1)
+	if err != nil {
+		t.Fatalf("NewManifest failed: %v", err)
+	}
+	defer manifest.Close()
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 3, Buffer: 1, ParallelHash: true},
+		ExifService: &fakeExif{dates: dates},
+		Manifest:    manifest,
+	}
+
+	originalRename := renameFile
+	defer func() { renameFile = originalRename }()
+	var mu sync.Mutex
+	var hashedAtMove []int
+	renameFile = func(oldpath, newpath string) error {
+		mu.Lock()
+		hashedAtMove = append(hashedAtMove, app.hashes.Len())
+		mu.Unlock()
+		return originalRename(oldpath, newpath)
+	}
+
+	app.Run()
+
+	if len(hashedAtMove) != len(names) {
+		t.Fatalf("Expected %d moves, but got %d", len(names), len(hashedAtMove))
+	}
+	for _, n := range hashedAtMove {
+		if n != len(names) {
+			t.Errorf("Expected all %d files hashed before every move, but a move saw %d", len(names), n)
+		}
+	}
+	for _, name := range names {
+		if _, ok := app.hashes.Hash(filepath.Join(inputDir, name)); !ok {
+			t.Errorf("Expected %s in the hash index", name)
+		}
+	}
+}
+
+// benchmarkHashing organizes files with a manifest, hashing either inline after each move
+// or up front on the worker pool.
+func benchmarkHashing(b *testing.B, parallel bool) {
+	content := make([]byte, 1<<20)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		inputDir := b.TempDir()
+		dates := make(map[string]time.Time)
+		for n := 0; n < 32; n++ {
+			name := fmt.Sprintf("IMG_%04d.jpg", n)
+			content[0] = byte(n)
+			if err := os.WriteFile(filepath.Join(inputDir, name), content, 0644); err != nil {
+				b.Fatal(err)
+			}
+			dates[name] = time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+		}
+		manifest, err := NewManifest(filepath.Join(b.TempDir(), "SHA256SUMS"), 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		app := &App{
+			Config: &Config{
+				InputPath:     inputDir,
+				OutputPath:    b.TempDir(),
+				Workers:       4,
+				Buffer:        4,
+				CopyMode:      true,
+				Deterministic: true,
+				ParallelHash:  parallel,
+			},
+			ExifService: &fakeExif{dates: dates},
+			Manifest:    manifest,
+		}
+		b.StartTimer()
+
+		app.Run()
+		manifest.Close()
+	}
+}
+
+func BenchmarkInlineHash(b *testing.B)   { benchmarkHashing(b, false) }
+func BenchmarkParallelHash(b *testing.B) { benchmarkHashing(b, true) }
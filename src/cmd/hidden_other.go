@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// hasHiddenAttribute reports false: outside Windows, hidden files are only marked by a leading dot.
+func hasHiddenAttribute(path string) bool {
+	return false
+}
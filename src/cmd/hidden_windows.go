@@ -0,0 +1,15 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// hasHiddenAttribute reports whether path has the Windows hidden file attribute.
+func hasHiddenAttribute(path string) bool {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+	attrs, err := syscall.GetFileAttributes(name)
+	return err == nil && attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
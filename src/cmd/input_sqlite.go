@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteFiles runs -query against the -from-sqlite database, opened read-only,
+// and returns the paths it selects that exist. The query must return a single text column.
+func (app *App) sqliteFiles() ([]string, error) {
+	dsn := (&url.URL{Scheme: "file", Path: app.Config.FromSQLite, RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", app.Config.FromSQLite, err)
+	}
+	defer db.Close()
+
+	listed, err := queryPaths(db, app.Config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query on %s: %w", app.Config.FromSQLite, err)
+	}
+	return existingFiles(listed, app.Config.FromSQLite), nil
+}
+
+// queryPaths runs query and reads its rows as paths, checking that it returns exactly one
+// column and that every value is text.
+func queryPaths(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != 1 {
+		return nil, fmt.Errorf("query must return a single column, but it returns %d", len(columns))
+	}
+	// Expressions have no declared type, so each value is checked as well.
+	if declared := strings.ToUpper(columns[0].DatabaseTypeName()); declared != "" && declared != "TEXT" && !strings.Contains(declared, "CHAR") {
+		return nil, fmt.Errorf("query must return text paths, but column %s is %s", columns[0].Name(), declared)
+	}
+
+	var paths []string
+	for rows.Next() {
+		var value interface{}
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		path, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("query must return text paths, but column %s of row %d is %v", columns[0].Name(), len(paths)+1, value)
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
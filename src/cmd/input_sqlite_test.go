@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestDB opens a database and runs the setup statements in it. An in-memory
+// database exists per connection, so the pool is limited to one.
+func openTestDB(t *testing.T, dsn string, setup ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", dsn, err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range setup {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to run %q: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestQueryPaths(t *testing.T) {
+	db := openTestDB(t, ":memory:",
+		"create table todo (path text, done integer, note varchar(20))",
+		"insert into todo values ('/a.jpg', 0, 'x'), ('/b.jpg', 0, null), ('/c.jpg', 1, 'y')",
+	)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected []string
+		hasError bool
+	}{
+		{"Rows", "select path from todo where done = 0 order by path", []string{"/a.jpg", "/b.jpg"}, false},
+		{"Varchar column", "select note from todo where note is not null order by note", []string{"x", "y"}, false},
+		{"Expression", "select '/in/' || note from todo where note = 'x'", []string{"/in/x"}, false},
+		{"No rows", "select path from todo where done = 2", nil, false},
+		{"Two columns", "select path, done from todo", nil, true},
+		{"Integer column", "select done from todo", nil, true},
+		{"Integer expression", "select done + 1 from todo", nil, true},
+		{"Null value", "select note from todo order by note", nil, true},
+		{"No such table", "select path from missing", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := queryPaths(db, tc.query)
+			if tc.hasError {
+				if err == nil {
+					t.Errorf("Expected an error, but got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFromSQLite(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{"a.jpg": date, "b.jpg": date, "c.jpg": date}}
+
+	catalog := filepath.Join(t.TempDir(), "catalog.sqlite")
+	openTestDB(t, catalog,
+		"create table todo (path text, done integer)",
+		"insert into todo values ('"+filepath.Join(inputDir, "a.jpg")+"', 0), ('"+filepath.Join(inputDir, "b.jpg")+"', 1), "+
+			"('"+filepath.Join(inputDir, "c.jpg")+"', 0), ('/gone.jpg', 0)",
+	).Close()
+
+	outputDir := t.TempDir()
+	app := &App{
+		Config: &Config{
+			InputPath:  inputDir,
+			OutputPath: outputDir,
+			Workers:    2,
+			Buffer:     1,
+			CopyMode:   true,
+			FromSQLite: catalog,
+			Query:      "select path from todo where done = 0",
+		},
+		ExifService: exif,
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := map[string]string{"2021/07/a.jpg": "a.jpg", "2021/07/c.jpg": "c.jpg"}
+	if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+
+	// The database is opened read-only.
+	app.Config.Query = "delete from todo returning path"
+	if _, err := app.sqliteFiles(); err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("Expected a read-only error, but got %v", err)
+	}
+	app.Config.FromSQLite = filepath.Join(t.TempDir(), "missing.sqlite")
+	if _, err := app.sqliteFiles(); err == nil {
+		t.Errorf("Expected an error for a missing database, but got nil")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// zipInput is the -input-zip archive, open for the run. Its entries are listed up front but
+// only extracted, each to its own temporary file, while a worker needs them, so the run never
+// holds more than the files in flight in temporary space.
+type zipInput struct {
+	archive *zip.ReadCloser
+	dir     string
+	// entries maps the temporary path of every listed entry to the entry.
+	entries map[string]*zipEntry
+}
+
+// zipEntry is one listed archive entry and the number of users of its extracted file.
+type zipEntry struct {
+	file  *zip.File
+	mu    sync.Mutex
+	users int
+}
+
+// openInputZip opens the -input-zip archive and lists its files under a new temporary folder,
+// which becomes the input root so the usual filters and layouts apply. Nothing is extracted yet:
+// acquireInput writes an entry out when it is needed, since exiftool reads files by path.
+// The caller must call cleanup, which closes the archive and removes the folder.
+func (app *App) openInputZip() (paths []string, cleanup func(), err error) {
+	archive, err := zip.OpenReader(app.Config.InputZip)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", app.Config.InputZip, err)
+	}
+	dir, err := os.MkdirTemp("", "sortbydate-zip-")
+	if err != nil {
+		archive.Close()
+		return nil, nil, err
+	}
+	cleanup = func() {
+		archive.Close()
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.Warnf("Failed to remove extracted files in %s: %v", dir, err)
+		}
+	}
+
+	app.zip = &zipInput{archive: archive, dir: dir, entries: make(map[string]*zipEntry)}
+	app.Config.InputPath = dir
+	organized := organizedZipDirs(archive.File)
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		name := filepath.FromSlash(entry.Name)
+		if !filepath.IsLocal(name) {
+			logrus.Warnf("Skipping %s in %s: the entry path leaves the archive", entry.Name, app.Config.InputZip)
+			continue
+		}
+		target := filepath.Join(dir, name)
+		if _, dup := app.zip.entries[target]; dup {
+			logrus.Warnf("Skipping %s in %s: the archive holds another entry with this path", entry.Name, app.Config.InputZip)
+			continue
+		}
+		if app.skipZipEntry(name, organized) {
+			continue
+		}
+		app.zip.entries[target] = &zipEntry{file: entry}
+		if !app.acceptZipEntry(target, entry) {
+			delete(app.zip.entries, target)
+			continue
+		}
+		paths = append(paths, target)
+	}
+	// Walked inputs come in lexical order; archives keep the order they were written in.
+	sort.Strings(paths)
+	logrus.Infof("Listed %d files in %s", len(paths), app.Config.InputZip)
+	return paths, cleanup, nil
+}
+
+// organizedZipDirs returns the folders of the archive that hold an output marker, which a walk
+// of the extracted tree would skip as organized output.
+func organizedZipDirs(files []*zip.File) map[string]bool {
+	dirs := make(map[string]bool)
+	for _, entry := range files {
+		name := filepath.FromSlash(entry.Name)
+		if filepath.Base(name) == outputMarker {
+			dirs[filepath.Dir(name)] = true
+		}
+	}
+	return dirs
+}
+
+// skipZipEntry applies the folder filters of walkInput to an entry name: system folders,
+// hidden files and folders with -skip-hidden, and organized output folders.
+func (app *App) skipZipEntry(name string, organized map[string]bool) bool {
+	parts := strings.Split(name, string(filepath.Separator))
+	for i, part := range parts {
+		isDir := i < len(parts)-1
+		if isDir && app.isSystemDir(part) {
+			logrus.Debugf("Skipping %s: in system folder %s", name, part)
+			return true
+		}
+		if app.Config.SkipHidden && strings.HasPrefix(part, ".") {
+			logrus.Debugf("Skipping hidden %s", name)
+			return true
+		}
+		if isDir && organized[filepath.Join(parts[:i+1]...)] {
+			logrus.Debugf("Skipping %s: in organized output folder", name)
+			return true
+		}
+	}
+	return organized["."]
+}
+
+// acceptZipEntry applies the per-file input filters to a listed entry, using its header for the
+// size and modification time. Only sniffing -media-type needs the entry extracted.
+func (app *App) acceptZipEntry(path string, entry *zip.File) bool {
+	if app.Config.MediaType != "" && app.Config.SniffMIME {
+		release, err := app.zip.acquire(path)
+		if err != nil {
+			logrus.Warnf("Cannot extract %s from %s: %v", entry.Name, app.Config.InputZip, err)
+			return false
+		}
+		defer release()
+	}
+	return app.acceptFile(path, func() (os.FileInfo, error) { return entry.FileInfo(), nil })
+}
+
+// acquireInput extracts an -input-zip entry while it is in use, together with the files read
+// alongside it: its sidecars and, for a Live Photo video, its still. The returned release
+// removes the extracted files nobody else is using. Other paths are left alone.
+func (app *App) acquireInput(path string) (release func(), err error) {
+	if app.zip == nil {
+		return func() {}, nil
+	}
+	paths := append([]string{path}, sidecarPaths(path)...)
+	if still, ok := app.livePhotos.videos[path]; ok {
+		paths = append(paths, still)
+	}
+
+	var releases []func()
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, p := range paths {
+		r, err := app.zip.acquire(p)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("failed to extract %s from %s: %w", p, app.Config.InputZip, err)
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
+
+// acquire extracts the entry listed at path unless it is already extracted, and returns a
+// function that removes the file once its last user is done. A file moved away in the
+// meantime is simply gone. Paths that are not entries need nothing.
+func (z *zipInput) acquire(path string) (func(), error) {
+	entry, ok := z.entries[path]
+	if !ok {
+		return func() {}, nil
+	}
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.users == 0 {
+		if err := extractZipEntry(entry.file, path); err != nil {
+			return nil, err
+		}
+	}
+	entry.users++
+	return func() {
+		entry.mu.Lock()
+		defer entry.mu.Unlock()
+		entry.users--
+		if entry.users == 0 {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logrus.Warnf("Failed to remove extracted file %s: %v", path, err)
+			}
+		}
+	}, nil
+}
+
+// extractZipEntry writes one archive entry to target, keeping its modification time
+// for the mtime date source and -modified-after.
+func extractZipEntry(entry *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	in, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, entry.Modified, entry.Modified)
+}
+
+// inputSize returns the size of an input file, taken from the archive for -input-zip entries
+// so they need not be extracted.
+func (app *App) inputSize(path string) (int64, error) {
+	if app.zip != nil {
+		if entry, ok := app.zip.entries[path]; ok {
+			return int64(entry.file.UncompressedSize64), nil
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// inputMediaType is classifyMedia for an input file, extracting an -input-zip entry
+// when -sniff-mime needs its content.
+func (app *App) inputMediaType(path string) string {
+	if app.Config.SniffMIME {
+		if release, err := app.acquireInput(path); err == nil {
+			defer release()
+		}
+	}
+	return app.classifyMedia(path)
+}
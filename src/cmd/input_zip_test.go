@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeZip creates a zip archive at path with one entry per name, holding the name as content.
+func writeZip(t *testing.T, path string, modified time.Time, names ...string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, name := range names {
+		entry, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		entry.Write([]byte(name))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to finish zip: %v", err)
+	}
+}
+
+func TestInputZip(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "photos.zip")
+	modified := time.Date(2019, 12, 24, 18, 0, 0, 0, time.UTC)
+	writeZip(t, zipPath, modified, "trip/IMG_0001.jpg", "IMG_0002.jpg", "clip.mp4", "../escape.jpg", "trip/IMG_0001.jpg")
+	outputDir := t.TempDir()
+
+	app := &App{
+		Config: &Config{
+			InputZip:    zipPath,
+			OutputPath:  outputDir,
+			Workers:     2,
+			Buffer:      1,
+			DateSources: []string{sourceExif, sourceMtime},
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+			"IMG_0002.jpg": time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// clip.mp4 has no EXIF date and is filed by the entry's modification time.
+	expected := map[string]string{
+		"2021/07/IMG_0001.jpg": "trip/IMG_0001.jpg",
+		"2022/01/IMG_0002.jpg": "IMG_0002.jpg",
+		"2019/12/clip.mp4":     "clip.mp4",
+	}
+	if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+	if _, err := os.Stat(app.Config.InputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the extracted files in %s to be removed, but got %v", app.Config.InputPath, err)
+	}
+	if _, err := os.Stat(zipPath); err != nil {
+		t.Errorf("Expected the archive to be kept, but got %v", err)
+	}
+}
+
+// extractedCountingExif records the most files extracted from an -input-zip at once
+// while a date is read.
+type extractedCountingExif struct {
+	fakeExif
+	app *App
+	max int
+}
+
+func (e *extractedCountingExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	n := 0
+	filepath.WalkDir(e.app.Config.InputPath, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	e.max = max(e.max, n)
+	return e.fakeExif.ExtractDate(path, debug, useFileModifyDate)
+}
+
+func TestOpenInputZipListsWithoutExtracting(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "photos.zip")
+	writeZip(t, zipPath, time.Date(2019, 12, 24, 18, 0, 0, 0, time.UTC),
+		"IMG_0001.jpg", "@eaDir/IMG_0001.jpg", ".hidden.jpg", "done/.media_organizer_output", "done/IMG_0002.jpg", "b/IMG_0003.jpg")
+	app := &App{Config: &Config{InputZip: zipPath, SkipHidden: true}}
+
+	paths, cleanup, err := app.openInputZip()
+	if err != nil {
+		t.Fatalf("openInputZip failed: %v", err)
+	}
+	defer cleanup()
+	expected := []string{filepath.Join(app.Config.InputPath, "IMG_0001.jpg"), filepath.Join(app.Config.InputPath, "b", "IMG_0003.jpg")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %v, but got %v", expected, paths)
+	}
+	if tree := readTree(t, app.Config.InputPath); len(tree) != 0 {
+		t.Errorf("Expected nothing extracted yet, but got %v", tree)
+	}
+}
+
+func TestInputZipExtractsOneEntryAtATime(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "photos.zip")
+	writeZip(t, zipPath, time.Date(2019, 12, 24, 18, 0, 0, 0, time.UTC),
+		"a/IMG_0001.jpg", "a/IMG_0002.jpg", "b/IMG_0003.jpg", "IMG_0004.jpg", "IMG_0004.xmp")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+
+	for _, deterministic := range []bool{false, true} {
+		outputDir := t.TempDir()
+		app := &App{
+			Config: &Config{
+				InputZip:      zipPath,
+				OutputPath:    outputDir,
+				Workers:       1,
+				Buffer:        1,
+				CopyMode:      true,
+				Deterministic: deterministic,
+				DateSources:   []string{sourceExif, sourceSidecar},
+			},
+		}
+		exif := &extractedCountingExif{app: app, fakeExif: fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": date, "IMG_0002.jpg": date, "IMG_0003.jpg": date, "IMG_0004.xmp": date,
+		}}}
+		app.ExifService = exif
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		// IMG_0004.jpg is read with its sidecar, so two files are out at most.
+		if exif.max == 0 || exif.max > 2 {
+			t.Errorf("Expected 1 or 2 extracted files at a time, but saw %d", exif.max)
+		}
+		expected := map[string]string{
+			"2021/07/IMG_0001.jpg": "a/IMG_0001.jpg",
+			"2021/07/IMG_0002.jpg": "a/IMG_0002.jpg",
+			"2021/07/IMG_0003.jpg": "b/IMG_0003.jpg",
+			"2021/07/IMG_0004.jpg": "IMG_0004.jpg",
+			"2021/07/IMG_0004.xmp": "IMG_0004.xmp",
+		}
+		if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v with deterministic %v, but got %v", expected, deterministic, got)
+		}
+	}
+}
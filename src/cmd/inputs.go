@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// labeledInput is one -i input directory. Files from an input with a label are placed
+// under a top-level folder named after it (e.g. Card-A/2021/07).
+type labeledInput struct {
+	Label string
+	Path  string
+}
+
+// parseInput parses an -i value: a directory, or label=directory.
+// A "=" after a path separator belongs to the directory name, not a label.
+func parseInput(value string) (labeledInput, error) {
+	label, path, found := strings.Cut(value, "=")
+	if !found || strings.ContainsAny(label, `/\`) {
+		return labeledInput{Path: value}, nil
+	}
+	if label == "" || path == "" {
+		return labeledInput{}, fmt.Errorf("invalid input %q: expected label=directory", value)
+	}
+	if sanitizeFolderName(label) != label {
+		return labeledInput{}, fmt.Errorf("invalid input label %q: not usable as a folder name", label)
+	}
+	return labeledInput{Label: label, Path: path}, nil
+}
+
+// inputs returns the input directories of the run: every -i, or the single input path.
+func (app *App) inputs() []labeledInput {
+	if len(app.Config.Inputs) > 0 {
+		return app.Config.Inputs
+	}
+	return []labeledInput{{Path: app.Config.InputPath}}
+}
+
+// inputFor returns the input that path was collected from; with nested inputs, the innermost one.
+func (app *App) inputFor(path string) labeledInput {
+	var best labeledInput
+	for _, in := range app.inputs() {
+		rel, err := filepath.Rel(in.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(in.Path) > len(best.Path) {
+			best = in
+		}
+	}
+	if best.Path == "" {
+		return labeledInput{Path: app.Config.InputPath}
+	}
+	return best
+}
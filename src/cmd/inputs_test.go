@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInput(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected labeledInput
+		hasError bool
+	}{
+		{"/media/card", labeledInput{Path: "/media/card"}, false},
+		{"Card-A=/media/card", labeledInput{Label: "Card-A", Path: "/media/card"}, false},
+		{"/media/x=y", labeledInput{Path: "/media/x=y"}, false},
+		{"=/media/card", labeledInput{}, true},
+		{"Card-A=", labeledInput{}, true},
+		{"Card:A=/media/card", labeledInput{}, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseInput(tc.value)
+		if (err != nil) != tc.hasError {
+			t.Errorf("Expected error %v for %q, but got %v", tc.hasError, tc.value, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("Expected %+v for %q, but got %+v", tc.expected, tc.value, got)
+		}
+	}
+}
+
+func TestLabeledInputs(t *testing.T) {
+	cardA := t.TempDir()
+	cardB := t.TempDir()
+	unlabeled := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, cardA, "DCIM/IMG_0001.jpg")
+	writeFiles(t, cardB, "DCIM/IMG_0002.jpg")
+	writeFiles(t, unlabeled, "IMG_0003.jpg")
+
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{
+			InputPath:  cardA,
+			Inputs:     []labeledInput{{Label: "Card-A", Path: cardA}, {Label: "Card-B", Path: cardB}, {Path: unlabeled}},
+			OutputPath: outputDir, Workers: 2, Buffer: 10, CopyMode: true,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{"IMG_0001.jpg": date, "IMG_0002.jpg": date, "IMG_0003.jpg": date}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{
+		"Card-A/2021/07/IMG_0001.jpg": "DCIM/IMG_0001.jpg",
+		"Card-B/2021/07/IMG_0002.jpg": "DCIM/IMG_0002.jpg",
+		"2021/07/IMG_0003.jpg":        "IMG_0003.jpg",
+	}
+	if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestInputForNested(t *testing.T) {
+	app := &App{Config: &Config{Inputs: []labeledInput{{Label: "All", Path: "/media"}, {Label: "Phone", Path: "/media/phone"}}}}
+
+	if got := app.inputFor(filepath.FromSlash("/media/phone/a.jpg")).Label; got != "Phone" {
+		t.Errorf("Expected the innermost input, but got %q", got)
+	}
+	if got := app.inputFor(filepath.FromSlash("/media/card/a.jpg")).Label; got != "All" {
+		t.Errorf("Expected the enclosing input, but got %q", got)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"media_organizer/src/internal"
+)
+
+// rootAlbum is the album prefix for files directly in the input root.
+const rootAlbum = "_root"
+
+// treePrefix returns the first path component of path below the input root,
+// or rootAlbum when the file sits directly in the root.
+func treePrefix(inputRoot, path string) string {
+	rel, err := filepath.Rel(inputRoot, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return rootAlbum
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 2 {
+		return rootAlbum
+	}
+	return parts[0]
+}
+
+// albumDirs returns Artist/Album folders from audio tags.
+// It reports false when neither tag is present so the caller can fall back to the date layout.
+func albumDirs(fields map[string]interface{}) ([]string, bool) {
+	artist := sanitizeFolderName(fieldString(fields, "Artist"))
+	album := sanitizeFolderName(fieldString(fields, "Album"))
+	if artist == "" && album == "" {
+		return nil, false
+	}
+	if artist == "" {
+		artist = "Unknown-Artist"
+	}
+	if album == "" {
+		album = "Unknown-Album"
+	}
+	return []string{artist, album}, true
+}
+
+// lensTags lists the fields that may carry the lens name, most specific first.
+var lensTags = []string{"LensModel", "LensID", "Lens"}
+
+// lensFolder returns a sanitized lens folder name, or "Unknown-Lens" if no lens field is set.
+func lensFolder(fields map[string]interface{}) string {
+	for _, tag := range lensTags {
+		if lens := sanitizeFolderName(fieldString(fields, tag)); lens != "" {
+			return lens
+		}
+	}
+	return "Unknown-Lens"
+}
+
+// softwareFamilies maps a lower-case substring of the Software field to a folder,
+// so every version of an editor lands in one folder.
+var softwareFamilies = []struct {
+	match  string
+	folder string
+}{
+	// Lightroom reports itself as "Adobe Photoshop Lightroom", so it is checked first.
+	{"lightroom", "Adobe-Lightroom"},
+	{"photoshop", "Adobe-Photoshop"},
+	{"gimp", "GIMP"},
+	{"snapseed", "Snapseed"},
+	{"picasa", "Picasa"},
+	{"affinity photo", "Affinity-Photo"},
+	{"capture one", "Capture-One"},
+	{"darktable", "darktable"},
+	{"firmware", "Camera"},
+}
+
+// cameraFirmwarePattern matches the bare version strings cameras and phones write,
+// such as "1.0", "Ver.1.01" or "16.1.2".
+var cameraFirmwarePattern = regexp.MustCompile(`(?i)^(ver(sion)?\.?\s*)?v?\d+(\.\d+)*[a-z]?$`)
+
+// softwareFolder returns the folder for a file's Software field: a known editor family,
+// "Camera" for in-camera firmware versions, the sanitized value otherwise, or
+// "Unknown-Software" if the field is not set.
+func softwareFolder(fields map[string]interface{}) string {
+	software := fieldString(fields, "Software")
+	if software == "" {
+		return "Unknown-Software"
+	}
+	lower := strings.ToLower(software)
+	for _, family := range softwareFamilies {
+		if strings.Contains(lower, family.match) {
+			return family.folder
+		}
+	}
+	if cameraFirmwarePattern.MatchString(software) {
+		return "Camera"
+	}
+	if folder := sanitizeFolderName(software); folder != "" {
+		return folder
+	}
+	return "Unknown-Software"
+}
+
+// orientationFolder returns Landscape, Portrait or Square for an image as it is displayed,
+// swapping the stored width and height when the Orientation tag rotates it by 90 or 270 degrees.
+// Images without dimensions go to "Unknown-Orientation".
+func orientationFolder(fields map[string]interface{}) string {
+	width, errW := strconv.ParseFloat(fieldString(fields, "ImageWidth"), 64)
+	height, errH := strconv.ParseFloat(fieldString(fields, "ImageHeight"), 64)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return "Unknown-Orientation"
+	}
+	if rotatedQuarterTurn(fieldString(fields, "Orientation")) {
+		width, height = height, width
+	}
+	switch {
+	case width > height:
+		return "Landscape"
+	case height > width:
+		return "Portrait"
+	}
+	return "Square"
+}
+
+// rotatedQuarterTurn reports whether an EXIF Orientation value, either numeric (5-8) or as
+// exiftool prints it ("Rotate 90 CW"), turns the image on its side.
+func rotatedQuarterTurn(orientation string) bool {
+	if n, err := strconv.Atoi(orientation); err == nil {
+		return n >= 5 && n <= 8
+	}
+	return strings.Contains(orientation, "90") || strings.Contains(orientation, "270")
+}
+
+// facesFolder returns People for a photo with face regions and Other for the rest. Faces are
+// recognised from MWG region types ("Face"), Microsoft people tags and the face count some
+// cameras record; nothing is detected from the pixels.
+func facesFolder(fields map[string]interface{}) string {
+	if hasFaceRegion(fields["RegionType"]) || fieldString(fields, "RegionPersonDisplayName") != "" {
+		return "People"
+	}
+	if n, err := strconv.Atoi(fieldString(fields, "FacesDetected")); err == nil && n > 0 {
+		return "People"
+	}
+	return "Other"
+}
+
+// hasFaceRegion reports whether a RegionType value, a single type or one per region, includes Face.
+func hasFaceRegion(regionType interface{}) bool {
+	switch v := regionType.(type) {
+	case string:
+		return strings.EqualFold(strings.TrimSpace(v), "Face")
+	case []interface{}:
+		for _, t := range v {
+			if hasFaceRegion(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeFolders maps media types to the -type-subfolder folder names.
+var typeFolders = map[string]string{
+	internal.MediaImage:    "Photos",
+	internal.MediaVideo:    "Videos",
+	internal.MediaAudio:    "Audio",
+	internal.MediaDocument: "Documents",
+}
+
+// typeFolder returns the category folder for a media type, or "Other" for unknown types.
+func typeFolder(mediaType string) string {
+	if folder, ok := typeFolders[mediaType]; ok {
+		return folder
+	}
+	return "Other"
+}
+
+// Positions of the -by-extension folder relative to the date folders.
+const (
+	extensionFirst = "first"
+	extensionLast  = "last"
+)
+
+// noExtensionFolder is the -by-extension folder for files without an extension.
+const noExtensionFolder = "no-extension"
+
+// extensionFolder returns the -by-extension folder for a file: its lowercased extension, such as jpg.
+func extensionFolder(path string) string {
+	ext := sanitizeFolderName(strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")))
+	if ext == "" {
+		return noExtensionFolder
+	}
+	return ext
+}
+
+// fieldString returns a metadata field as a trimmed string, or "" if it is missing.
+func fieldString(fields map[string]interface{}, name string) string {
+	val, ok := fields[name]
+	if !ok || val == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(val))
+}
+
+// sanitizeFolderName turns a metadata value into a safe single folder name.
+// Path separators and characters reserved on common filesystems are replaced with '-'.
+func sanitizeFolderName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '-'
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, " .")
+}
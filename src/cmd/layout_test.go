@@ -0,0 +1,323 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlbumDirs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "Tagged MP3",
+			fields:   map[string]interface{}{"FileType": "MP3", "Artist": "Miles Davis", "Album": "Kind of Blue", "Title": "So What"},
+			expected: "Miles Davis/Kind of Blue",
+			ok:       true,
+		},
+		{
+			name:     "Artist only",
+			fields:   map[string]interface{}{"Artist": "AC/DC"},
+			expected: "AC-DC/Unknown-Album",
+			ok:       true,
+		},
+		{
+			name:   "No tags",
+			fields: map[string]interface{}{"FileType": "M4A"},
+			ok:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dirs, ok := albumDirs(tc.fields)
+			if ok != tc.ok {
+				t.Fatalf("Expected ok %v, but got %v", tc.ok, ok)
+			}
+			if got := strings.Join(dirs, "/"); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeFolderName(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Kind of Blue", "Kind of Blue"},
+		{"AC/DC", "AC-DC"},
+		{`What? "Live" <2003>`, `What- -Live- -2003-`},
+		{"  Trailing dots... ", "Trailing dots"},
+		{"..", ""},
+		{"Tab\tSeparated", "Tab Separated"},
+	}
+
+	for _, tc := range testCases {
+		if got := sanitizeFolderName(tc.input); got != tc.expected {
+			t.Errorf("Expected %q for %q, but got %q", tc.expected, tc.input, got)
+		}
+	}
+}
+
+func TestLensFolder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected string
+	}{
+		{"LensModel", map[string]interface{}{"LensModel": "EF24-70mm f/2.8L II USM", "LensID": "Canon EF 24-70mm"}, "EF24-70mm f-2.8L II USM"},
+		{"LensID only", map[string]interface{}{"LensID": "AF-S Nikkor 50mm f/1.8G"}, "AF-S Nikkor 50mm f-1.8G"},
+		{"Empty LensModel", map[string]interface{}{"LensModel": " ", "LensID": "XF35mmF1.4 R"}, "XF35mmF1.4 R"},
+		{"No lens fields", map[string]interface{}{"Model": "iPhone 12"}, "Unknown-Lens"},
+		{"No metadata", nil, "Unknown-Lens"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := lensFolder(tc.fields); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTreePrefix(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/input/IMG_0001.jpg", "_root"},
+		{"/input/Trip to Rome/IMG_0001.jpg", "Trip to Rome"},
+		{"/input/Trip to Rome/Day 2/IMG_0002.jpg", "Trip to Rome"},
+		{"/elsewhere/IMG_0003.jpg", "_root"},
+	}
+
+	for _, tc := range testCases {
+		if got := treePrefix("/input", tc.path); got != tc.expected {
+			t.Errorf("Expected prefix %v for %s, but got %v", tc.expected, tc.path, got)
+		}
+	}
+}
+
+func TestResolveDirsPreserveTreeUnder(t *testing.T) {
+	app := &App{
+		Config: &Config{InputPath: "/input", PreserveTreeUnder: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	dirs, _, err := app.resolveDirs("/input/AlbumName/sub/IMG_0001.jpg")
+	if err != nil {
+		t.Fatalf("resolveDirs failed: %v", err)
+	}
+	if got := strings.Join(dirs, "/"); got != "AlbumName/2021/07" {
+		t.Errorf("Expected AlbumName/2021/07, but got %v", got)
+	}
+}
+
+func TestResolveDirsTypeSubfolder(t *testing.T) {
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config:      &Config{TypeSubfolder: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{"IMG_0001.jpg": date, "VID_0001.mp4": date, "notes.txt": date}},
+	}
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/input/IMG_0001.jpg", "2021/07/Photos"},
+		{"/input/VID_0001.mp4", "2021/07/Videos"},
+		{"/input/notes.txt", "2021/07/Other"},
+	}
+	for _, tc := range testCases {
+		dirs, _, err := app.resolveDirs(tc.path)
+		if err != nil {
+			t.Fatalf("resolveDirs failed for %s: %v", tc.path, err)
+		}
+		if got := strings.Join(dirs, "/"); got != tc.expected {
+			t.Errorf("Expected %v for %s, but got %v", tc.expected, tc.path, got)
+		}
+	}
+}
+
+func TestSoftwareFolder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		software interface{}
+		expected string
+	}{
+		{"Photoshop", "Adobe Photoshop CC 2019 (Windows)", "Adobe-Photoshop"},
+		{"Lightroom", "Adobe Photoshop Lightroom Classic 12.0 (Macintosh)", "Adobe-Lightroom"},
+		{"Camera version", "Ver.1.01", "Camera"},
+		{"Phone version", "16.1.2", "Camera"},
+		{"Camera firmware", "Firmware Version 1.1", "Camera"},
+		{"Other software", "Google Photos: edit", "Google Photos- edit"},
+		{"Numeric value", 1.1, "Camera"},
+		{"Missing", nil, "Unknown-Software"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := map[string]interface{}{}
+			if tc.software != nil {
+				fields["Software"] = tc.software
+			}
+			if got := softwareFolder(fields); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveDirsBySoftware(t *testing.T) {
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{BySoftware: true},
+		ExifService: &fakeExif{
+			dates: map[string]time.Time{"edited.jpg": date, "original.jpg": date},
+			fields: map[string]map[string]interface{}{
+				"edited.jpg":   {"Software": "Adobe Photoshop 24.0 (Macintosh)"},
+				"original.jpg": {"Software": "Ver.2.00"},
+			},
+		},
+	}
+
+	for file, expected := range map[string]string{"edited.jpg": "Adobe-Photoshop/2021/07", "original.jpg": "Camera/2021/07"} {
+		dirs, _, err := app.resolveDirs("/input/" + file)
+		if err != nil {
+			t.Fatalf("resolveDirs failed for %s: %v", file, err)
+		}
+		if got := strings.Join(dirs, "/"); got != expected {
+			t.Errorf("Expected %v for %s, but got %v", expected, file, got)
+		}
+	}
+}
+
+func TestOrientationFolder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected string
+	}{
+		{"Landscape", map[string]interface{}{"ImageWidth": 4032.0, "ImageHeight": 3024.0, "Orientation": "Horizontal (normal)"}, "Landscape"},
+		{"Rotated portrait", map[string]interface{}{"ImageWidth": 4032.0, "ImageHeight": 3024.0, "Orientation": "Rotate 90 CW"}, "Portrait"},
+		{"Mirrored and rotated", map[string]interface{}{"ImageWidth": 4032.0, "ImageHeight": 3024.0, "Orientation": "Mirror horizontal and rotate 270 CW"}, "Portrait"},
+		{"Numeric orientation", map[string]interface{}{"ImageWidth": 3024.0, "ImageHeight": 4032.0, "Orientation": 6.0}, "Landscape"},
+		{"Upside down portrait", map[string]interface{}{"ImageWidth": 3024.0, "ImageHeight": 4032.0, "Orientation": "Rotate 180"}, "Portrait"},
+		{"Square", map[string]interface{}{"ImageWidth": 1080.0, "ImageHeight": 1080.0, "Orientation": "Rotate 90 CW"}, "Square"},
+		{"No dimensions", map[string]interface{}{"Orientation": "Rotate 90 CW"}, "Unknown-Orientation"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := orientationFolder(tc.fields); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveDirsByOrientation(t *testing.T) {
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{ByOrientation: true, TypeSubfolder: true},
+		ExifService: &fakeExif{
+			dates: map[string]time.Time{"IMG_0001.jpg": date},
+			fields: map[string]map[string]interface{}{
+				"IMG_0001.jpg": {"ImageWidth": 4032.0, "ImageHeight": 3024.0, "Orientation": "Rotate 270 CW"},
+			},
+		},
+	}
+
+	dirs, _, err := app.resolveDirs("/input/IMG_0001.jpg")
+	if err != nil {
+		t.Fatalf("resolveDirs failed: %v", err)
+	}
+	if got := strings.Join(dirs, "/"); got != "2021/07/Portrait/Photos" {
+		t.Errorf("Expected 2021/07/Portrait/Photos, but got %v", got)
+	}
+}
+
+func TestFacesFolder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected string
+	}{
+		{"MWG face region", map[string]interface{}{"RegionAppliedToDimensionsW": 4032.0, "RegionType": "Face", "RegionName": "Ann"}, "People"},
+		{"Several regions", map[string]interface{}{"RegionType": []interface{}{"Pet", "Face"}}, "People"},
+		{"Microsoft people tag", map[string]interface{}{"RegionPersonDisplayName": "Ann"}, "People"},
+		{"Camera face count", map[string]interface{}{"FacesDetected": 2.0}, "People"},
+		{"No faces counted", map[string]interface{}{"FacesDetected": 0.0}, "Other"},
+		{"Pet region only", map[string]interface{}{"RegionType": "Pet"}, "Other"},
+		{"No region fields", map[string]interface{}{"Model": "iPhone 12"}, "Other"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := facesFolder(tc.fields); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveDirsByFaces(t *testing.T) {
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{ByFaces: true},
+		ExifService: &fakeExif{
+			dates: map[string]time.Time{"portrait.jpg": date, "beach.jpg": date},
+			fields: map[string]map[string]interface{}{
+				"portrait.jpg": {"RegionAppliedToDimensionsW": 4032.0, "RegionAppliedToDimensionsH": 3024.0, "RegionType": "Face"},
+				"beach.jpg":    {"ImageWidth": 4032.0, "ImageHeight": 3024.0},
+			},
+		},
+	}
+
+	for file, expected := range map[string]string{"portrait.jpg": "2021/07/People", "beach.jpg": "2021/07/Other"} {
+		dirs, _, err := app.resolveDirs("/input/" + file)
+		if err != nil {
+			t.Fatalf("resolveDirs failed for %s: %v", file, err)
+		}
+		if got := strings.Join(dirs, "/"); got != expected {
+			t.Errorf("Expected %v for %s, but got %v", expected, file, got)
+		}
+	}
+}
+
+func TestResolveDirsByExtension(t *testing.T) {
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{"IMG_0001.JPG": date, "VID_0001.mov": date, "README": date}}
+
+	testCases := []struct {
+		position string
+		path     string
+		expected string
+	}{
+		{extensionFirst, "/input/IMG_0001.JPG", "jpg/2021/07"},
+		{extensionFirst, "/input/VID_0001.mov", "mov/2021/07"},
+		{extensionFirst, "/input/README", "no-extension/2021/07"},
+		{extensionLast, "/input/IMG_0001.JPG", "2021/07/jpg"},
+		{extensionLast, "/input/VID_0001.mov", "2021/07/mov"},
+		{"", "/input/VID_0001.mov", "2021/07"},
+	}
+	for _, tc := range testCases {
+		app := &App{Config: &Config{ByExtension: tc.position}, ExifService: exif}
+		dirs, _, err := app.resolveDirs(tc.path)
+		if err != nil {
+			t.Fatalf("resolveDirs failed for %s: %v", tc.path, err)
+		}
+		if got := strings.Join(dirs, "/"); got != tc.expected {
+			t.Errorf("Expected %v for %s with position %q, but got %v", tc.expected, tc.path, tc.position, got)
+		}
+	}
+}
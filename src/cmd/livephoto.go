@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// livePhotoDir is the hidden folder, next to the still, that receives Live Photo videos.
+const livePhotoDir = ".livephotos"
+
+// Extensions of the two halves of a Live Photo. Stills are ranked: when a folder holds both
+// IMG_0001.HEIC and IMG_0001.JPG, the HEIC original is paired with the video, not its export.
+var (
+	livePhotoStillExts = map[string]int{".heic": 2, ".jpg": 1, ".jpeg": 1}
+	livePhotoVideoExts = map[string]bool{".mov": true}
+)
+
+// livePhotoPairs links Live Photo videos to their stills.
+type livePhotoPairs struct {
+	// videos maps each video to the still with the same name in the same folder.
+	videos map[string]string
+	// stills holds the destination of each paired still, resolved once so the video
+	// can follow the still even after the still has been moved.
+	stills map[string]*resolvedDirs
+}
+
+// resolvedDirs is the memoized result of resolving a file's destination.
+type resolvedDirs struct {
+	once sync.Once
+	dirs []string
+	t    time.Time
+	err  error
+}
+
+// findLivePhotoPairs pairs every video with a still of the same name in the same folder,
+// e.g. IMG_0001.HEIC and IMG_0001.MOV. Names are compared without case, but folders must match
+// exactly, since they may differ only by case on a case-sensitive file system. Among several
+// stills for one video, the highest ranked wins, then the first path in lexical order.
+func findLivePhotoPairs(paths []string) livePhotoPairs {
+	key := func(path string) string {
+		name := filepath.Base(path)
+		return filepath.Join(filepath.Dir(path), strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))))
+	}
+
+	stills := make(map[string]string)
+	for _, path := range paths {
+		rank := livePhotoStillExts[strings.ToLower(filepath.Ext(path))]
+		if rank == 0 {
+			continue
+		}
+		k := key(path)
+		if other, ok := stills[k]; ok {
+			otherRank := livePhotoStillExts[strings.ToLower(filepath.Ext(other))]
+			if rank < otherRank || (rank == otherRank && path > other) {
+				continue
+			}
+		}
+		stills[k] = path
+	}
+
+	pairs := livePhotoPairs{videos: make(map[string]string), stills: make(map[string]*resolvedDirs)}
+	for _, path := range paths {
+		if !livePhotoVideoExts[strings.ToLower(filepath.Ext(path))] {
+			continue
+		}
+		if still, ok := stills[key(path)]; ok {
+			pairs.videos[path] = still
+			pairs.stills[still] = &resolvedDirs{}
+		}
+	}
+	return pairs
+}
+
+// resolveStill resolves the destination of a paired still exactly once.
+func (app *App) resolveStill(still string) ([]string, time.Time, error) {
+	r := app.livePhotos.stills[still]
+	r.once.Do(func() {
+		r.dirs, r.t, r.err = app.resolveOwnDirs(still)
+	})
+	return r.dirs, r.t, r.err
+}
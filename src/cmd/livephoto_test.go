@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindLivePhotoPairs(t *testing.T) {
+	pairs := findLivePhotoPairs([]string{
+		"/in/IMG_0001.HEIC", "/in/IMG_0001.MOV",
+		"/in/IMG_0002.jpg", "/in/img_0002.mov",
+		"/in/clip.mov",
+		"/in/other/IMG_0001.MOV",
+		"/in/A/IMG_0003.HEIC", "/in/a/IMG_0003.MOV",
+	})
+
+	expected := map[string]string{
+		"/in/IMG_0001.MOV": "/in/IMG_0001.HEIC",
+		"/in/img_0002.mov": "/in/IMG_0002.jpg",
+	}
+	if !reflect.DeepEqual(pairs.videos, expected) {
+		t.Errorf("Expected pairs %v, but got %v", expected, pairs.videos)
+	}
+	if len(pairs.stills) != 2 {
+		t.Errorf("Expected 2 paired stills, but got %d", len(pairs.stills))
+	}
+}
+
+func TestFindLivePhotoPairsPrefersHEIC(t *testing.T) {
+	// The video pairs with the HEIC original whatever order the stills are walked in.
+	orders := [][]string{
+		{"/in/IMG_0001.HEIC", "/in/IMG_0001.JPG", "/in/IMG_0001.MOV", "/in/IMG_0002.jpeg", "/in/IMG_0002.jpg", "/in/IMG_0002.MOV"},
+		{"/in/IMG_0002.MOV", "/in/IMG_0002.jpg", "/in/IMG_0002.jpeg", "/in/IMG_0001.MOV", "/in/IMG_0001.JPG", "/in/IMG_0001.HEIC"},
+	}
+	expected := map[string]string{
+		"/in/IMG_0001.MOV": "/in/IMG_0001.HEIC",
+		"/in/IMG_0002.MOV": "/in/IMG_0002.jpeg",
+	}
+	for _, paths := range orders {
+		if got := findLivePhotoPairs(paths).videos; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected pairs %v for %v, but got %v", expected, paths, got)
+		}
+	}
+}
+
+func TestLivePhotoSubfolder(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.HEIC", "IMG_0001.MOV", "clip.mov")
+
+	// The video's own date falls in the next month; it must still follow its still.
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 4, Buffer: 1, LivePhotoSubfolder: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.HEIC": time.Date(2021, 7, 31, 23, 59, 59, 0, time.UTC),
+			"IMG_0001.MOV":  time.Date(2021, 8, 1, 0, 0, 1, 0, time.UTC),
+			"clip.mov":      time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	app.Run()
+
+	expected := map[string]string{
+		"2021/07/IMG_0001.HEIC":            "IMG_0001.HEIC",
+		"2021/07/.livephotos/IMG_0001.MOV": "IMG_0001.MOV",
+		"2021/08/clip.mov":                 "clip.mov",
+	}
+	if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hashFile returns the hex SHA-256 digest of a file's content.
+func (app *App) hashFile(path string) (string, error) {
+	defer app.acquireFiles(1)()
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Manifest records a SHA256SUMS line for every placed file. Lines are appended as files
+// are placed and sorted by path when the manifest is closed, so it can be checked with
+// `sha256sum -c` from the output root.
+type Manifest struct {
+	out *SafeWriter
+}
+
+// NewManifest creates the manifest file at path.
+func NewManifest(path string, checkpointEvery int) (*Manifest, error) {
+	out, err := CreateSafeWriter(path, 0644, checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{out: out}, nil
+}
+
+// Add appends the hash of a file at relPath, relative to the output root.
+func (m *Manifest) Add(hash, relPath string) error {
+	return m.out.WriteLine(hash + "  " + filepath.ToSlash(relPath))
+}
+
+// Close sorts the manifest by path and closes it.
+func (m *Manifest) Close() error {
+	if err := m.out.Close(); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(m.out.Name())
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return manifestPath(lines[i]) < manifestPath(lines[j])
+	})
+	return os.WriteFile(m.out.Name(), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// manifestPath returns the path part of a manifest line.
+func manifestPath(line string) string {
+	if _, path, ok := strings.Cut(line, "  "); ok {
+		return path
+	}
+	return line
+}
+
+// relativeToRoot returns target relative to the output root that contains it.
+func (app *App) relativeToRoot(target string) string {
+	for _, root := range app.Config.localRoots() {
+		if rel, err := filepath.Rel(root, target); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return target
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestOut(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "b.jpg", "a.jpg", "c.mp4")
+
+	manifestPath := filepath.Join(t.TempDir(), "SHA256SUMS")
+	manifest, err := NewManifest(manifestPath, 1)
+	if err != nil {
+		t.Fatalf("NewManifest failed: %v", err)
+	}
+
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 3, Buffer: 1, CopyMode: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"a.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+			"b.jpg": time.Date(2021, 7, 5, 10, 0, 0, 0, time.UTC),
+			"c.mp4": time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC),
+		}},
+		Manifest: manifest,
+	}
+	app.Run()
+	if err := manifest.Close(); err != nil {
+		t.Fatalf("Failed to close manifest: %v", err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+
+	sum := func(content string) string {
+		h := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(h[:])
+	}
+	expected := sum("c.mp4") + "  2020/01/c.mp4\n" +
+		sum("a.jpg") + "  2021/07/a.jpg\n" +
+		sum("b.jpg") + "  2021/07/b.jpg\n"
+	if string(data) != expected {
+		t.Errorf("Expected manifest:\n%s\nbut got:\n%s", expected, string(data))
+	}
+}
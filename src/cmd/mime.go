@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"media_organizer/src/internal"
+)
+
+// sniffLen is how much of a file detectMIME reads, as http.DetectContentType considers.
+const sniffLen = 512
+
+// isobmffBrands maps ftyp major brands that http.DetectContentType does not know to MIME types.
+var isobmffBrands = map[string]string{
+	"heic": "image/heic", "heix": "image/heic", "mif1": "image/heif", "msf1": "image/heif",
+	"avif": "image/avif", "qt  ": "video/quicktime", "3gp4": "video/3gpp", "3gp5": "video/3gpp",
+	"M4A ": "audio/mp4", "M4V ": "video/mp4", "crx ": "image/x-canon-cr3",
+}
+
+// detectMIME returns the MIME type of a file from its leading bytes, regardless of its extension.
+// Unrecognized content is reported as application/octet-stream.
+func detectMIME(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	if len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")) {
+		if mime, ok := isobmffBrands[string(head[8:12])]; ok {
+			return mime, nil
+		}
+	}
+	mime := http.DetectContentType(head)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return mime, nil
+}
+
+// mediaTypeOfMIME maps a MIME type to a media type, or "" if it does not identify one.
+func mediaTypeOfMIME(mime string) string {
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return internal.MediaImage
+	case strings.HasPrefix(mime, "video/"):
+		return internal.MediaVideo
+	case strings.HasPrefix(mime, "audio/"), mime == "application/ogg":
+		return internal.MediaAudio
+	case mime == "application/pdf":
+		return internal.MediaDocument
+	}
+	return ""
+}
+
+// classifyMedia returns the media type of a file. With -sniff-mime the file content decides
+// when it is recognized; otherwise, and for unrecognized content, the extension does.
+func (app *App) classifyMedia(path string) string {
+	byExtension := internal.ClassifyMedia(path)
+	if !app.Config.SniffMIME {
+		return byExtension
+	}
+	mime, err := detectMIME(path)
+	if err != nil {
+		logrus.Warnf("Cannot sniff the type of %s, using its extension: %v", path, err)
+		return byExtension
+	}
+	sniffed := mediaTypeOfMIME(mime)
+	if sniffed == "" {
+		return byExtension
+	}
+	if sniffed != byExtension {
+		logrus.Debugf("%s is %s by content, not %s as its extension says", path, mime, byExtension)
+	}
+	return sniffed
+}
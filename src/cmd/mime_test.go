@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	pngHeader  = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	jpegHeader = "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+	mp4Header  = "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"
+	heicHeader = "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"
+)
+
+func TestDetectMIME(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"really_png.jpg", pngHeader, "image/png"},
+		{"photo.jpg", jpegHeader, "image/jpeg"},
+		{"clip.dat", mp4Header, "video/mp4"},
+		{"IMG_0001", heicHeader, "image/heic"},
+		{"notes.jpg", "just some text", "text/plain"},
+		{"empty.jpg", "", "text/plain"},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join(dir, tc.name)
+		if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", tc.name, err)
+		}
+		got, err := detectMIME(path)
+		if err != nil {
+			t.Fatalf("detectMIME failed for %s: %v", tc.name, err)
+		}
+		if got != tc.expected {
+			t.Errorf("Expected %v for %s, but got %v", tc.expected, tc.name, got)
+		}
+	}
+
+	if _, err := detectMIME(filepath.Join(dir, "missing.jpg")); err == nil {
+		t.Errorf("Expected an error for a missing file, but got nil")
+	}
+}
+
+func TestSniffMIMETypeSubfolder(t *testing.T) {
+	inputDir := t.TempDir()
+	files := map[string]string{
+		"photo.jpg":  jpegHeader,
+		"clip.jpg":   mp4Header,
+		"scan.bin":   pngHeader,
+		"readme.mp4": "not a video",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	dates := make(map[string]time.Time)
+	for name := range files {
+		dates[name] = date
+	}
+	expected := map[bool][]string{
+		false: {"2021/07/Photos/photo.jpg", "2021/07/Photos/clip.jpg", "2021/07/Other/scan.bin", "2021/07/Videos/readme.mp4"},
+		// readme.mp4 is unrecognized content, so its extension still decides.
+		true: {"2021/07/Photos/photo.jpg", "2021/07/Videos/clip.jpg", "2021/07/Photos/scan.bin", "2021/07/Videos/readme.mp4"},
+	}
+
+	for _, sniff := range []bool{false, true} {
+		outputDir := t.TempDir()
+		app := &App{
+			Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 1, CopyMode: true, TypeSubfolder: true, SniffMIME: sniff},
+			ExifService: &fakeExif{dates: dates},
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		tree := readTree(t, outputDir)
+		for _, path := range expected[sniff] {
+			if _, ok := tree[path]; !ok {
+				t.Errorf("Expected %s with -sniff-mime=%v, but got %v", path, sniff, tree)
+			}
+		}
+	}
+}
+
+func TestSniffMIMEMediaType(t *testing.T) {
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{"clip.jpg": mp4Header, "photo.jpg": jpegHeader} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	app := &App{Config: &Config{InputPath: inputDir, MediaType: "video", SniffMIME: true}}
+	paths, _ := app.collectFiles()
+	if len(paths) != 1 || !strings.HasSuffix(paths[0], "clip.jpg") {
+		t.Errorf("Expected only clip.jpg to be a video, but got %v", paths)
+	}
+}
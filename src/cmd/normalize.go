@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// parseNormalization parses a -normalize-unicode value: nfc, nfd, or empty to keep names as they are.
+func parseNormalization(value string) (string, error) {
+	switch v := strings.ToLower(value); v {
+	case "", "nfc", "nfd":
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid -normalize-unicode %q: expected nfc or nfd", value)
+}
+
+// targetName returns the base name a file is placed under. With -normalize-unicode, names that
+// differ only in their Unicode composition (macOS writes NFD, most other systems NFC) become
+// byte-identical, so they collide and are deduplicated like any other same-named files.
+func (app *App) targetName(path string) string {
+	name := filepath.Base(path)
+	switch app.Config.NormalizeUnicode {
+	case "nfc":
+		return norm.NFC.String(name)
+	case "nfd":
+		return norm.NFD.String(name)
+	}
+	return name
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestNormalizeUnicode(t *testing.T) {
+	nfc := norm.NFC.String("café.jpg")
+	nfd := norm.NFD.String("café.jpg")
+	if nfc == nfd {
+		t.Fatalf("Expected the NFC and NFD names to differ in bytes")
+	}
+
+	testCases := []struct {
+		form     string
+		expected []string
+	}{
+		{"", []string{"2021/07/" + nfc, "2021/07/" + nfd}},
+		{"nfc", []string{"2021/07/" + nfc, "2021/07/" + norm.NFC.String("café-1.jpg")}},
+		{"nfd", []string{"2021/07/" + nfd, "2021/07/" + norm.NFD.String("café-1.jpg")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run("form "+tc.form, func(t *testing.T) {
+			inputDir := t.TempDir()
+			outputDir := t.TempDir()
+			writeFiles(t, inputDir, "mac/"+nfd, "linux/"+nfc)
+			date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+
+			app := &App{
+				Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 10, CopyMode: true, NormalizeUnicode: tc.form},
+				ExifService: &fakeExif{dates: map[string]time.Time{nfc: date, nfd: date}},
+			}
+			if err := app.Run(); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+
+			got := readTree(t, outputDir)
+			if len(got) != len(tc.expected) {
+				t.Fatalf("Expected %q, but got %q", tc.expected, got)
+			}
+			for _, name := range tc.expected {
+				if _, ok := got[name]; !ok {
+					t.Errorf("Expected %q in the output, but got %q", name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseNormalization(t *testing.T) {
+	for value, expected := range map[string]string{"": "", "nfc": "nfc", "NFD": "nfd"} {
+		if got, err := parseNormalization(value); err != nil || got != expected {
+			t.Errorf("Expected %q for %q, but got %q (%v)", expected, value, got, err)
+		}
+	}
+	if _, err := parseNormalization("nfkc"); err == nil {
+		t.Errorf("Expected an error for nfkc")
+	}
+}
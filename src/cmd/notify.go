@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds the completion notification so it cannot hang the run.
+const webhookTimeout = 10 * time.Second
+
+// Run statuses reported in runSummary.
+const (
+	statusCompleted = "completed"
+	statusAborted   = "aborted"
+)
+
+// runSummary is the final statistics document sent to -notify-webhook.
+type runSummary struct {
+	// Status is completed, or aborted when -fail-fast, -run-timeout or another stop ended the
+	// run early; Error then holds the cause.
+	Status         string  `json:"status"`
+	Error          string  `json:"error,omitempty"`
+	Total          int     `json:"total"`
+	Processed      int64   `json:"processed"`
+	Failed         int64   `json:"failed"`
+	Skipped        int64   `json:"skipped"`
+	Elapsed        string  `json:"elapsed"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	DryRun         bool    `json:"dryRun"`
+	// SlowFiles lists the files over -max-runtime-per-file, slowest first.
+	SlowFiles []slowFile `json:"slowFiles,omitempty"`
+}
+
+// summary builds the run summary from the collected statistics.
+func (app *App) summary(total int, elapsed time.Duration) runSummary {
+	summary := runSummary{
+		Status:         statusCompleted,
+		Total:          total,
+		Processed:      app.stats.processed.Load(),
+		Failed:         app.stats.failed.Load(),
+		Skipped:        app.stats.skipped.Load(),
+		Elapsed:        elapsed.String(),
+		ElapsedSeconds: elapsed.Seconds(),
+		DryRun:         app.Config.DryRun,
+		SlowFiles:      app.slowest(),
+	}
+	if err := app.stopErr(); err != nil {
+		summary.Status = statusAborted
+		summary.Error = err.Error()
+	}
+	return summary
+}
+
+// postWebhook POSTs the summary as JSON to url.
+func postWebhook(url string, summary runSummary, timeout time.Duration) error {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
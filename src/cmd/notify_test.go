@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyWebhook(t *testing.T) {
+	received := make(chan runSummary, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON POST, but got %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var summary runSummary
+		if err := json.NewDecoder(r.Body).Decode(&summary); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		received <- summary
+	}))
+	defer server.Close()
+
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.jpg", "IMG_0002.jpg", "undated.jpg")
+
+	app := &App{
+		Config: &Config{
+			InputPath:     inputDir,
+			OutputPath:    t.TempDir(),
+			Workers:       2,
+			Buffer:        1,
+			CopyMode:      true,
+			NotifyWebhook: server.URL,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+			"IMG_0002.jpg": time.Date(2021, 7, 5, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	app.Run()
+
+	select {
+	case summary := <-received:
+		if summary.Total != 3 || summary.Processed != 2 || summary.Failed != 1 {
+			t.Errorf("Expected 3 total, 2 processed, 1 failed, but got %+v", summary)
+		}
+		if summary.Elapsed == "" {
+			t.Errorf("Expected elapsed time in payload, but got %+v", summary)
+		}
+		if summary.Status != statusCompleted || summary.Error != "" {
+			t.Errorf("Expected a completed run without error, but got %+v", summary)
+		}
+	default:
+		t.Fatal("Expected webhook to be called")
+	}
+}
+
+func TestNotifyWebhookOnAbort(t *testing.T) {
+	received := make(chan runSummary, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var summary runSummary
+		if err := json.NewDecoder(r.Body).Decode(&summary); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		received <- summary
+	}))
+	defer server.Close()
+
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a_undated.jpg", "b.jpg", "c.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+
+	for _, notifyOnAbort := range []bool{false, true} {
+		app := &App{
+			Config: &Config{
+				InputPath:     inputDir,
+				OutputPath:    t.TempDir(),
+				Workers:       1,
+				Buffer:        1,
+				CopyMode:      true,
+				FailFast:      true,
+				NotifyWebhook: server.URL,
+				NotifyOnAbort: notifyOnAbort,
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"b.jpg": date, "c.jpg": date}},
+		}
+		if err := app.Run(); err == nil {
+			t.Fatal("Expected -fail-fast to stop the run, but got nil")
+		}
+
+		select {
+		case summary := <-received:
+			if !notifyOnAbort {
+				t.Errorf("Expected no notification for an aborted run without -notify-on-abort, but got %+v", summary)
+			} else if summary.Status != statusAborted || !strings.Contains(summary.Error, "a_undated.jpg") {
+				t.Errorf("Expected an aborted status with the failing file as cause, but got %+v", summary)
+			}
+		default:
+			if notifyOnAbort {
+				t.Error("Expected the webhook to be called for an aborted run with -notify-on-abort")
+			}
+		}
+	}
+}
+
+func TestPostWebhookErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := postWebhook(server.URL, runSummary{}, time.Second); err == nil {
+		t.Error("Expected an error for a 500 response, but got nil")
+	}
+}
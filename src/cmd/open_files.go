@@ -0,0 +1,77 @@
+package main
+
+import "sync"
+
+// openFileSlots limits the file descriptors held at once by copies and hashing (-max-open-files).
+// Its limiter is built from the config on first use and shared by all workers; nil means unlimited.
+type openFileSlots struct {
+	once    sync.Once
+	limiter *fileLimiter
+}
+
+// acquireFiles blocks until n more descriptors may be opened under -max-open-files and returns
+// the function releasing them. 0 uses defaultMaxOpenFiles and -1 never blocks.
+func (app *App) acquireFiles(n int) func() {
+	o := &app.openFiles
+	o.once.Do(func() {
+		limit := app.Config.MaxOpenFiles
+		if limit == 0 {
+			limit = defaultMaxOpenFiles()
+		}
+		if limit > 0 {
+			o.limiter = newFileLimiter(limit)
+		}
+	})
+	return o.limiter.acquire(n)
+}
+
+// fileLimiter is a counting semaphore over file descriptors.
+type fileLimiter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+// newFileLimiter returns a limiter allowing limit descriptors at once.
+func newFileLimiter(limit int) *fileLimiter {
+	l := &fileLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until n more descriptors may be opened and returns the function releasing them.
+// The n descriptors are taken together, so two workers each holding half of a copy cannot deadlock.
+// A nil limiter never blocks.
+func (l *fileLimiter) acquire(n int) func() {
+	if l == nil {
+		return func() {}
+	}
+	if n > l.limit {
+		n = l.limit
+	}
+	l.mu.Lock()
+	for l.inUse+n > l.limit {
+		l.cond.Wait()
+	}
+	l.inUse += n
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		l.inUse -= n
+		l.mu.Unlock()
+		l.cond.Broadcast()
+	}
+}
+
+// defaultMaxOpenFiles is the -max-open-files used when none is given: half of the process's
+// descriptor limit, leaving the rest for exiftool, logs and reports, or 0 (unlimited) when the
+// limit cannot be read.
+func defaultMaxOpenFiles() int {
+	limit := openFileLimit()
+	if limit <= 0 {
+		return 0
+	}
+	return max(limit/2, 2)
+}
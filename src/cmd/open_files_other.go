@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// openFileLimit is not known on this platform, so open files are not limited by default.
+func openFileLimit() int {
+	return 0
+}
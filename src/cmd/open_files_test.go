@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileLimiter(t *testing.T) {
+	const limit = 5
+	l := newFileLimiter(limit)
+
+	var open, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		n := 1 + i%2 // hashes hold one file, copies two
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := l.acquire(n)
+			now := open.Add(int64(n))
+			for {
+				p := peak.Load()
+				if now <= p || peak.CompareAndSwap(p, now) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			open.Add(-int64(n))
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > limit {
+		t.Errorf("Expected at most %d files open at once, but got %d", limit, p)
+	}
+	if l.inUse != 0 {
+		t.Errorf("Expected every slot to be released, but %d are in use", l.inUse)
+	}
+}
+
+func TestMaxOpenFilesCopies(t *testing.T) {
+	app := &App{Config: &Config{MaxOpenFiles: 3}}
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		src := filepath.Join(dir, fmt.Sprintf("src-%d.jpg", i))
+		if err := os.WriteFile(src, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write source: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.copyFile(src, src+".copy"); err != nil {
+				t.Errorf("copyFile failed: %v", err)
+			}
+			if _, err := app.hashFile(src); err != nil {
+				t.Errorf("hashFile failed: %v", err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Copies and hashes deadlocked under -max-open-files")
+	}
+	if l := app.openFiles.limiter; l.inUse != 0 {
+		t.Errorf("Expected every slot to be released, but %d are in use", l.inUse)
+	}
+}
+
+func TestDefaultMaxOpenFiles(t *testing.T) {
+	limit := openFileLimit()
+	got := defaultMaxOpenFiles()
+	if limit > 0 && (got < 2 || got > limit) {
+		t.Errorf("Expected a default within the limit of %d, but got %d", limit, got)
+	}
+	if limit == 0 && got != 0 {
+		t.Errorf("Expected no default without a known limit, but got %d", got)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"math"
+
+	"golang.org/x/sys/unix"
+)
+
+// openFileLimit returns the soft RLIMIT_NOFILE, or 0 if it cannot be read.
+func openFileLimit() int {
+	var rl unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil || rl.Cur == unix.RLIM_INFINITY {
+		return 0
+	}
+	return int(min(rl.Cur, math.MaxInt32))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// planEntry is one file's resolved destination in a two-pass run.
+type planEntry struct {
+	Source     string
+	Root       string
+	Dirs       []string
+	TargetDir  string
+	TargetPath string
+	Date       time.Time
+	Bytes      int64
+}
+
+// usePlan reports whether the run needs the two-pass plan.
+func (app *App) usePlan() bool {
+	return app.Config.Deterministic || app.Config.DryRunJSON != "" || app.Config.EventGap > 0 || app.Config.DetectSkew || app.Config.ParallelHash ||
+		app.Config.RollbackOnFailure
+}
+
+// runPlanned processes files in two passes: first every destination is resolved,
+// then targets are assigned in source path order and the files are transferred.
+// Assigning targets in a fixed order makes collision suffixes independent of worker scheduling.
+func (app *App) runPlanned(paths []string, bar *progressbar.ProgressBar) {
+	plan := app.buildPlan(paths)
+	if app.stopErr() != nil {
+		return
+	}
+	if app.Config.ParallelHash && !app.Config.DryRun {
+		app.prehash(plan)
+	}
+
+	if app.Config.DryRunJSON != "" {
+		if err := writeDryRunJSON(app.Config.DryRunJSON, plan); err != nil {
+			logrus.Errorf("Failed to write dry-run plan %s: %v", app.Config.DryRunJSON, err)
+		} else {
+			logrus.Infof("Wrote dry-run plan to %s", app.Config.DryRunJSON)
+		}
+	}
+
+	entries := make(map[string]planEntry, len(plan))
+	sources := make([]string, 0, len(plan))
+	for _, entry := range plan {
+		entries[entry.Source] = entry
+		sources = append(sources, entry.Source)
+	}
+
+	// Files that could not be planned are already counted as done.
+	bar.Add(len(paths) - len(plan))
+
+	app.runPool(sources, bar, func(path string) error {
+		defer app.timeFile(path)()
+		entry := entries[path]
+		err := app.transfer(entry.Root, entry.Source, entry.TargetDir, entry.TargetPath)
+		if errors.Is(err, errSkipFile) {
+			return app.track(nil)
+		}
+		if err == nil {
+			app.recordPlaced(entry.Date)
+		} else if app.Config.RollbackOnFailure {
+			app.abort(fmt.Errorf("%s: %w", path, err))
+		}
+		return app.track(err)
+	})
+
+	if app.Config.RollbackOnFailure && app.stopErr() != nil && !app.Config.DryRun {
+		logrus.Warnf("Rolling back the run: %v", app.stopErr())
+		logrus.Infof("Rolled back %d operations", app.rollback())
+	}
+}
+
+// buildPlan resolves the destination of every path concurrently and assigns
+// final target paths in source path order. Files that fail to resolve are logged and left out.
+func (app *App) buildPlan(paths []string) []planEntry {
+	var mu sync.Mutex
+	var plan []planEntry
+
+	planning := progressbar.NewOptions(len(paths),
+		progressbar.OptionSetDescription("Planning"),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowCount(),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
+	)
+	app.runPool(paths, planning, func(path string) error {
+		defer app.timeFile(path)()
+		if app.skipDuplicate(path) {
+			return nil
+		}
+		dirs, date, err := app.resolveDirs(path)
+		if errors.Is(err, errSkipFile) {
+			return nil
+		}
+		if err != nil {
+			app.stats.failed.Add(1)
+			return err
+		}
+		entry := planEntry{Source: path, Root: app.rootFor(path, date), Dirs: dirs, Date: date}
+		if info, err := os.Stat(path); err == nil {
+			entry.Bytes = info.Size()
+		}
+
+		mu.Lock()
+		plan = append(plan, entry)
+		mu.Unlock()
+		return nil
+	})
+
+	if app.Config.DetectSkew {
+		threshold := time.Duration(app.Config.SkewDays) * 24 * time.Hour
+		for _, i := range detectSkew(plan, threshold, skewMinFolderFiles) {
+			logrus.Warnf("Date %s of %s is far from the other files in its folder; routing to %s for review", plan[i].Date.Format("2006-01-02"), plan[i].Source, reviewDirName)
+			plan[i].Dirs = []string{reviewDirName}
+			plan[i].Date = time.Time{}
+		}
+	}
+	if app.Config.EventGap > 0 {
+		assignEvents(plan, app.Config.EventGap)
+	}
+
+	sort.Slice(plan, func(i, j int) bool { return plan[i].Source < plan[j].Source })
+	kept := plan[:0]
+	for _, entry := range plan {
+		entry.TargetDir, entry.TargetPath = app.targetFor(entry.Root, entry.Source, entry.Dirs)
+		if app.alreadyPlaced(entry.Source, entry.TargetDir) {
+			entry.TargetPath = entry.Source
+		} else if !app.Config.IsRemote {
+			target, err := app.claimTarget(entry.Source, entry.TargetPath)
+			if err != nil {
+				app.track(err)
+				continue
+			}
+			entry.TargetPath = target
+			entry.TargetDir = filepath.Dir(target)
+		}
+		kept = append(kept, entry)
+	}
+	plan = kept
+	logrus.Infof("Planned %d of %d files", len(plan), len(paths))
+	return plan
+}
+
+// dryRunPlan is the JSON document written by -dry-run-json.
+type dryRunPlan struct {
+	Targets    map[string][]dryRunFile `json:"targets"`
+	TotalFiles int                     `json:"totalFiles"`
+	TotalBytes int64                   `json:"totalBytes"`
+}
+
+// dryRunFile is one planned file within a target directory.
+type dryRunFile struct {
+	Source   string `json:"source"`
+	Basename string `json:"basename"`
+	Bytes    int64  `json:"bytes"`
+	Date     string `json:"date,omitempty"`
+}
+
+// writeDryRunJSON groups the plan by target directory and writes it to path.
+func writeDryRunJSON(path string, plan []planEntry) error {
+	report := dryRunPlan{Targets: make(map[string][]dryRunFile)}
+	for _, entry := range plan {
+		file := dryRunFile{
+			Source:   entry.Source,
+			Basename: filepath.Base(entry.TargetPath),
+			Bytes:    entry.Bytes,
+		}
+		if !entry.Date.IsZero() {
+			file.Date = entry.Date.Format(time.RFC3339)
+		}
+		report.Targets[entry.TargetDir] = append(report.Targets[entry.TargetDir], file)
+		report.TotalFiles++
+		report.TotalBytes += entry.Bytes
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
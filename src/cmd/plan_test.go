@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeterministicCollisionNumbering(t *testing.T) {
+	inputDir := t.TempDir()
+	names := []string{"a/IMG_0001.jpg", "b/IMG_0001.jpg", "c/IMG_0001.jpg", "d/IMG_0001.jpg", "d/IMG_0002.jpg"}
+	writeFiles(t, inputDir, names...)
+
+	exif := &fakeExif{dates: map[string]time.Time{
+		"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		"IMG_0002.jpg": time.Date(2021, 7, 5, 10, 0, 0, 0, time.UTC),
+	}}
+
+	run := func(workers int) map[string]string {
+		outputDir := t.TempDir()
+		app := &App{
+			Config: &Config{
+				InputPath:     inputDir,
+				OutputPath:    outputDir,
+				Workers:       workers,
+				Buffer:        1,
+				CopyMode:      true,
+				Deterministic: true,
+			},
+			ExifService: exif,
+		}
+		app.Run()
+		return readTree(t, outputDir)
+	}
+
+	first := run(1)
+	second := run(8)
+
+	expected := map[string]string{
+		"2021/07/IMG_0001.jpg":   "a/IMG_0001.jpg",
+		"2021/07/IMG_0001-1.jpg": "b/IMG_0001.jpg",
+		"2021/07/IMG_0001-2.jpg": "c/IMG_0001.jpg",
+		"2021/07/IMG_0001-3.jpg": "d/IMG_0001.jpg",
+		"2021/07/IMG_0002.jpg":   "d/IMG_0002.jpg",
+	}
+	if !reflect.DeepEqual(first, expected) {
+		t.Errorf("Expected output %v, but got %v", expected, first)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected identical output across worker counts, but got %v and %v", first, second)
+	}
+}
+
+func TestTargetReserverSuffixesCollisions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "IMG.jpg")
+
+	var reserver targetReserver
+	first := reserver.Reserve(filepath.Join(dir, "IMG.jpg"))
+	second := reserver.Reserve(filepath.Join(dir, "IMG.jpg"))
+	other := reserver.Reserve(filepath.Join(dir, "other.jpg"))
+
+	if first != filepath.Join(dir, "IMG-1.jpg") {
+		t.Errorf("Expected existing file to be skipped, but got %v", first)
+	}
+	if second != filepath.Join(dir, "IMG-2.jpg") {
+		t.Errorf("Expected reserved path to be skipped, but got %v", second)
+	}
+	if other != filepath.Join(dir, "other.jpg") {
+		t.Errorf("Expected free path to be kept, but got %v", other)
+	}
+}
+
+func TestTargetReserverConflictsDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "IMG.jpg")
+
+	reserver := targetReserver{conflictsDir: true}
+	first := reserver.Reserve(filepath.Join(dir, "IMG.jpg"))
+	second := reserver.Reserve(filepath.Join(dir, "IMG.jpg"))
+	other := reserver.Reserve(filepath.Join(dir, "other.jpg"))
+
+	if first != filepath.Join(dir, "_conflicts", "IMG.jpg") {
+		t.Errorf("Expected the taken target to move to _conflicts, but got %v", first)
+	}
+	if second != filepath.Join(dir, "_conflicts", "IMG-1.jpg") {
+		t.Errorf("Expected a suffix for a collision within _conflicts, but got %v", second)
+	}
+	if other != filepath.Join(dir, "other.jpg") {
+		t.Errorf("Expected free path to be kept, but got %v", other)
+	}
+}
+
+func TestOnConflictConflictsDir(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "a/IMG_0001.jpg", "b/IMG_0001.jpg", "c/IMG_0001.jpg")
+		outputDir := t.TempDir()
+		date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+
+		app := &App{
+			Config: &Config{
+				InputPath:     inputDir,
+				OutputPath:    outputDir,
+				Workers:       1,
+				Buffer:        1,
+				CopyMode:      true,
+				Deterministic: deterministic,
+				OnConflict:    onConflictConflictsDir,
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"IMG_0001.jpg": date}},
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := map[string]string{
+			"2021/07/IMG_0001.jpg":              "a/IMG_0001.jpg",
+			"2021/07/_conflicts/IMG_0001.jpg":   "b/IMG_0001.jpg",
+			"2021/07/_conflicts/IMG_0001-1.jpg": "c/IMG_0001.jpg",
+		}
+		if got := readTree(t, outputDir); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v with deterministic=%v, but got %v", expected, deterministic, got)
+		}
+	}
+}
+
+// modifyDateExif is fakeExif with the EXIF ModifyDate of each file keyed by full path,
+// so a source and an existing target with the same name can differ.
+type modifyDateExif struct {
+	fakeExif
+	modifyDates map[string]string
+}
+
+func (f *modifyDateExif) ExtractFields(path string) (map[string]interface{}, error) {
+	if date, ok := f.modifyDates[path]; ok {
+		return map[string]interface{}{"ModifyDate": date}, nil
+	}
+	return nil, nil
+}
+
+func TestOnConflictKeepNewerExif(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "newer.jpg", "older.jpg", "undated.jpg")
+		outputDir := t.TempDir()
+		writeFiles(t, outputDir, "2021/07/newer.jpg", "2021/07/older.jpg", "2021/07/undated.jpg")
+
+		date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+		exif := &modifyDateExif{
+			fakeExif: fakeExif{dates: map[string]time.Time{"newer.jpg": date, "older.jpg": date, "undated.jpg": date}},
+			modifyDates: map[string]string{
+				filepath.Join(inputDir, "newer.jpg"):                  "2021:07:05 09:00:00",
+				filepath.Join(outputDir, "2021", "07", "newer.jpg"):   "2021:07:04 12:00:00",
+				filepath.Join(inputDir, "older.jpg"):                  "2021:07:04 12:00:00",
+				filepath.Join(outputDir, "2021", "07", "older.jpg"):   "2021:07:05 09:00:00",
+				filepath.Join(outputDir, "2021", "07", "undated.jpg"): "2021:07:05 09:00:00",
+			},
+		}
+		app := &App{
+			Config: &Config{
+				InputPath:     inputDir,
+				OutputPath:    outputDir,
+				Workers:       2,
+				Buffer:        1,
+				Deterministic: deterministic,
+				OnConflict:    onConflictKeepNewerExif,
+			},
+			ExifService: exif,
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expectedOutput := map[string]string{
+			"2021/07/newer.jpg":     "newer.jpg",
+			"2021/07/older.jpg":     "2021/07/older.jpg",
+			"2021/07/undated.jpg":   "2021/07/undated.jpg",
+			"2021/07/undated-1.jpg": "undated.jpg",
+		}
+		if got := readTree(t, outputDir); !reflect.DeepEqual(got, expectedOutput) {
+			t.Errorf("Expected output %v with deterministic=%v, but got %v", expectedOutput, deterministic, got)
+		}
+		if got := readTree(t, inputDir); !reflect.DeepEqual(got, map[string]string{"older.jpg": "older.jpg"}) {
+			t.Errorf("Expected only the older file to stay in the input with deterministic=%v, but got %v", deterministic, got)
+		}
+		if s := app.summary(3, 0); s.Processed != 2 || s.Skipped != 1 || s.Failed != 0 {
+			t.Errorf("Expected 2 processed and 1 skipped with deterministic=%v, but got %+v", deterministic, s)
+		}
+	}
+}
+
+func TestDryRunJSON(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.jpg", "IMG_0002.jpg", "clip.mp4")
+	jsonPath := filepath.Join(t.TempDir(), "plan.json")
+
+	app := &App{
+		Config: &Config{
+			InputPath:  inputDir,
+			OutputPath: outputDir,
+			Workers:    2,
+			Buffer:     1,
+			DryRun:     true,
+			DryRunJSON: jsonPath,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+			"IMG_0002.jpg": time.Date(2021, 7, 5, 10, 0, 0, 0, time.UTC),
+			"clip.mp4":     time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	app.Run()
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("Failed to read plan: %v", err)
+	}
+	var plan dryRunPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		t.Fatalf("Failed to decode plan: %v", err)
+	}
+
+	// Each fixture file contains its own name, so sizes are known.
+	expectedBytes := int64(len("IMG_0001.jpg") + len("IMG_0002.jpg") + len("clip.mp4"))
+	if plan.TotalFiles != 3 || plan.TotalBytes != expectedBytes {
+		t.Errorf("Expected totals 3 files / %d bytes, but got %d / %d", expectedBytes, plan.TotalFiles, plan.TotalBytes)
+	}
+	july := plan.Targets[filepath.Join(outputDir, "2021", "07")]
+	if len(july) != 2 || july[0].Basename != "IMG_0001.jpg" || july[0].Bytes != int64(len("IMG_0001.jpg")) {
+		t.Errorf("Unexpected entries for 2021/07: %+v", july)
+	}
+	if len(plan.Targets[filepath.Join(outputDir, "2022", "01")]) != 1 {
+		t.Errorf("Expected one entry for 2022/01, but got %+v", plan.Targets)
+	}
+	if tree := readTree(t, inputDir); len(tree) != 3 {
+		t.Errorf("Expected dry run to leave input untouched, but got %v", tree)
+	}
+}
+
+func TestCollisionReport(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "a/IMG_0001.jpg", "b/IMG_0001.jpg")
+
+	reportPath := filepath.Join(t.TempDir(), "collisions.csv")
+	report, err := NewCollisionReport(reportPath, 1)
+	if err != nil {
+		t.Fatalf("NewCollisionReport failed: %v", err)
+	}
+
+	app := &App{
+		Config: &Config{
+			InputPath:     inputDir,
+			OutputPath:    outputDir,
+			Workers:       2,
+			Buffer:        1,
+			CopyMode:      true,
+			Deterministic: true,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		}},
+		Collisions: report,
+	}
+	app.Run()
+	report.Close()
+
+	f, err := os.Open(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to open report: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+
+	expected := [][]string{
+		{"original_basename", "final_basename", "target_dir"},
+		{"IMG_0001.jpg", "IMG_0001-1.jpg", filepath.Join(outputDir, "2021", "07")},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected report %v, but got %v", expected, rows)
+	}
+}
+
+func TestDryRunLimit(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.jpg", "IMG_0002.jpg", "IMG_0003.jpg", "IMG_0004.jpg", "IMG_0005.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{
+		"IMG_0001.jpg": date, "IMG_0002.jpg": date, "IMG_0003.jpg": date, "IMG_0004.jpg": date, "IMG_0005.jpg": date,
+	}}
+
+	for _, limit := range []int{2, 10} {
+		jsonPath := filepath.Join(t.TempDir(), "plan.json")
+		app := &App{
+			Config: &Config{
+				InputPath:   inputDir,
+				OutputPath:  t.TempDir(),
+				Workers:     2,
+				Buffer:      1,
+				DryRun:      true,
+				DryRunJSON:  jsonPath,
+				DryRunLimit: limit,
+			},
+			ExifService: exif,
+		}
+		app.Run()
+
+		data, err := os.ReadFile(jsonPath)
+		if err != nil {
+			t.Fatalf("Failed to read plan: %v", err)
+		}
+		var plan dryRunPlan
+		if err := json.Unmarshal(data, &plan); err != nil {
+			t.Fatalf("Failed to decode plan: %v", err)
+		}
+		if expected := min(limit, 5); plan.TotalFiles != expected {
+			t.Errorf("Expected %d planned files with limit %d, but got %d", expected, limit, plan.TotalFiles)
+		}
+	}
+}
+
+func TestDryRunLimitStopsWalk(t *testing.T) {
+	originalWalkDir := walkDir
+	defer func() { walkDir = originalWalkDir }()
+	visited := 0
+	walkDir = func(root string, fn fs.WalkDirFunc) error {
+		return originalWalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				visited++
+			}
+			return fn(path, d, err)
+		})
+	}
+
+	inputDir := t.TempDir()
+	for i := range 50 {
+		writeFiles(t, inputDir, fmt.Sprintf("%02d/IMG_%04d.jpg", i/10, i))
+	}
+	app := &App{Config: &Config{InputPath: inputDir, DryRun: true, DryRunLimit: 3}}
+
+	paths, total := app.collectFiles()
+	if total != 3 || len(paths) != 3 {
+		t.Errorf("Expected 3 files, but got %d: %v", total, paths)
+	}
+	// The walk stops at the file after the limit.
+	if visited > 4 {
+		t.Errorf("Expected the walk to stop after the limit, but it visited %d files", visited)
+	}
+
+	visited = 0
+	app.Config.DryRun = false
+	if paths, _ := app.collectFiles(); len(paths) != 50 || visited != 50 {
+		t.Errorf("Expected the limit to apply to dry runs only, but got %d files after %d visits", len(paths), visited)
+	}
+}
+
+func TestRollbackOnFailure(t *testing.T) {
+	originalMkdirAll := mkdirAll
+	defer func() { mkdirAll = originalMkdirAll }()
+	mkdirAll = func(path string, perm os.FileMode) error {
+		if filepath.Base(path) == "08" {
+			return os.ErrPermission
+		}
+		return originalMkdirAll(path, perm)
+	}
+
+	july := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	august := time.Date(2021, 8, 1, 9, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{"a.jpg": july, "b.jpg": july, "c.jpg": august, "d.jpg": july}}
+
+	for _, copyMode := range []bool{false, true} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "a.jpg", "b/b.jpg", "c.jpg", "d.jpg")
+		before := readTree(t, inputDir)
+		outputDir := t.TempDir()
+
+		app := &App{
+			Config: &Config{
+				InputPath:         inputDir,
+				OutputPath:        outputDir,
+				Workers:           1,
+				Buffer:            1,
+				CopyMode:          copyMode,
+				RollbackOnFailure: true,
+			},
+			ExifService: exif,
+		}
+		err := app.Run()
+		if err == nil || !strings.Contains(err.Error(), "c.jpg") {
+			t.Errorf("Expected the failure of c.jpg with copy=%v, but got %v", copyMode, err)
+		}
+		if got := readTree(t, inputDir); !reflect.DeepEqual(got, before) {
+			t.Errorf("Expected the input to be restored to %v with copy=%v, but got %v", before, copyMode, got)
+		}
+		if got := readTree(t, outputDir); len(got) != 0 {
+			t.Errorf("Expected no placed files after rollback with copy=%v, but got %v", copyMode, got)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Precision is how deep the date tree goes, set with -precision.
+type Precision string
+
+const (
+	PrecisionYear  Precision = "year"
+	PrecisionMonth Precision = "month"
+	PrecisionDay   Precision = "day"
+	PrecisionHour  Precision = "hour"
+)
+
+// parsePrecision validates a -precision value.
+func parsePrecision(s string) (Precision, error) {
+	switch p := Precision(s); p {
+	case PrecisionYear, PrecisionMonth, PrecisionDay, PrecisionHour:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid precision %q: expected year, month, day or hour", s)
+}
+
+// datePath returns the date folders for t down to precision, e.g. 2021/07/04/10 for hour.
+// An unset precision means month, the default YYYY/MM layout.
+func datePath(t time.Time, precision Precision) string {
+	switch precision {
+	case PrecisionYear:
+		return t.Format("2006")
+	case PrecisionDay:
+		return t.Format("2006/01/02")
+	case PrecisionHour:
+		return t.Format("2006/01/02/15")
+	}
+	return t.Format("2006/01")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatePath(t *testing.T) {
+	date := time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		precision Precision
+		expected  string
+	}{
+		{PrecisionYear, "2021"},
+		{PrecisionMonth, "2021/07"},
+		{PrecisionDay, "2021/07/04"},
+		{PrecisionHour, "2021/07/04/10"},
+		{"", "2021/07"},
+	}
+
+	for _, tc := range testCases {
+		if got := datePath(date, tc.precision); got != tc.expected {
+			t.Errorf("Expected %v for precision %q, but got %v", tc.expected, tc.precision, got)
+		}
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	for _, valid := range []string{"year", "month", "day", "hour"} {
+		if p, err := parsePrecision(valid); err != nil || string(p) != valid {
+			t.Errorf("Expected %v to be accepted, but got %v, %v", valid, p, err)
+		}
+	}
+	for _, invalid := range []string{"", "week", "Month"} {
+		if _, err := parsePrecision(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected, but got nil", invalid)
+		}
+	}
+}
+
+func TestResolveDirsPrecision(t *testing.T) {
+	app := &App{
+		Config: &Config{Precision: PrecisionHour, TypeSubfolder: true},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	dirs, _, err := app.resolveDirs("/input/IMG_0001.jpg")
+	if err != nil {
+		t.Fatalf("resolveDirs failed: %v", err)
+	}
+	if got := strings.Join(dirs, "/"); got != "2021/07/04/10/Photos" {
+		t.Errorf("Expected 2021/07/04/10/Photos, but got %v", got)
+	}
+}
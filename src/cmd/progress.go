@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// progressInterval is how often -progress-file is rewritten; tests shorten it.
+var progressInterval = time.Second
+
+// progressStatus is the document written to -progress-file.
+type progressStatus struct {
+	Processed int64  `json:"processed"`
+	Total     int    `json:"total"`
+	Failed    int64  `json:"failed"`
+	Current   string `json:"current"`
+}
+
+// progress returns the current run progress.
+func (app *App) progress(total int) progressStatus {
+	current, _ := app.stats.current.Load().(string)
+	return progressStatus{
+		Processed: app.stats.processed.Load(),
+		Total:     total,
+		Failed:    app.stats.failed.Load(),
+		Current:   current,
+	}
+}
+
+// writeProgressFile replaces path with status. It writes a temporary file next to it and
+// renames it into place, so readers never see a partial document.
+func writeProgressFile(path string, status progressStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// startProgressFile rewrites path every progressInterval until the returned function is
+// called, which writes the final status.
+func (app *App) startProgressFile(path string, total int) func() {
+	write := func() {
+		if err := writeProgressFile(path, app.progress(total)); err != nil {
+			logrus.Warnf("Failed to write progress file %s: %v", path, err)
+		}
+	}
+	write()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				write()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+		write()
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingExif is fakeExif, but waits for release before reading one file.
+type blockingExif struct {
+	fakeExif
+	block   string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	if filepath.Base(path) == b.block {
+		close(b.started)
+		<-b.release
+	}
+	return b.fakeExif.ExtractDate(path, debug, useFileModifyDate)
+}
+
+func TestProgressFile(t *testing.T) {
+	originalInterval := progressInterval
+	defer func() { progressInterval = originalInterval }()
+	progressInterval = 5 * time.Millisecond
+
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b_undated.jpg", "slow.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &blockingExif{
+		fakeExif: fakeExif{dates: map[string]time.Time{"a.jpg": date, "slow.jpg": date}},
+		block:    "slow.jpg",
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	statusPath := filepath.Join(t.TempDir(), "status.json")
+
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: t.TempDir(), Workers: 1, Buffer: 1, CopyMode: true, ProgressFile: statusPath},
+		ExifService: exif,
+	}
+	done := make(chan struct{})
+	go func() {
+		app.Run()
+		close(done)
+	}()
+
+	read := func() progressStatus {
+		t.Helper()
+		data, err := os.ReadFile(statusPath)
+		if err != nil {
+			t.Fatalf("Failed to read progress file: %v", err)
+		}
+		var status progressStatus
+		if err := json.Unmarshal(data, &status); err != nil {
+			t.Fatalf("Failed to decode progress file %q: %v", data, err)
+		}
+		return status
+	}
+
+	// While slow.jpg is in flight, the file must catch up with the first two files.
+	<-exif.started
+	expected := progressStatus{Processed: 1, Total: 3, Failed: 1, Current: filepath.Join(inputDir, "slow.jpg")}
+	var mid progressStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(progressInterval) {
+		if mid = read(); mid == expected {
+			break
+		}
+	}
+	if mid != expected {
+		t.Errorf("Expected mid-run status %+v, but got %+v", expected, mid)
+	}
+
+	close(exif.release)
+	<-done
+	if final := read(); final.Processed != 2 || final.Failed != 1 || final.Total != 3 {
+		t.Errorf("Expected final status 2 processed, 1 failed of 3, but got %+v", final)
+	}
+}
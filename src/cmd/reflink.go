@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errReflinkUnsupported is returned by reflinkCopy on platforms without copy-on-write clones.
+var errReflinkUnsupported = errors.New("reflink copies are not supported on this platform")
+
+// copyTo copies src to dst for -copy. With -reflink it first tries a copy-on-write clone,
+// falling back to a regular (or -sparse) copy when the file system or platform cannot clone,
+// or src and dst are on different file systems.
+func (app *App) copyTo(src, dst string) error {
+	if app.Config.Reflink {
+		err := app.reflinkCopy(src, dst)
+		if err == nil {
+			return nil
+		}
+		logrus.Debugf("Cannot reflink %s, copying instead: %v", src, err)
+	}
+	return app.copyLocal(src, dst)
+}
+
+// copyLocal copies src to dst for -copy and for moves across file systems, keeping holes with -sparse.
+func (app *App) copyLocal(src, dst string) error {
+	if app.Config.Sparse {
+		return app.copySparse(src, dst)
+	}
+	return app.copyFile(src, dst)
+}
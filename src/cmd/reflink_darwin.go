@@ -0,0 +1,11 @@
+//go:build darwin
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// reflinkCopy clones src to dst with clonefile(2), so they share their blocks until either is
+// modified (APFS). dst must not exist yet.
+func (app *App) reflinkCopy(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
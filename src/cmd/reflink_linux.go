@@ -0,0 +1,31 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkCopy clones src to dst with the FICLONE ioctl, so they share their blocks until
+// either is modified (Btrfs, XFS). dst is removed again when the clone fails.
+func (app *App) reflinkCopy(src, dst string) error {
+	defer app.acquireFiles(2)()
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
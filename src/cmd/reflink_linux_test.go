@@ -0,0 +1,37 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReflinkCopy(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "clip.mov")
+	dst := filepath.Join(tempDir, "clone.mov")
+	data := bytes.Repeat([]byte("frame"), 64<<10)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	err := (&App{Config: &Config{}}).reflinkCopy(src, dst)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EXDEV) {
+		if _, statErr := os.Stat(dst); !os.IsNotExist(statErr) {
+			t.Errorf("Expected a failed clone to leave no file behind, but got %v", statErr)
+		}
+		t.Skipf("File system of %s cannot clone: %v", tempDir, err)
+	}
+	if err != nil {
+		t.Fatalf("reflinkCopy failed: %v", err)
+	}
+	if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expected the clone to match its source, but got %d bytes (%v)", len(got), err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// reflinkCopy is not available on this platform; -reflink always falls back to a regular copy.
+func (app *App) reflinkCopy(src, dst string) error {
+	return errReflinkUnsupported
+}
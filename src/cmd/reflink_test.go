@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyToReflinkFallback(t *testing.T) {
+	// Whether or not the temp file system can clone, the copy must succeed with the same content.
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "IMG_0001.jpg")
+	data := bytes.Repeat([]byte("jpeg"), 16<<10)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	for _, sparse := range []bool{false, true} {
+		app := &App{Config: &Config{CopyMode: true, Reflink: true, Sparse: sparse}}
+		dst := filepath.Join(tempDir, "copy.jpg")
+		if err := app.copyTo(src, dst); err != nil {
+			t.Fatalf("copyTo failed (sparse=%v): %v", sparse, err)
+		}
+		if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Expected the copy to match its source (sparse=%v), but got %d bytes (%v)", sparse, len(got), err)
+		}
+		os.Remove(dst)
+	}
+	if got, err := os.ReadFile(src); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expected the source to be left alone, but got %v", err)
+	}
+}
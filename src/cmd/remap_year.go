@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseYearRemap adds one -remap-year value of the form "from:to", e.g. "1980:2008", to remap.
+func parseYearRemap(value string, remap map[int]int) error {
+	fromStr, toStr, ok := strings.Cut(value, ":")
+	from, fromErr := strconv.Atoi(strings.TrimSpace(fromStr))
+	to, toErr := strconv.Atoi(strings.TrimSpace(toStr))
+	if !ok || fromErr != nil || toErr != nil || from < 1 || from > 9999 || to < 1 || to > 9999 {
+		return fmt.Errorf("expected from:to years, e.g. 1980:2008, got %q", value)
+	}
+	if prev, dup := remap[from]; dup && prev != to {
+		return fmt.Errorf("year %d is remapped to both %d and %d", from, prev, to)
+	}
+	remap[from] = to
+	return nil
+}
+
+// remapYear moves t to the year -remap-year maps its year to, keeping the month and day so the
+// file lands in the same month folder. February 29 becomes February 28 in a year without it.
+func (app *App) remapYear(t time.Time) time.Time {
+	year, ok := app.Config.RemapYears[t.Year()]
+	if !ok || t.IsZero() {
+		return t
+	}
+	day := t.Day()
+	if t.Month() == time.February && day == 29 && time.Date(year, time.March, 0, 0, 0, 0, 0, time.UTC).Day() != 29 {
+		day = 28
+	}
+	return time.Date(year, t.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
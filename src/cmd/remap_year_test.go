@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseYearRemap(t *testing.T) {
+	remap := make(map[int]int)
+	for _, value := range []string{"1980:2008", " 1970 : 2004 ", "1980:2008"} {
+		if err := parseYearRemap(value, remap); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", value, err)
+		}
+	}
+	if remap[1980] != 2008 || remap[1970] != 2004 || len(remap) != 2 {
+		t.Errorf("Expected 1980→2008 and 1970→2004, but got %v", remap)
+	}
+
+	for _, value := range []string{"1980", "1980:", "80s:2008", "1980:0", "1980:2009"} {
+		if err := parseYearRemap(value, remap); err == nil {
+			t.Errorf("Expected an error for %q, but got nil", value)
+		}
+	}
+}
+
+func TestRemapYear(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "scan1.jpg", "scan2.jpg", "photo.jpg")
+
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 10, CopyMode: true,
+			RemapYears: map[int]int{1980: 2008}},
+		ExifService: &fakeExif{dates: map[string]time.Time{
+			"scan1.jpg": time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+			"scan2.jpg": time.Date(1980, 2, 29, 12, 0, 0, 0, time.UTC),
+			"photo.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	tree := readTree(t, outputDir)
+	for _, expected := range []string{"2008/01/scan1.jpg", "2008/02/scan2.jpg", "2021/07/photo.jpg"} {
+		if _, ok := tree[expected]; !ok {
+			t.Errorf("Expected %s in the output, but got %v", expected, tree)
+		}
+	}
+	if len(tree) != 3 {
+		t.Errorf("Expected 3 files in the output, but got %v", tree)
+	}
+
+	// February 29 has no match in a common year, so it stays in February.
+	app.Config.RemapYears = map[int]int{1980: 2009}
+	if got := app.remapYear(time.Date(1980, 2, 29, 0, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2009, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2009-02-28, but got %v", got)
+	}
+}
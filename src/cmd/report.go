@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reportSchemaVersion is bumped whenever the -report-json format changes incompatibly.
+const reportSchemaVersion = 1
+
+// runReport is the document written by -report-json.
+type runReport struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	StartedAt     time.Time      `json:"startedAt"`
+	Config        reportConfig   `json:"config"`
+	Stats         runSummary     `json:"stats"`
+	Months        map[string]int `json:"months"`
+	Errors        []reportError  `json:"errors"`
+}
+
+// reportConfig describes how the run was configured.
+type reportConfig struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Copy   bool   `json:"copy"`
+	DryRun bool   `json:"dryRun"`
+	// Flags holds every flag given on the command line, by name.
+	Flags map[string]string `json:"flags"`
+}
+
+// reportError is a file that failed, with its error.
+type reportError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// reportCollector gathers the per-file parts of the report while workers run.
+type reportCollector struct {
+	mu     sync.Mutex
+	months map[string]int
+	errors []reportError
+}
+
+// newReportCollector returns an empty collector.
+func newReportCollector() *reportCollector {
+	return &reportCollector{months: make(map[string]int)}
+}
+
+// RecordPlaced counts a placed file under its YYYY-MM month, or "undated".
+func (c *reportCollector) RecordPlaced(date time.Time) {
+	month := "undated"
+	if !date.IsZero() {
+		month = date.Format("2006-01")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.months[month]++
+}
+
+// RecordError adds a failed file.
+func (c *reportCollector) RecordError(path string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, reportError{Path: path, Error: err.Error()})
+}
+
+// report builds the final report from the collected files and run statistics.
+func (app *App) report(start time.Time, total int, elapsed time.Duration) runReport {
+	c := app.reportFiles
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	months := make(map[string]int, len(c.months))
+	for month, n := range c.months {
+		months[month] = n
+	}
+	errors := append([]reportError{}, c.errors...)
+	sort.Slice(errors, func(i, j int) bool { return errors[i].Path < errors[j].Path })
+
+	flags := app.Config.Flags
+	if flags == nil {
+		flags = map[string]string{}
+	}
+	return runReport{
+		SchemaVersion: reportSchemaVersion,
+		StartedAt:     start,
+		Config: reportConfig{
+			Input:  app.Config.InputPath,
+			Output: app.Config.OutputPath,
+			Copy:   app.Config.CopyMode,
+			DryRun: app.Config.DryRun,
+			Flags:  flags,
+		},
+		Stats:  app.summary(total, elapsed),
+		Months: months,
+		Errors: errors,
+	}
+}
+
+// writeReport writes the report as indented JSON to path.
+func writeReport(path string, report runReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
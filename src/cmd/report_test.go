@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestReportJSON(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "a.jpg", "b.jpg", "c.jpg", "undated.jpg")
+		reportPath := filepath.Join(t.TempDir(), "run.json")
+
+		july := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+		august := time.Date(2021, 8, 1, 9, 0, 0, 0, time.UTC)
+		app := &App{
+			Config: &Config{
+				InputPath:     inputDir,
+				OutputPath:    t.TempDir(),
+				Workers:       2,
+				Buffer:        1,
+				CopyMode:      true,
+				Deterministic: deterministic,
+				ReportJSON:    reportPath,
+				Flags:         map[string]string{"copy": "true", "report-json": reportPath},
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"a.jpg": july, "b.jpg": july, "c.jpg": august}},
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatalf("Failed to read report: %v", err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("Report is not a JSON object: %v", err)
+		}
+		var keys []string
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if expected := []string{"config", "errors", "months", "schemaVersion", "startedAt", "stats"}; !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Expected top-level keys %v, but got %v", expected, keys)
+		}
+
+		var report runReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+		if report.SchemaVersion != reportSchemaVersion {
+			t.Errorf("Expected schema version %d, but got %d", reportSchemaVersion, report.SchemaVersion)
+		}
+		if !report.Config.Copy || report.Config.Input != inputDir || report.Config.Flags["copy"] != "true" {
+			t.Errorf("Expected the run configuration, but got %+v", report.Config)
+		}
+		if report.Stats.Total != 4 || report.Stats.Processed != 3 || report.Stats.Failed != 1 {
+			t.Errorf("Expected 4 total, 3 processed and 1 failed, but got %+v", report.Stats)
+		}
+		if expected := map[string]int{"2021-07": 2, "2021-08": 1}; !reflect.DeepEqual(report.Months, expected) {
+			t.Errorf("Expected months %v, but got %v", expected, report.Months)
+		}
+		if len(report.Errors) != 1 || report.Errors[0].Path != filepath.Join(inputDir, "undated.jpg") || report.Errors[0].Error == "" {
+			t.Errorf("Expected one error for undated.jpg, but got %+v", report.Errors)
+		}
+	}
+}
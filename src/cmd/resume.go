@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// partialSuffix is appended to the target of a resumable copy until it is complete.
+const partialSuffix = ".partial"
+
+// resumeCheckSize is how many bytes at the end of a partial file must match the source for
+// the copy to continue from there.
+const resumeCheckSize = 1 << 20
+
+// copyResumable copies src to dst through dst.partial, renaming it into place when complete.
+// If dst.partial already exists and its tail matches the same range of src, the copy continues
+// after it instead of starting over.
+func (app *App) copyResumable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	partial := dst + partialSuffix
+	offset := resumeOffset(in, partial, info.Size())
+	out, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	w, done := app.withCopyProgress(out, src, info.Size()-offset)
+	defer done()
+	if _, err := io.Copy(w, app.throttled(in)); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, dst)
+}
+
+// resumeOffset returns the size of partial if its last resumeCheckSize bytes match src,
+// or 0 if there is nothing to resume.
+func resumeOffset(src *os.File, partial string, size int64) int64 {
+	info, err := os.Stat(partial)
+	if err != nil || info.Size() == 0 {
+		return 0
+	}
+	n := info.Size()
+	if n > size {
+		logrus.Warnf("Partial copy %s is larger than %s; starting over", partial, src.Name())
+		return 0
+	}
+
+	f, err := os.Open(partial)
+	if err != nil {
+		logrus.Warnf("Cannot read partial copy %s; starting over: %v", partial, err)
+		return 0
+	}
+	defer f.Close()
+
+	start := max(n-resumeCheckSize, 0)
+	want, err := hashRange(src, start, n-start)
+	if err != nil {
+		return 0
+	}
+	got, err := hashRange(f, start, n-start)
+	if err != nil || !bytes.Equal(got, want) {
+		logrus.Warnf("Partial copy %s does not match %s; starting over", partial, src.Name())
+		return 0
+	}
+	logrus.Infof("Resuming copy of %s at %d of %d bytes", src.Name(), n, size)
+	return n
+}
+
+// hashRange returns the SHA-256 of length bytes of r starting at offset.
+func hashRange(r io.ReaderAt, offset, length int64) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, offset, length)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
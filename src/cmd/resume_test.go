@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCopyResumable(t *testing.T) {
+	defer func(orig func(string, int64) io.WriteCloser) { newCopyProgress = orig }(newCopyProgress)
+	var copied []int64
+	newCopyProgress = func(name string, size int64) io.WriteCloser {
+		copied = append(copied, size)
+		return &countingProgress{name: name, size: size}
+	}
+
+	data := make([]byte, 3*resumeCheckSize)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	half := int64(len(data) / 2)
+	corrupt := bytes.Clone(data[:half])
+	corrupt[half-1] ^= 0xff
+
+	testCases := []struct {
+		name      string
+		partial   []byte
+		remaining int64
+	}{
+		{"No partial", nil, int64(len(data))},
+		{"Half-written partial", data[:half], int64(len(data)) - half},
+		{"Complete partial", data, 0},
+		{"Mismatched partial", corrupt, int64(len(data))},
+		{"Partial larger than source", append(bytes.Clone(data), 'x'), int64(len(data))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "clip.mov")
+			dst := filepath.Join(dir, "out.mov")
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatalf("Failed to write source: %v", err)
+			}
+			if tc.partial != nil {
+				if err := os.WriteFile(dst+partialSuffix, tc.partial, 0644); err != nil {
+					t.Fatalf("Failed to write partial: %v", err)
+				}
+			}
+
+			copied = nil
+			if err := (&App{Config: &Config{CopyWithProgress: 1}}).copyResumable(src, dst); err != nil {
+				t.Fatalf("copyResumable failed: %v", err)
+			}
+
+			if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("Expected the copy to match its source, but got %d bytes (%v)", len(got), err)
+			}
+			if _, err := os.Stat(dst + partialSuffix); !os.IsNotExist(err) {
+				t.Errorf("Expected the partial file to be renamed into place, but got %v", err)
+			}
+			// Only copies with bytes left to go show progress.
+			var expected []int64
+			if tc.remaining > 0 {
+				expected = []int64{tc.remaining}
+			}
+			if !reflect.DeepEqual(copied, expected) {
+				t.Errorf("Expected %d bytes to be copied, but got %v", tc.remaining, copied)
+			}
+		})
+	}
+}
+
+func TestCopyFileResumePartial(t *testing.T) {
+	data := bytes.Repeat([]byte("r"), 2*resumeCheckSize)
+	for _, resume := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ResumePartial=%v", resume), func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "clip.mov")
+			dst := filepath.Join(dir, "out.mov")
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatalf("Failed to write source: %v", err)
+			}
+			if err := os.WriteFile(dst+partialSuffix, data[:resumeCheckSize], 0644); err != nil {
+				t.Fatalf("Failed to write partial: %v", err)
+			}
+
+			app := &App{Config: &Config{ResumePartial: resume}}
+			if err := app.copyFile(src, dst); err != nil {
+				t.Fatalf("copyFile failed: %v", err)
+			}
+
+			if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("Expected the copy to match its source, but got %d bytes (%v)", len(got), err)
+			}
+			_, err := os.Stat(dst + partialSuffix)
+			if resumed := os.IsNotExist(err); resumed != resume {
+				t.Errorf("Expected the partial file to be used %v, but got %v", resume, resumed)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// retryDelay is the pause before retrying a failed remote command; tests set it to zero.
+var retryDelay = 2 * time.Second
+
+// Outcomes recorded in the retry report.
+const (
+	retrySucceeded = "succeeded"
+	retryFailed    = "failed"
+)
+
+// RetryReport writes a CSV row for every file whose remote transfer needed retries: those that
+// succeeded after some retries (transient failures) and those that failed after exhausting them
+// (permanent failures).
+type RetryReport struct {
+	out *SafeWriter
+}
+
+// NewRetryReport creates the report at path and writes the header row.
+func NewRetryReport(path string, checkpointEvery int) (*RetryReport, error) {
+	out, err := CreateSafeWriter(path, 0644, checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteRecord([]string{"path", "outcome", "retries", "error"}); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return &RetryReport{out: out}, nil
+}
+
+// Record adds a file, the number of retries it took and, for a permanent failure, the last error.
+func (r *RetryReport) Record(path string, retries int, failure error) error {
+	outcome, message := retrySucceeded, ""
+	if failure != nil {
+		outcome, message = retryFailed, failure.Error()
+	}
+	return r.out.WriteRecord([]string{path, outcome, strconv.Itoa(retries), message})
+}
+
+// Close closes the report file.
+func (r *RetryReport) Close() error {
+	return r.out.Close()
+}
+
+// runRetried is run for remote commands, retrying a failure up to -retries times.
+// It also returns how many retries were used.
+func (app *App) runRetried(name string, args ...string) ([]byte, int, error) {
+	for retries := 0; ; retries++ {
+		output, err := app.run(name, args...)
+		if err == nil || retries >= app.Config.Retries {
+			return output, retries, err
+		}
+		logrus.Warnf("%s failed (attempt %d of %d), retrying: %v", name, retries+1, app.Config.Retries+1, err)
+		if !app.sleepRetry() {
+			return output, retries, err
+		}
+	}
+}
+
+// sleepRetry waits retryDelay, returning false if the run is stopped meanwhile.
+func (app *App) sleepRetry() bool {
+	if app.ctx == nil {
+		time.Sleep(retryDelay)
+		return true
+	}
+	timer := time.NewTimer(retryDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-app.ctx.Done():
+		return false
+	}
+}
+
+// recordRetries adds a file to the -retry-report if its transfer needed retries or failed after them.
+func (app *App) recordRetries(path string, retries int, failure error) {
+	if app.Retries == nil || (retries == 0 && failure == nil) {
+		return
+	}
+	if err := app.Retries.Record(path, retries, failure); err != nil {
+		logrus.Errorf("Failed to record retries of %s: %v", path, err)
+	}
+}
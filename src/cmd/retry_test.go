@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRetryReport(t *testing.T) {
+	originalDelay := retryDelay
+	defer func() { retryDelay = originalDelay }()
+	retryDelay = 0
+
+	// flaky.jpg fails twice before succeeding, broken.jpg always fails, ok.jpg never fails.
+	failures := map[string]int{"flaky.jpg": 2, "broken.jpg": 100}
+	runner := &fakeRunner{respond: func(name string, args []string) ([]byte, error) {
+		if name != "rsync" {
+			return nil, nil
+		}
+		file := filepath.Base(args[len(args)-2])
+		if failures[file] > 0 {
+			failures[file]--
+			return []byte("connection reset"), errors.New("exit status 12")
+		}
+		return nil, nil
+	}}
+
+	reportPath := filepath.Join(t.TempDir(), "retries.csv")
+	report, err := NewRetryReport(reportPath, 1)
+	if err != nil {
+		t.Fatalf("NewRetryReport failed: %v", err)
+	}
+	app := &App{
+		Config: &Config{
+			OutputPath:        "user@host:/remote/photos",
+			IsRemote:          true,
+			CopyMode:          true,
+			FollowDstSymlinks: true,
+			Retries:           3,
+		},
+		Runner:  runner,
+		Retries: report,
+	}
+
+	for _, file := range []string{"flaky.jpg", "broken.jpg", "ok.jpg"} {
+		err := app.placeFile("/input/"+file, []string{"2021", "07"})
+		if (err != nil) != (file == "broken.jpg") {
+			t.Errorf("Unexpected result for %s: %v", file, err)
+		}
+	}
+	report.Close()
+
+	f, err := os.Open(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to open report: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+
+	expected := [][]string{
+		{"path", "outcome", "retries", "error"},
+		{"/input/flaky.jpg", "succeeded", "2", ""},
+		{"/input/broken.jpg", "failed", "3", "exit status 12"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected report %v, but got %v", expected, rows)
+	}
+	if count := strings.Count(strings.Join(runner.commands, "\n"), "/input/broken.jpg "); count != 4 {
+		t.Errorf("Expected broken.jpg to be tried 4 times, but got %d", count)
+	}
+}
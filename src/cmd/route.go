@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// rawRoute is the -route key that stands for every camera RAW extension.
+const rawRoute = "raw"
+
+// rawExtensions are the extensions matched by -route raw:<root>.
+var rawExtensions = []string{".cr2", ".cr3", ".nef", ".arw", ".dng", ".orf", ".raf", ".rw2"}
+
+// parseRoute adds one -route value of the form "ext:/root" to routes, keyed by
+// lower-case extension with its dot. The key "raw" maps every RAW extension.
+func parseRoute(value string, routes map[string]string) error {
+	ext, root, ok := strings.Cut(value, ":")
+	ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+	root = strings.TrimSpace(root)
+	if !ok || ext == "" || root == "" {
+		return fmt.Errorf("expected ext:/root, got %q", value)
+	}
+
+	exts := []string{"." + ext}
+	if ext == rawRoute {
+		exts = rawExtensions
+	}
+	for _, e := range exts {
+		routes[e] = root
+	}
+	return nil
+}
+
+// routedRoot returns the -route root for a file's extension, if any.
+func (app *App) routedRoot(path string) (string, bool) {
+	root, ok := app.Config.Routes[strings.ToLower(filepath.Ext(path))]
+	return root, ok
+}
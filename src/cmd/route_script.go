@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errSkipFile is returned while resolving a file that should be left where it is.
+var errSkipFile = errors.New("file skipped")
+
+// scriptDirs asks the -route-script for the folders of a file. The script gets the path as its
+// argument and the EXIF fields as JSON on stdin, and prints a subpath relative to the output root.
+// Empty output skips the file.
+func (app *App) scriptDirs(file string) ([]string, error) {
+	fields, err := app.ExifService.ExtractFields(file)
+	if err != nil {
+		logrus.Warnf("Cannot read metadata of %s for -route-script, sending none: %v", file, err)
+	}
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	input, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of %s: %w", file, err)
+	}
+
+	output, err := app.runInput(app.Config.RouteScriptTimeout, input, app.Config.RouteScript, file)
+	if err != nil {
+		return nil, fmt.Errorf("route script failed for %s: %w", file, err)
+	}
+	subpath := strings.TrimSpace(string(output))
+	if subpath == "" {
+		logrus.Infof("Skipping %s: -route-script returned no path", file)
+		return nil, errSkipFile
+	}
+	return parseSubpath(subpath)
+}
+
+// parseSubpath splits a slash-separated subpath into folders, refusing paths that would
+// leave the output root.
+func parseSubpath(subpath string) ([]string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(subpath, `\`, "/"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return nil, fmt.Errorf("route script path %q is outside the output root", subpath)
+	}
+	if cleaned == "." {
+		return nil, nil
+	}
+	return strings.Split(cleaned, "/"), nil
+}
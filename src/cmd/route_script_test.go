@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSubpath(t *testing.T) {
+	testCases := []struct {
+		subpath  string
+		expected string
+		hasError bool
+	}{
+		{"Clients/Acme/2021", "Clients/Acme/2021", false},
+		{"./Screenshots/", "Screenshots", false},
+		{`Scans\1990s`, "Scans/1990s", false},
+		{".", "", false},
+		{"/etc", "", true},
+		{"../outside", "", true},
+		{"Trips/../../outside", "", true},
+	}
+
+	for _, tc := range testCases {
+		dirs, err := parseSubpath(tc.subpath)
+		if tc.hasError {
+			if err == nil {
+				t.Errorf("Expected an error for %q, but got %v", tc.subpath, dirs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %q, but got %v", tc.subpath, err)
+		}
+		if got := strings.Join(dirs, "/"); got != tc.expected {
+			t.Errorf("Expected %q for %q, but got %q", tc.expected, tc.subpath, got)
+		}
+	}
+}
+
+func TestRouteScript(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "invoice.pdf", "skip.jpg")
+	outputDir := t.TempDir()
+
+	runner := &fakeRunner{respond: func(name string, args []string) ([]byte, error) {
+		if strings.HasSuffix(args[0], "invoice.pdf") {
+			return []byte("Documents/Invoices\n"), nil
+		}
+		return []byte("\n"), nil
+	}}
+	app := &App{
+		Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 1, CopyMode: true, RouteScript: "/opt/route.sh"},
+		ExifService: &fakeExif{fields: map[string]map[string]interface{}{
+			"invoice.pdf": {"Title": "March"},
+		}},
+		Runner: runner,
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	tree := readTree(t, outputDir)
+	if len(tree) != 1 || tree["Documents/Invoices/invoice.pdf"] != "invoice.pdf" {
+		t.Errorf("Expected only invoice.pdf under Documents/Invoices, but got %v", tree)
+	}
+	if !runner.ran("/opt/route.sh " + filepath.Join(inputDir, "skip.jpg")) {
+		t.Errorf("Expected the script to be run for skip.jpg, but got %v", runner.commands)
+	}
+	if !strings.Contains(strings.Join(runner.inputs, "\n"), `{"Title":"March"}`) {
+		t.Errorf("Expected the EXIF JSON on stdin, but got %v", runner.inputs)
+	}
+	if app.stats.failed.Load() != 0 {
+		t.Errorf("Expected no failures, but got %d", app.stats.failed.Load())
+	}
+}
+
+func TestRouteScriptExec(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "route.sh")
+	body := "#!/bin/sh\ncase \"$1\" in\n*slow*) exec sleep 5 ;;\nesac\nread -r json\necho \"Out/$json\"\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	app := &App{
+		Config:      &Config{RouteScript: script, RouteScriptTimeout: 200 * time.Millisecond},
+		ExifService: &fakeExif{fields: map[string]map[string]interface{}{"a.jpg": {"Make": "Canon"}}},
+	}
+	dirs, err := app.scriptDirs("/input/a.jpg")
+	if err != nil {
+		t.Fatalf("scriptDirs failed: %v", err)
+	}
+	if got := strings.Join(dirs, "/"); got != `Out/{"Make":"Canon"}` {
+		t.Errorf("Expected the script to echo its stdin, but got %v", got)
+	}
+
+	start := time.Now()
+	if _, err := app.scriptDirs("/input/slow.jpg"); err == nil {
+		t.Errorf("Expected a timeout error, but got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the script to be killed after the timeout, but it ran for %s", elapsed)
+	}
+}
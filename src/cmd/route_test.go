@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRoute(t *testing.T) {
+	routes := make(map[string]string)
+	for _, value := range []string{"jpg:/mnt/ssd", ".PNG:/mnt/png", "raw:/mnt/raw"} {
+		if err := parseRoute(value, routes); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", value, err)
+		}
+	}
+	for ext, expected := range map[string]string{".jpg": "/mnt/ssd", ".png": "/mnt/png", ".cr2": "/mnt/raw", ".nef": "/mnt/raw"} {
+		if routes[ext] != expected {
+			t.Errorf("Expected %s to route to %s, but got %q", ext, expected, routes[ext])
+		}
+	}
+
+	for _, value := range []string{"jpg", "jpg:", ":/mnt/ssd"} {
+		if err := parseRoute(value, routes); err == nil {
+			t.Errorf("Expected an error for %q, but got nil", value)
+		}
+	}
+}
+
+func TestRouteByExtension(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	rawDir := t.TempDir()
+	ssdDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.CR2", "IMG_0001.jpg", "clip.mp4")
+
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	routes := make(map[string]string)
+	parseRoute("raw:"+rawDir, routes)
+	parseRoute("jpg:"+ssdDir, routes)
+
+	app := &App{
+		Config: &Config{
+			InputPath:  inputDir,
+			OutputPath: outputDir,
+			Workers:    2,
+			Buffer:     1,
+			CopyMode:   true,
+			Routes:     routes,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{"IMG_0001.CR2": date, "IMG_0001.jpg": date, "clip.mp4": date}},
+	}
+	app.Run()
+
+	for dir, expected := range map[string]map[string]string{
+		rawDir:    {"2021/07/IMG_0001.CR2": "IMG_0001.CR2"},
+		ssdDir:    {"2021/07/IMG_0001.jpg": "IMG_0001.jpg"},
+		outputDir: {"2021/07/clip.mp4": "clip.mp4"},
+	} {
+		if got := readTree(t, dir); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v under %s, but got %v", expected, filepath.Base(dir), got)
+		}
+	}
+}
+
+func TestRouteContinueOnMkdirError(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	rawDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.CR2")
+
+	blocked := filepath.Join(rawDir, "2021", "07")
+	originalMkdirAll := mkdirAll
+	defer func() { mkdirAll = originalMkdirAll }()
+	mkdirAll = func(path string, perm os.FileMode) error {
+		if path == blocked {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrPermission}
+		}
+		return originalMkdirAll(path, perm)
+	}
+
+	routes := make(map[string]string)
+	parseRoute("raw:"+rawDir, routes)
+	for _, deterministic := range []bool{false, true} {
+		app := &App{
+			Config: &Config{
+				InputPath:            inputDir,
+				OutputPath:           outputDir,
+				Workers:              1,
+				Buffer:               1,
+				CopyMode:             true,
+				Routes:               routes,
+				ContinueOnMkdirError: true,
+				Deterministic:        deterministic,
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"IMG_0001.CR2": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)}},
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		// The file stays in its routed tree, in that root's review folder.
+		expected := map[string]string{reviewDirName + "/IMG_0001.CR2": "IMG_0001.CR2"}
+		if got := readTree(t, rawDir); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v under the routed root with deterministic=%v, but got %v", expected, deterministic, got)
+		}
+		if got := readTree(t, outputDir); len(got) != 0 {
+			t.Errorf("Expected nothing under the default output with deterministic=%v, but got %v", deterministic, got)
+		}
+		os.RemoveAll(filepath.Join(rawDir, reviewDirName))
+	}
+}
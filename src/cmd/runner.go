@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandRunner runs external commands such as ssh and rsync; tests substitute a fake.
+type CommandRunner interface {
+	// Run executes name with args and returns its combined output.
+	Run(name string, args ...string) ([]byte, error)
+	// RunInput executes name with args, feeding input to its stdin, and returns its stdout.
+	// The command is killed when ctx is done.
+	RunInput(ctx context.Context, input []byte, name string, args ...string) ([]byte, error)
+}
+
+// execRunner runs commands with os/exec.
+type execRunner struct{}
+
+func (execRunner) Run(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+func (execRunner) RunInput(ctx context.Context, input []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, err
+}
+
+// run executes a command through the configured runner, logging it in debug mode.
+func (app *App) run(name string, args ...string) ([]byte, error) {
+	if app.Config.Debug {
+		logrus.Debugf("Executing: %s %s", name, strings.Join(args, " "))
+	}
+	runner := app.Runner
+	if runner == nil {
+		runner = execRunner{}
+	}
+	return runner.Run(name, args...)
+}
+
+// runInput is run for a command that reads input from stdin, stopped after timeout (0 = never).
+func (app *App) runInput(timeout time.Duration, input []byte, name string, args ...string) ([]byte, error) {
+	if app.Config.Debug {
+		logrus.Debugf("Executing: %s %s", name, strings.Join(args, " "))
+	}
+	runner := app.Runner
+	if runner == nil {
+		runner = execRunner{}
+	}
+	ctx := app.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return runner.RunInput(ctx, input, name, args...)
+}
+
+// checkRemoteSymlinks refuses remote target directories that are, or sit below, a symlink
+// within the remote output root. It runs before the directories are created, so components that
+// do not exist yet pass. Directories found safe are remembered for the rest of the run.
+func (app *App) checkRemoteSymlinks(host, targetDir string) error {
+	if _, ok := app.safeRemote.Load(targetDir); ok {
+		return nil
+	}
+
+	remoteBaseDir := strings.SplitN(app.Config.OutputPath, ":", 2)[1]
+	var tests []string
+	for dir := targetDir; len(dir) > len(remoteBaseDir) && dir != "/" && dir != "."; dir = path.Dir(dir) {
+		tests = append(tests, "-L "+shellQuote(dir))
+	}
+	if len(tests) == 0 {
+		return nil
+	}
+
+	script := "if test " + strings.Join(tests, " -o ") + "; then echo symlink; else echo ok; fi"
+	output, err := app.run("ssh", host, script)
+	if err != nil {
+		return fmt.Errorf("failed to check remote dir %s for symlinks: %w, output: %s", targetDir, err, string(output))
+	}
+	if strings.TrimSpace(string(output)) == "symlink" {
+		return fmt.Errorf("refusing to write into %s: remote target directory is a symlink", targetDir)
+	}
+	app.safeRemote.Store(targetDir, true)
+	return nil
+}
+
+// rsyncArgs builds the rsync arguments for transferring path to a remote target.
+func (app *App) rsyncArgs(path, targetPath string) []string {
+	args := []string{"-aHAXv"}
+	if app.Config.Compress && isCompressible(path) {
+		args = append(args, "-z")
+	}
+	if !app.Config.CopyMode {
+		args = append(args, "--remove-source-files")
+	}
+	return append(args, path, targetPath)
+}
+
+// precompressedExtensions lists formats that gain nothing from transfer compression.
+var precompressedExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".heic": true, ".heif": true, ".avif": true, ".webp": true,
+	".png": true, ".gif": true, ".mp4": true, ".mov": true, ".m4v": true, ".mkv": true,
+	".mts": true, ".m2ts": true, ".3gp": true, ".avi": true, ".mp3": true, ".m4a": true,
+	".aac": true, ".ogg": true, ".opus": true, ".flac": true, ".zip": true, ".gz": true,
+	".7z": true, ".pdf": true,
+}
+
+// isCompressible reports whether a file is worth compressing in transit, such as RAW or TIFF.
+func isCompressible(path string) bool {
+	return !precompressedExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// tagOrigin writes the original path of a placed file into its XMP Source field with exiftool.
+// Failures are logged; the file stays placed.
+func (app *App) tagOrigin(source, targetPath string) {
+	output, err := app.run("exiftool", "-overwrite_original", "-XMP-dc:Source="+source, targetPath)
+	if err != nil {
+		logrus.Warnf("Failed to record origin %s in %s: %v, output: %s", source, targetPath, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// backfillExif writes the date a file was filed under into its DateTimeOriginal with exiftool,
+// for a placed file whose date was inferred. Failures, such as formats exiftool cannot write,
+// are logged; the file stays placed.
+func (app *App) backfillExif(targetPath string, date time.Time) {
+	output, err := app.run("exiftool", "-overwrite_original", "-DateTimeOriginal="+date.Format("2006:01:02 15:04:05"), targetPath)
+	if err != nil {
+		logrus.Warnf("Failed to write DateTimeOriginal into %s: %v, output: %s", targetPath, err, strings.TrimSpace(string(output)))
+	}
+}
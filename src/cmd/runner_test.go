@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner records commands and answers them through respond instead of executing them.
+type fakeRunner struct {
+	mu       sync.Mutex
+	commands []string
+	// inputs holds the stdin of each RunInput call.
+	inputs  []string
+	respond func(name string, args []string) ([]byte, error)
+}
+
+func (f *fakeRunner) Run(name string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	f.commands = append(f.commands, name+" "+strings.Join(args, " "))
+	f.mu.Unlock()
+	if f.respond != nil {
+		return f.respond(name, args)
+	}
+	return nil, nil
+}
+
+func (f *fakeRunner) RunInput(ctx context.Context, input []byte, name string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	f.inputs = append(f.inputs, string(input))
+	f.mu.Unlock()
+	return f.Run(name, args...)
+}
+
+// ran reports whether a recorded command starts with prefix.
+func (f *fakeRunner) ran(prefix string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, cmd := range f.commands {
+		if strings.HasPrefix(cmd, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRemoteSymlinkProtection(t *testing.T) {
+	symlinked := "/remote/photos/2021/07"
+	respond := func(name string, args []string) ([]byte, error) {
+		if name == "ssh" && strings.Contains(strings.Join(args, " "), "test -L") {
+			if strings.Contains(args[1], "'"+symlinked+"'") {
+				return []byte("symlink\n"), nil
+			}
+			return []byte("ok\n"), nil
+		}
+		return nil, nil
+	}
+
+	testCases := []struct {
+		name     string
+		dirs     []string
+		follow   bool
+		hasError bool
+	}{
+		{"Symlinked dir refused", []string{"2021", "07"}, false, true},
+		{"Regular dir allowed", []string{"2021", "08"}, false, false},
+		{"Symlinked dir allowed when following", []string{"2021", "07"}, true, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{respond: respond}
+			app := &App{
+				Config: &Config{
+					OutputPath:        "user@host:/remote/photos",
+					IsRemote:          true,
+					CopyMode:          true,
+					FollowDstSymlinks: tc.follow,
+				},
+				Runner: runner,
+			}
+
+			err := app.placeFile("/input/IMG_0001.jpg", tc.dirs)
+			if tc.hasError && err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+			if !tc.hasError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if ranRsync := runner.ran("rsync"); ranRsync == tc.hasError {
+				t.Errorf("Expected rsync to run: %v, but got %v (commands: %v)", !tc.hasError, ranRsync, runner.commands)
+			}
+			if checked := runner.ran("ssh user@host if test -L"); checked == tc.follow {
+				t.Errorf("Expected symlink check: %v, but got %v", !tc.follow, checked)
+			}
+		})
+	}
+}
+
+func TestRemoteSymlinkCheckBeforeMkdir(t *testing.T) {
+	testCases := []struct {
+		name     string
+		symlink  bool
+		expected []string
+	}{
+		{"Safe dir is created after the check", false, []string{
+			"ssh user@host if test -L '/remote/photos/Summer Trip/2021' -o -L '/remote/photos/Summer Trip'; then echo symlink; else echo ok; fi",
+			"ssh user@host mkdir -p '/remote/photos/Summer Trip/2021'",
+			"rsync -aHAXv /input/IMG_0001.jpg user@host:/remote/photos/Summer Trip/2021/IMG_0001.jpg",
+		}},
+		{"Nothing is created behind a symlink", true, []string{
+			"ssh user@host if test -L '/remote/photos/Summer Trip/2021' -o -L '/remote/photos/Summer Trip'; then echo symlink; else echo ok; fi",
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{respond: func(name string, args []string) ([]byte, error) {
+				if tc.symlink && strings.Contains(strings.Join(args, " "), "test -L") {
+					return []byte("symlink\n"), nil
+				}
+				return []byte("ok\n"), nil
+			}}
+			app := &App{
+				Config: &Config{OutputPath: "user@host:/remote/photos", IsRemote: true, CopyMode: true},
+				Runner: runner,
+			}
+
+			err := app.placeFile("/input/IMG_0001.jpg", []string{"Summer Trip", "2021"})
+			if (err != nil) != tc.symlink {
+				t.Errorf("Expected an error: %v, but got %v", tc.symlink, err)
+			}
+			if !reflect.DeepEqual(runner.commands, tc.expected) {
+				t.Errorf("Expected commands %q, but got %q", tc.expected, runner.commands)
+			}
+		})
+	}
+}
+
+func TestRsyncArgsCompress(t *testing.T) {
+	testCases := []struct {
+		path     string
+		compress bool
+		expected bool
+	}{
+		{"/input/IMG_0001.CR2", true, true},
+		{"/input/scan.tiff", true, true},
+		{"/input/IMG_0001.JPG", true, false},
+		{"/input/clip.mp4", true, false},
+		{"/input/IMG_0001.CR2", false, false},
+	}
+
+	for _, tc := range testCases {
+		app := &App{Config: &Config{Compress: tc.compress, CopyMode: true}}
+		args := app.rsyncArgs(tc.path, "user@host:/remote/2021/07/x")
+		hasZ := false
+		for _, arg := range args {
+			if arg == "-z" {
+				hasZ = true
+			}
+		}
+		if hasZ != tc.expected {
+			t.Errorf("Expected -z %v for %s (compress=%v), but got args %v", tc.expected, tc.path, tc.compress, args)
+		}
+	}
+}
+
+func TestTagOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		dryRun bool
+		fail   bool
+	}{
+		{"Tags placed file", false, false},
+		{"Failure is tolerated", false, true},
+		{"Skipped in dry run", true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			outputDir := t.TempDir()
+			writeFiles(t, inputDir, "IMG_0001.jpg")
+			source := filepath.Join(inputDir, "IMG_0001.jpg")
+			target := filepath.Join(outputDir, "2021", "07", "IMG_0001.jpg")
+
+			runner := &fakeRunner{respond: func(name string, args []string) ([]byte, error) {
+				if tc.fail {
+					return []byte("Error: not writable"), errors.New("exit status 1")
+				}
+				return nil, nil
+			}}
+			app := &App{
+				Config: &Config{OutputPath: outputDir, CopyMode: true, DryRun: tc.dryRun, TagOrigin: true},
+				Runner: runner,
+			}
+			if err := app.placeFile(source, []string{"2021", "07"}); err != nil {
+				t.Fatalf("Expected placement to succeed, but got %v", err)
+			}
+
+			expected := "exiftool -overwrite_original -XMP-dc:Source=" + source + " " + target
+			if tc.dryRun {
+				if len(runner.commands) != 0 {
+					t.Errorf("Expected no commands in dry run, but got %v", runner.commands)
+				}
+				return
+			}
+			if !reflect.DeepEqual(runner.commands, []string{expected}) {
+				t.Errorf("Expected %q, but got %v", expected, runner.commands)
+			}
+		})
+	}
+}
+
+func TestBackfillExif(t *testing.T) {
+	originalZone := localZone
+	defer func() { localZone = originalZone }()
+	localZone = time.UTC
+
+	for _, dryRun := range []bool{false, true} {
+		inputDir := t.TempDir()
+		outputDir := t.TempDir()
+		writeFiles(t, inputDir, "IMG_20210704_103000.jpg", "tagged.jpg")
+
+		runner := &fakeRunner{respond: func(name string, args []string) ([]byte, error) {
+			return []byte("Error: Writing of this type of file is not supported"), errors.New("exit status 1")
+		}}
+		app := &App{
+			Config: &Config{
+				InputPath:    inputDir,
+				OutputPath:   outputDir,
+				Workers:      1,
+				Buffer:       1,
+				CopyMode:     true,
+				DryRun:       dryRun,
+				DateSources:  []string{sourceExif, sourceFilename},
+				BackfillExif: true,
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"tagged.jpg": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}},
+			Runner:      runner,
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		var expected []string
+		if !dryRun {
+			// Only the file dated from its name is written; the failure is tolerated.
+			expected = []string{"exiftool -overwrite_original -DateTimeOriginal=2021:07:04 10:30:00 " +
+				filepath.Join(outputDir, "2021", "07", "IMG_20210704_103000.jpg")}
+		}
+		if !reflect.DeepEqual(runner.commands, expected) {
+			t.Errorf("Expected commands %v with dry-run=%v, but got %v", expected, dryRun, runner.commands)
+		}
+	}
+}
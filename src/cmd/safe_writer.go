@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SafeWriter is a line-oriented file shared by concurrent workers. Each line is written under
+// a lock, so lines from different workers never interleave, and lines are flushed in batches of
+// -checkpoint-every, so with the default of 1 every complete line is on disk if the run is interrupted.
+type SafeWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	// every is the flush interval in lines, and pending the lines written since the last flush.
+	every   int
+	pending int
+}
+
+// CreateSafeWriter creates or truncates the file at path with the given permissions,
+// flushing it every n lines.
+func CreateSafeWriter(path string, perm os.FileMode, every int) (*SafeWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &SafeWriter{file: f, buf: bufio.NewWriter(f), every: every}, nil
+}
+
+// WriteLine writes line, adding the newline if it is missing, and flushes the batch it completes.
+func (w *SafeWriter) WriteLine(line string) error {
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.buf.WriteString(line); err != nil {
+		return err
+	}
+	w.pending++
+	if w.pending < w.every {
+		return nil
+	}
+	w.pending = 0
+	return w.buf.Flush()
+}
+
+// WriteRecord writes fields as one CSV row.
+func (w *SafeWriter) WriteRecord(fields []string) error {
+	var row bytes.Buffer
+	cw := csv.NewWriter(&row)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return w.WriteLine(row.String())
+}
+
+// Name returns the path of the file.
+func (w *SafeWriter) Name() string {
+	return w.file.Name()
+}
+
+// Close flushes any buffered output and closes the file.
+func (w *SafeWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.buf.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
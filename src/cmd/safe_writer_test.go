@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSafeWriterConcurrentLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	w, err := CreateSafeWriter(path, 0644, 1)
+	if err != nil {
+		t.Fatalf("CreateSafeWriter failed: %v", err)
+	}
+
+	const workers, lines = 32, 100
+	// Long lines make a torn write visible if the lock ever fails.
+	padding := strings.Repeat("x", 1000)
+	var wg sync.WaitGroup
+	for g := 0; g < workers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				if err := w.WriteLine(fmt.Sprintf("%02d-%03d %s", g, i, padding)); err != nil {
+					t.Errorf("WriteLine failed: %v", err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(got) != workers*lines {
+		t.Fatalf("Expected %d lines, but got %d", workers*lines, len(got))
+	}
+	seen := make(map[string]bool)
+	for _, line := range got {
+		var g, i int
+		var rest string
+		if n, err := fmt.Sscanf(line, "%02d-%03d %s", &g, &i, &rest); n != 3 || err != nil || rest != padding {
+			t.Fatalf("Expected an intact line, but got %.40q...", line)
+		}
+		seen[line[:6]] = true
+	}
+	if len(seen) != workers*lines {
+		t.Errorf("Expected %d distinct lines, but got %d", workers*lines, len(seen))
+	}
+}
+
+func TestSafeWriterRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	w, err := CreateSafeWriter(path, 0644, 1)
+	if err != nil {
+		t.Fatalf("CreateSafeWriter failed: %v", err)
+	}
+	records := [][]string{
+		{"path", "error"},
+		{"/in/a, b.jpg", "exiftool said \"no\"\nand more"},
+	}
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			t.Fatalf("WriteRecord failed: %v", err)
+		}
+	}
+	w.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open report: %v", err)
+	}
+	defer f.Close()
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("Expected %q, but got %q", records, got)
+	}
+}
+
+func TestSafeWriterCheckpointEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.txt")
+	w, err := CreateSafeWriter(path, 0644, 3)
+	if err != nil {
+		t.Fatalf("CreateSafeWriter failed: %v", err)
+	}
+	onDisk := func() int {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		return strings.Count(string(data), "\n")
+	}
+
+	// Lines reach the disk in batches of 3.
+	expected := []int{0, 0, 3, 3, 3, 6, 6}
+	for i, want := range expected {
+		if err := w.WriteLine(fmt.Sprintf("line %d", i)); err != nil {
+			t.Fatalf("WriteLine failed: %v", err)
+		}
+		if got := onDisk(); got != want {
+			t.Errorf("Expected %d lines on disk after %d writes, but got %d", want, i+1, got)
+		}
+	}
+
+	// Close flushes the partial batch.
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := onDisk(); got != len(expected) {
+		t.Errorf("Expected %d lines on disk after Close, but got %d", len(expected), got)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sampleExampleLen is the longest example value -sample-metadata prints for a tag.
+const sampleExampleLen = 60
+
+// tagSample is one metadata tag found by -sample-metadata.
+type tagSample struct {
+	Tag string
+	// Files is the number of sampled files that have the tag.
+	Files int
+	// Example is the tag's value in the first sampled file that has it.
+	Example string
+}
+
+// sampleMetadata reads the metadata of up to n random paths and merges the tags they have,
+// most common first. It returns the tags and the number of files that could be read.
+func (app *App) sampleMetadata(paths []string, n int) ([]tagSample, int) {
+	if n > len(paths) {
+		n = len(paths)
+	}
+
+	tags := make(map[string]*tagSample)
+	read := 0
+	for _, idx := range rand.Perm(len(paths))[:n] {
+		path := paths[idx]
+		release, err := app.acquireInput(path)
+		if err != nil {
+			logrus.Warnf("Sample: %v", err)
+			continue
+		}
+		fields, err := app.ExifService.ExtractFields(path)
+		release()
+		if err != nil {
+			logrus.Warnf("Sample: cannot read metadata of %s: %v", path, err)
+			continue
+		}
+		read++
+		for tag, value := range fields {
+			if tag == "SourceFile" {
+				continue
+			}
+			s, ok := tags[tag]
+			if !ok {
+				s = &tagSample{Tag: tag, Example: exampleValue(value)}
+				tags[tag] = s
+			}
+			s.Files++
+		}
+	}
+
+	samples := make([]tagSample, 0, len(tags))
+	for _, s := range tags {
+		samples = append(samples, *s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Files != samples[j].Files {
+			return samples[i].Files > samples[j].Files
+		}
+		return samples[i].Tag < samples[j].Tag
+	})
+	return samples, read
+}
+
+// exampleValue formats a tag value on one line, shortened to sampleExampleLen characters.
+func exampleValue(value interface{}) string {
+	s := strings.Join(strings.Fields(fmt.Sprint(value)), " ")
+	if r := []rune(s); len(r) > sampleExampleLen {
+		s = string(r[:sampleExampleLen-3]) + "..."
+	}
+	return s
+}
+
+// printTagSamples writes one line per tag: its name, how many sampled files have it, and an example.
+func printTagSamples(w io.Writer, samples []tagSample, read int) {
+	fmt.Fprintf(w, "%d tags in %d sampled files:\n", len(samples), read)
+	for _, s := range samples {
+		fmt.Fprintf(w, "  %-32s %d/%d  %s\n", s.Tag, s.Files, read, s.Example)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSampleMetadata(t *testing.T) {
+	app := &App{
+		Config: &Config{},
+		ExifService: &fakeExif{fields: map[string]map[string]interface{}{
+			"a.jpg": {"SourceFile": "a.jpg", "Make": "Canon", "LensModel": "EF 50mm f/1.8", "ImageWidth": 6000},
+			"b.jpg": {"SourceFile": "b.jpg", "Make": "Apple", "Software": "17.1\nbeta"},
+			"c.mov": {"SourceFile": "c.mov", "Make": "Apple", "Duration": 12.5},
+		}},
+	}
+
+	samples, read := app.sampleMetadata([]string{"/in/a.jpg", "/in/b.jpg", "/in/c.mov"}, 10)
+	if read != 3 {
+		t.Errorf("Expected 3 files to be read, but got %d", read)
+	}
+	files := make(map[string]int)
+	for _, s := range samples {
+		files[s.Tag] = s.Files
+	}
+	expected := map[string]int{"Make": 3, "LensModel": 1, "ImageWidth": 1, "Software": 1, "Duration": 1}
+	if len(files) != len(expected) {
+		t.Errorf("Expected tags %v, but got %v", expected, files)
+	}
+	for tag, n := range expected {
+		if files[tag] != n {
+			t.Errorf("Expected %s in %d files, but got %d", tag, n, files[tag])
+		}
+	}
+	if samples[0].Tag != "Make" {
+		t.Errorf("Expected the most common tag first, but got %s", samples[0].Tag)
+	}
+
+	var out bytes.Buffer
+	printTagSamples(&out, samples, read)
+	for _, want := range []string{"5 tags in 3 sampled files", "Make", "3/3", "LensModel", "EF 50mm f/1.8", "Software", "17.1 beta", "6000"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the output to contain %q, but got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "sync"
+
+// keyedSemaphore limits how many holders may work on the same key at once,
+// such as operations targeting one destination directory. The zero value is ready to use.
+type keyedSemaphore struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// Acquire blocks until fewer than limit holders hold key, and returns the release function.
+func (s *keyedSemaphore) Acquire(key string, limit int) func() {
+	s.mu.Lock()
+	if s.slots == nil {
+		s.slots = make(map[string]chan struct{})
+	}
+	slot, ok := s.slots[key]
+	if !ok {
+		slot = make(chan struct{}, limit)
+		s.slots[key] = slot
+	}
+	s.mu.Unlock()
+
+	slot <- struct{}{}
+	return func() { <-slot }
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedSemaphoreLimitsPerKey(t *testing.T) {
+	var sem keyedSemaphore
+	const limit = 2
+
+	var mu sync.Mutex
+	current := make(map[string]int)
+	peak := make(map[string]int)
+	var total atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		key := []string{"2021/07", "2021/08"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := sem.Acquire(key, limit)
+			defer release()
+
+			mu.Lock()
+			current[key]++
+			if current[key] > peak[key] {
+				peak[key] = current[key]
+			}
+			mu.Unlock()
+
+			total.Add(1)
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			current[key]--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if total.Load() != 40 {
+		t.Errorf("Expected all 40 operations to run, but got %d", total.Load())
+	}
+	for key, p := range peak {
+		if p > limit {
+			t.Errorf("Expected at most %d concurrent operations in %s, but got %d", limit, key, p)
+		}
+	}
+}
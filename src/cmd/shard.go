@@ -0,0 +1,44 @@
+package main
+
+import (
+	"hash/fnv"
+	"path/filepath"
+	"time"
+)
+
+// Sharding strategies accepted by -shard-by.
+const (
+	shardByHash = "hash"
+	shardByYear = "year"
+)
+
+// rootFor returns the output root for a file: its -route root, the single -o root,
+// or one of the -shard-by roots.
+func (app *App) rootFor(path string, t time.Time) string {
+	if root, ok := app.routedRoot(path); ok {
+		return root
+	}
+	if app.Config.ShardBy == "" || len(app.Config.OutputRoots) == 0 {
+		return app.Config.OutputPath
+	}
+	return chooseRoot(path, app.remapYear(t), app.Config.OutputRoots, app.Config.ShardBy)
+}
+
+// chooseRoot deterministically assigns a file to one of roots: by a hash of its
+// base name, or by its year. Files without a date always go to the first root
+// under the year strategy.
+func chooseRoot(path string, t time.Time, roots []string, strategy string) string {
+	n := uint32(len(roots))
+	switch strategy {
+	case shardByHash:
+		h := fnv.New32a()
+		h.Write([]byte(filepath.Base(path)))
+		return roots[h.Sum32()%n]
+	case shardByYear:
+		if t.IsZero() {
+			return roots[0]
+		}
+		return roots[uint32(t.Year())%n]
+	}
+	return roots[0]
+}
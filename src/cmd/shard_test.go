@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChooseRootHash(t *testing.T) {
+	roots := []string{"/mnt/a", "/mnt/b", "/mnt/c"}
+	counts := make(map[string]int)
+
+	const files = 3000
+	for i := 0; i < files; i++ {
+		path := fmt.Sprintf("/input/IMG_%04d.jpg", i)
+		root := chooseRoot(path, time.Time{}, roots, shardByHash)
+		if again := chooseRoot("/elsewhere/"+fmt.Sprintf("IMG_%04d.jpg", i), time.Time{}, roots, shardByHash); again != root {
+			t.Fatalf("Expected stable assignment for %s, but got %s and %s", path, root, again)
+		}
+		counts[root]++
+	}
+
+	for _, root := range roots {
+		if counts[root] < files/len(roots)*8/10 || counts[root] > files/len(roots)*12/10 {
+			t.Errorf("Expected roughly even distribution, but got %v", counts)
+		}
+	}
+}
+
+func TestChooseRootYear(t *testing.T) {
+	roots := []string{"/mnt/a", "/mnt/b"}
+	testCases := []struct {
+		date     time.Time
+		expected string
+	}{
+		{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "/mnt/a"},
+		{time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC), "/mnt/b"},
+		{time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC), "/mnt/b"},
+		{time.Time{}, "/mnt/a"},
+	}
+
+	for _, tc := range testCases {
+		if got := chooseRoot("/input/x.jpg", tc.date, roots, shardByYear); got != tc.expected {
+			t.Errorf("Expected %v for %v, but got %v", tc.expected, tc.date, got)
+		}
+	}
+}
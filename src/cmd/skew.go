@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// skewMinFolderFiles is the smallest number of dated files a folder needs before its
+// median is trusted for outlier detection.
+const skewMinFolderFiles = 5
+
+// detectSkew returns the indices of plan entries whose date is more than threshold away
+// from the median date of the dated files in the same source folder. A camera with a
+// wrong clock shows up as a few such outliers among many consistent neighbors.
+func detectSkew(plan []planEntry, threshold time.Duration, minFiles int) []int {
+	folders := make(map[string][]int)
+	for i, entry := range plan {
+		if entry.Date.IsZero() {
+			continue
+		}
+		dir := filepath.Dir(entry.Source)
+		folders[dir] = append(folders[dir], i)
+	}
+
+	var outliers []int
+	for _, indices := range folders {
+		if len(indices) < minFiles {
+			continue
+		}
+		median := medianDate(plan, indices)
+		for _, i := range indices {
+			deviation := plan[i].Date.Sub(median)
+			if deviation < 0 {
+				deviation = -deviation
+			}
+			if deviation > threshold {
+				outliers = append(outliers, i)
+			}
+		}
+	}
+	sort.Ints(outliers)
+	return outliers
+}
+
+// medianDate returns the median date of the given plan entries.
+func medianDate(plan []planEntry, indices []int) time.Time {
+	dates := make([]time.Time, len(indices))
+	for n, i := range indices {
+		dates[n] = plan[i].Date
+	}
+	sort.Slice(dates, func(a, b int) bool { return dates[a].Before(dates[b]) })
+	return dates[len(dates)/2]
+}
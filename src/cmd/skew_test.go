@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectSkew(t *testing.T) {
+	var plan []planEntry
+	base := time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		plan = append(plan, planEntry{Source: fmt.Sprintf("/cards/A/IMG_%04d.jpg", i), Date: base.Add(time.Duration(i) * 24 * time.Hour)})
+	}
+	// Injected outlier: one 2015 file among the 2021 files.
+	plan = append(plan, planEntry{Source: "/cards/A/IMG_9999.jpg", Date: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)})
+	// Undated files and small folders are never flagged.
+	plan = append(plan, planEntry{Source: "/cards/A/song.mp3"})
+	plan = append(plan,
+		planEntry{Source: "/cards/B/old.jpg", Date: time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)},
+		planEntry{Source: "/cards/B/new.jpg", Date: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+	)
+
+	outliers := detectSkew(plan, 365*24*time.Hour, skewMinFolderFiles)
+	if expected := []int{20}; !reflect.DeepEqual(outliers, expected) {
+		t.Errorf("Expected outliers %v, but got %v", expected, outliers)
+	}
+}
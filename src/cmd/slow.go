@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slowFile is a file whose processing took longer than -max-runtime-per-file.
+type slowFile struct {
+	Path    string  `json:"path"`
+	Seconds float64 `json:"seconds"`
+	// Bytes is the size of the file, or -1 if it could not be read.
+	Bytes int64 `json:"bytes"`
+}
+
+// slowFileTracker adds up the time spent on each file, across planning and transfer.
+// The zero value is ready to use.
+type slowFileTracker struct {
+	mu    sync.Mutex
+	spent map[string]time.Duration
+	sizes map[string]int64
+}
+
+// timeFile starts timing work on path for -max-runtime-per-file and returns the function that
+// stops it, logging the file as slow when its total time first exceeds the threshold.
+// Use it as defer app.timeFile(path)().
+func (app *App) timeFile(path string) func() {
+	threshold := app.Config.MaxRuntimePerFile
+	if threshold <= 0 {
+		return func() {}
+	}
+	start := time.Now()
+	size := int64(-1)
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	return func() {
+		t := &app.slowFiles
+		t.mu.Lock()
+		if t.spent == nil {
+			t.spent = make(map[string]time.Duration)
+			t.sizes = make(map[string]int64)
+		}
+		before := t.spent[path]
+		after := before + time.Since(start)
+		t.spent[path] = after
+		if _, ok := t.sizes[path]; !ok {
+			t.sizes[path] = size
+		}
+		t.mu.Unlock()
+
+		if before <= threshold && after > threshold {
+			logrus.Warnf("Slow file %s: took %s (%d bytes)", path, after.Round(time.Millisecond), size)
+		}
+	}
+}
+
+// slowest returns the files that took longer than -max-runtime-per-file, slowest first.
+func (app *App) slowest() []slowFile {
+	t := &app.slowFiles
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var slow []slowFile
+	for path, spent := range t.spent {
+		if spent > app.Config.MaxRuntimePerFile {
+			slow = append(slow, slowFile{Path: path, Seconds: spent.Seconds(), Bytes: t.sizes[path]})
+		}
+	}
+	sort.Slice(slow, func(i, j int) bool { return slow[i].Seconds > slow[j].Seconds })
+	return slow
+}
+
+// logSlowFiles lists the slow files at the end of the run.
+func (app *App) logSlowFiles() {
+	slow := app.slowest()
+	if len(slow) == 0 {
+		return
+	}
+	logrus.Warnf("%d files took longer than %s:", len(slow), app.Config.MaxRuntimePerFile)
+	for _, f := range slow {
+		logrus.Warnf("  %s: %.1fs (%d bytes)", f.Path, f.Seconds, f.Bytes)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// delayedExif is fakeExif that takes longer for some files.
+type delayedExif struct {
+	fakeExif
+	delays map[string]time.Duration
+}
+
+func (d *delayedExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	time.Sleep(d.delays[filepath.Base(path)])
+	return d.fakeExif.ExtractDate(path, debug, useFileModifyDate)
+}
+
+func TestMaxRuntimePerFile(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "fast.jpg", "slow.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+
+	for _, deterministic := range []bool{false, true} {
+		app := &App{
+			Config: &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 2, Buffer: 10, CopyMode: true, DryRun: true,
+				Deterministic: deterministic, MaxRuntimePerFile: 50 * time.Millisecond},
+			ExifService: &delayedExif{
+				fakeExif: fakeExif{dates: map[string]time.Time{"fast.jpg": date, "slow.jpg": date}},
+				delays:   map[string]time.Duration{"slow.jpg": 100 * time.Millisecond},
+			},
+		}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		slow := app.summary(2, time.Second).SlowFiles
+		if len(slow) != 1 || filepath.Base(slow[0].Path) != "slow.jpg" {
+			t.Errorf("Expected only slow.jpg to be flagged (deterministic=%v), but got %+v", deterministic, slow)
+			continue
+		}
+		if slow[0].Seconds < 0.1 || slow[0].Bytes != int64(len("slow.jpg")) {
+			t.Errorf("Expected at least 0.1s and %d bytes, but got %+v", len("slow.jpg"), slow[0])
+		}
+	}
+}
@@ -11,15 +11,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
@@ -27,6 +32,39 @@ import (
 	"media_organizer/src/internal"
 )
 
+// Date source strategies accepted by -prefer.
+const (
+	preferExif              = "exif"
+	preferEarliestOfSources = "earliest-of-sources"
+)
+
+// Policies accepted by -naive-tz for dates written without a time zone.
+const (
+	naiveTZLocal = "local"
+	naiveTZUTC   = "utc"
+)
+
+// reviewDirName is the folder under the output root for files that could not be placed normally.
+const reviewDirName = "_review"
+
+// badDatesDirName is the folder under the output root for -quarantine-unparseable-dates.
+const badDatesDirName = "_baddates"
+
+// outputMarker is written to the output root so later walks never treat organized files as input.
+const outputMarker = ".media_organizer_output"
+
+// mkdirAll creates local directories; tests replace it to inject failures.
+var mkdirAll = os.MkdirAll
+
+// walkDir walks input trees; tests replace it to count the entries visited.
+var walkDir = filepath.WalkDir
+
+// renameFile and removeFile move and delete local files; tests replace them to inject failures.
+var (
+	renameFile = os.Rename
+	removeFile = os.Remove
+)
+
 // Config holds the application configuration, populated from command-line flags.
 type Config struct {
 	InputPath            string
@@ -34,31 +72,388 @@ type Config struct {
 	Workers              int
 	Buffer               int
 	Debug                bool
+	LogLevel             string
 	CopyMode             bool
 	DryRun               bool
 	OnlyDateTimeOriginal bool
 	UseFileModifyDate    bool
+	ByDecade             bool
+	DecadeYears          bool
+	Sparse               bool
+	UndoScript           string
+	MediaType            string
+	ByAlbum              bool
+	Prefer               string
+	Deterministic        bool
+	ModifiedAfter        time.Time
+	DryRunJSON           string
+	MinSize              int64
+	MaxSize              int64
+	NotifyWebhook        string
+	ByLens               bool
+	ContinueOnMkdirError bool
+	DateSources          []string
+	CollisionReport      string
+	CreateOutputRoot     bool
+	Estimate             int
+	FollowDstSymlinks    bool
+	Compress             bool
+	EventGap             time.Duration
+	ExtraDateTags        []string
+	PreserveTreeUnder    bool
+	ShardBy              string
+	OutputRoots          []string
+	ManifestOut          string
+	DetectSkew           bool
+	SkewDays             int
+	ApplyOffset          time.Duration
+	DirConcurrency       int
+	FailFast             bool
+	TypeSubfolder        bool
+	DryRunLimit          int
+	Archive              string
+	Rehome               bool
+	Routes               map[string]string
+	DateFromDir          bool
+	DirDateLayouts       []string
+	ForceRemove          bool
+	ParallelHash         bool
+	SkipHidden           bool
+	OutputReadOnly       bool
+	OutputReadOnlyDirs   bool
+	BySoftware           bool
+	VerifyAfterMove      bool
+	VerifyHash           bool
+	MergeDuplicateDirs   bool
+	InputGlobs           []string
+	TagOrigin            bool
+	ProgressFile         string
+	LivePhotoSubfolder   bool
+	RunTimeout           time.Duration
+	LogPerRun            bool
+	ErrorReport          string
+	ReprocessErrors      string
+	RouteScript          string
+	RouteScriptTimeout   time.Duration
+	DedupeAcrossDest     bool
+	SniffMIME            bool
+	AllowDelete          bool
+	ByOrientation        bool
+	MinFreePercent       float64
+	ReportJSON           string
+	NaiveTZ              string
+	FlattenSparseYears   int
+	FromSQLite           string
+	Query                string
+	RollbackOnFailure    bool
+	OnlyMissingDate      string
+	OnConflict           string
+	ExifFieldsCache      int
+	Retries              int
+	RetryReport          string
+	ByFaces              bool
+	DedupeDryRun         string
+	SkipSystemDirs       []string
+	MoveEmptyToTrash     bool
+	Precision            Precision
+	ThroughputCapMbps    float64
+	BackfillExif         bool
+	InputZip             string
+	DedupeHardlink       bool
+	MaxRuntimePerFile    time.Duration
+	CopyWithProgress     int64
+	Reverse              bool
+	SampleMetadata       int
+	VideoUseMtime        bool
+	Inputs               []labeledInput
+	CheckpointEvery      int
+	ListDestinations     bool
+	NormalizeUnicode     string
+	DryRunCompare        string
+	WorkerStats          time.Duration
+	Reflink              bool
+	QuarantineBadDates   bool
+	MaxOpenFiles         int
+	ByExtension          string
+	ResumePartial        bool
+	Classify             bool
+	RemapYears           map[int]int
+	NotifyOnAbort        bool
+	DateTagPriority      []string
+	Flags                map[string]string
 	IsRemote             bool
 }
 
+// ExifReader is the metadata access App needs; *internal.ExifToolService implements it.
+type ExifReader interface {
+	ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error)
+	ExtractFields(path string) (map[string]interface{}, error)
+}
+
 // App represents the application state, including configuration and services.
 type App struct {
 	Config      *Config
-	ExifService *internal.ExifToolService
+	ExifService ExifReader
+	Undo        *UndoWriter
+	Collisions  *CollisionReport
+	Manifest    *Manifest
+	Archive     *ArchiveBackend
+	Errors      *ErrorReport
+	Retries     *RetryReport
+	Duplicates  *DedupeReport
+	Runner      CommandRunner
+
+	targets    targetReserver
+	stats      runStats
+	safeRemote sync.Map
+	dirSlots   keyedSemaphore
+	hashes     hashIndex
+	destHashes hashIndex
+	placedDirs sync.Map
+	// pool tracks the running worker pool for -worker-stats.
+	pool poolStats
+	// slowFiles records the time spent on each file for -max-runtime-per-file.
+	slowFiles slowFileTracker
+	// contentSlots serializes the placement of files with the same content for -dedupe-hardlink.
+	contentSlots keyedSemaphore
+	// inferredDates holds the dates -backfill-exif writes into placed files, by source path.
+	inferredDates sync.Map
+	// openFiles limits the descriptors held by copies and hashing for -max-open-files.
+	openFiles openFileSlots
+	// throttle caps the throughput of local copies for -throughput-cap-mbps.
+	throttle copyThrottle
+	// zip is the -input-zip archive whose entries are extracted on demand.
+	zip *zipInput
+	// badDates holds the files whose date tags could not be parsed, by source path.
+	badDates sync.Map
+	// movedSources holds the sources moved away, for -move-empty-to-trash-after.
+	movedSources sync.Map
+	livePhotos   livePhotoPairs
+	dirCase      sync.Map
+	journal      undoJournal
+	// reportFiles collects placed months and failures for -report-json.
+	reportFiles *reportCollector
+	// spaceChecked is when -min-free-percent was last checked, in Unix nanoseconds.
+	spaceChecked atomic.Int64
+	ctx          context.Context
+	cancel       context.CancelCauseFunc
+}
+
+// runStats counts file outcomes across workers.
+type runStats struct {
+	processed atomic.Int64
+	failed    atomic.Int64
+	// skipped counts the files -on-conflict keep-newer-exif left in place.
+	skipped atomic.Int64
+	// current is the path a worker most recently started on.
+	current atomic.Value
 }
 
 // NewConfig creates a new Config object from command-line flags.
 func NewConfig() *Config {
 	config := &Config{}
-	flag.StringVar(&config.InputPath, "i", "", "Input directory")
+	flag.Func("i", "Input directory, or label=dir to place its files under a label folder (repeatable)", func(s string) error {
+		in, err := parseInput(s)
+		if err != nil {
+			return err
+		}
+		if config.InputPath == "" {
+			config.InputPath = in.Path
+		}
+		config.Inputs = append(config.Inputs, in)
+		return nil
+	})
 	flag.StringVar(&config.OutputPath, "o", "", "Output directory")
 	flag.IntVar(&config.Workers, "workers", 8, "Number of concurrent workers")
 	flag.IntVar(&config.Buffer, "buffer", 100, "Channel buffer size")
-	flag.BoolVar(&config.Debug, "debug", false, "Enable debug logging")
+	flag.BoolVar(&config.Debug, "debug", false, "Enable debug logging (alias for -log-level debug)")
+	flag.StringVar(&config.LogLevel, "log-level", "info", "Log level: trace, debug, info, warn or error")
 	flag.BoolVar(&config.CopyMode, "copy", false, "Copy instead of move (keep original files)")
 	flag.BoolVar(&config.DryRun, "dry-run", false, "Show what would be done, without moving/copying files")
 	flag.BoolVar(&config.OnlyDateTimeOriginal, "only-datetimeoriginal", false, "Only process files with DateTimeOriginal tag")
 	flag.BoolVar(&config.UseFileModifyDate, "use-file-modify-date", false, "Use file modify date as a fallback")
+	flag.BoolVar(&config.ByDecade, "by-decade", false, "Organize into decade folders (e.g. 1950s) instead of YYYY/MM")
+	flag.BoolVar(&config.DecadeYears, "decade-years", false, "With -by-decade, add a year subfolder under each decade (e.g. 1950s/1954)")
+	flag.BoolVar(&config.Sparse, "sparse", false, "Preserve holes in sparse files when copying locally")
+	flag.StringVar(&config.UndoScript, "undo-script", "", "Write a shell script that reverses every local move/copy to this path")
+	flag.StringVar(&config.MediaType, "media-type", "", "Only process files of this media type (image, video, audio, document)")
+	flag.BoolVar(&config.ByAlbum, "by-album", false, "With -media-type audio, organize into Artist/Album folders, falling back to date")
+	flag.StringVar(&config.Prefer, "prefer", preferExif, "Date source strategy: exif, or earliest-of-sources to use the earlier of the EXIF and filename dates")
+	flag.BoolVar(&config.Deterministic, "deterministic", false, "Plan all targets before moving so collision suffixes follow source path order")
+	flag.Func("modified-after", "Only collect files modified on or after this date (YYYY-MM-DD)", func(s string) error {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return fmt.Errorf("expected YYYY-MM-DD: %w", err)
+		}
+		config.ModifiedAfter = t
+		return nil
+	})
+	flag.StringVar(&config.DryRunJSON, "dry-run-json", "", "Write the planned targets grouped by directory, with sizes, to this JSON file (implies -dry-run)")
+	flag.Func("min-size", "Skip files smaller than this size (e.g. 50KB)", func(s string) error {
+		size, err := parseSize(s)
+		config.MinSize = size
+		return err
+	})
+	flag.Func("max-size", "Skip files larger than this size (e.g. 4GB)", func(s string) error {
+		size, err := parseSize(s)
+		config.MaxSize = size
+		return err
+	})
+	flag.StringVar(&config.NotifyWebhook, "notify-webhook", "", "POST the final run statistics as JSON to this URL on completion (see -notify-on-abort)")
+	flag.BoolVar(&config.ByLens, "by-lens", false, "Prepend a lens folder (from LensModel/LensID) to the date tree")
+	flag.BoolVar(&config.ContinueOnMkdirError, "continue-on-mkdir-error", false, "Route files whose target directory cannot be created to "+reviewDirName+" instead of failing them")
+	flag.Func("date-sources", "Comma-separated date sources tried in order: exif, filename, sidecar, mtime, heif (native HEIC/AVIF reader), dir (default exif)", func(s string) error {
+		sources, err := parseDateSources(s)
+		config.DateSources = sources
+		return err
+	})
+	flag.StringVar(&config.CollisionReport, "collision-report", "", "Write a CSV of every file renamed because its target already existed")
+	flag.BoolVar(&config.CreateOutputRoot, "create-output-root", false, "Create the output directory even if its parent does not exist")
+	flag.IntVar(&config.Estimate, "estimate", 0, "Time N random files copied to a temp dir, print an ETA for the full run and exit")
+	flag.BoolVar(&config.FollowDstSymlinks, "follow-dst-symlinks", true, "Allow writing into remote target directories that are symlinks (use =false to refuse)")
+	flag.BoolVar(&config.Compress, "compress", false, "Compress remote transfers (rsync -z), except for already-compressed formats")
+	flag.Func("by-event", "Group files into Event-NNN folders under the date, splitting where shots are at least this far apart (e.g. gap=4h)", func(s string) error {
+		gap, err := time.ParseDuration(strings.TrimPrefix(s, "gap="))
+		if err != nil || gap <= 0 {
+			return fmt.Errorf("expected a positive duration such as gap=4h")
+		}
+		config.EventGap = gap
+		return nil
+	})
+	flag.Func("extra-date-tags", "Comma-separated extra EXIF date tags to check after the built-in ones (list them in -date-tag-priority to check them first)", func(s string) error {
+		for _, tag := range strings.Split(s, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				config.ExtraDateTags = append(config.ExtraDateTags, tag)
+			}
+		}
+		return nil
+	})
+	flag.BoolVar(&config.PreserveTreeUnder, "preserve-tree-under", false, "Keep the top-level input folder as an album prefix above the date tree (files in the input root use "+rootAlbum+")")
+	flag.StringVar(&config.ShardBy, "shard-by", "", "Spread files across the comma-separated -o roots: hash or year")
+	flag.StringVar(&config.ManifestOut, "manifest-out", "", "Write a SHA256SUMS-format manifest of placed files, relative to the output root")
+	flag.BoolVar(&config.DetectSkew, "detect-skew", false, "Route files whose date is far from the median of their source folder to "+reviewDirName)
+	flag.IntVar(&config.SkewDays, "skew-days", 365, "With -detect-skew, days from the folder median beyond which a date is an outlier")
+	flag.DurationVar(&config.ApplyOffset, "apply-offset", 0, "Shift every extracted date by this duration to correct a camera clock (e.g. -3h)")
+	flag.IntVar(&config.DirConcurrency, "concurrency-limit-per-dir", 0, "Maximum concurrent operations targeting the same destination directory (0 = unlimited)")
+	flag.BoolVar(&config.FailFast, "fail-fast", false, "Stop the run at the first failed file and exit with its error")
+	flag.BoolVar(&config.TypeSubfolder, "type-subfolder", false, "Add a Photos, Videos, Audio, Documents or Other folder under each date folder")
+	flag.IntVar(&config.DryRunLimit, "dry-run-limit", 0, "In dry-run mode, only plan the first N files (0 = all)")
+	flag.StringVar(&config.Archive, "archive", "", "Write the organized files into this .tar or .zip archive instead of an output directory")
+	flag.BoolVar(&config.Rehome, "rehome", false, "Re-file an already organized tree in place: move misfiled files within the input root, leaving correctly filed ones alone")
+	flag.Func("route", "Send files with an extension to another output root, e.g. jpg:/mnt/ssd or raw:/mnt/raw (repeatable)", func(s string) error {
+		if config.Routes == nil {
+			config.Routes = make(map[string]string)
+		}
+		return parseRoute(s, config.Routes)
+	})
+	flag.BoolVar(&config.DateFromDir, "date-from-dir", false, "When no other date source has a date, parse one from the parent folder name (e.g. \"2021-07-04 Trip\")")
+	flag.Func("dir-date-layouts", "Comma-separated Go time layouts for -date-from-dir (default 2006-01-02,2006_01_02,2006.01.02,20060102,2006-01)", func(s string) error {
+		for _, layout := range strings.Split(s, ",") {
+			if layout = strings.TrimSpace(layout); layout != "" {
+				config.DirDateLayouts = append(config.DirDateLayouts, layout)
+			}
+		}
+		return nil
+	})
+	flag.BoolVar(&config.ForceRemove, "force-remove", false, "When a moved file's source cannot be removed for lack of permission, add write permission and retry")
+	flag.BoolVar(&config.ParallelHash, "parallel-hash", false, "Hash all files on the worker pool before moving them, so moves never wait on hashing")
+	flag.BoolVar(&config.SkipHidden, "skip-hidden", false, "Skip hidden files and folders (names starting with '.', or with the hidden attribute on Windows)")
+	flag.BoolVar(&config.OutputReadOnly, "output-readonly", false, "Make placed files read-only (0444)")
+	flag.BoolVar(&config.OutputReadOnlyDirs, "output-readonly-dirs", false, "With -output-readonly, also make the folders files were placed in read-only (0555) at the end of the run")
+	flag.BoolVar(&config.BySoftware, "by-software", false, "Prepend a folder for the EXIF Software field (e.g. Adobe-Photoshop, Camera) to the date tree")
+	flag.BoolVar(&config.VerifyAfterMove, "verify-after-move", false, "Check that each placed local file has the size of its source")
+	flag.BoolVar(&config.VerifyHash, "verify-hash", false, "Check that each placed local file has the SHA-256 of its source (implies -verify-after-move)")
+	flag.BoolVar(&config.MergeDuplicateDirs, "merge-duplicate-dirs", false, "Reuse an existing folder whose name differs only by case (e.g. 2021/Jul for 2021/jul) instead of creating another")
+	flag.Func("input-glob", "Only process files whose name matches this glob, e.g. IMG_*.JPG (repeatable; a file matching any glob is processed)", func(s string) error {
+		if _, err := filepath.Match(s, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", s, err)
+		}
+		config.InputGlobs = append(config.InputGlobs, s)
+		return nil
+	})
+	flag.BoolVar(&config.TagOrigin, "tag-origin", false, "Record each placed file's original path in its XMP Source field (local output, uses exiftool)")
+	flag.StringVar(&config.ProgressFile, "progress-file", "", "Keep a JSON status ({processed, total, failed, current}) in this file, rewritten every second")
+	flag.BoolVar(&config.LivePhotoSubfolder, "live-photo-subfolder", false, "Put the .MOV half of a Live Photo in a hidden "+livePhotoDir+" folder next to its still")
+	flag.DurationVar(&config.RunTimeout, "run-timeout", 0, "Stop starting new files after this long (e.g. 2h); files in flight are finished and the run exits with an error")
+	flag.BoolVar(&config.LogPerRun, "log-per-run", false, "Log each run to its own sortbydate-YYYYMMDD-HHMMSS.log instead of appending to sortbydate.log")
+	flag.StringVar(&config.ErrorReport, "error-report", "", "Write a CSV of every file that failed, with its error")
+	flag.StringVar(&config.ReprocessErrors, "reprocess-errors", "", "Instead of walking the input, process only the files listed in this -error-report CSV")
+	flag.StringVar(&config.RouteScript, "route-script", "", "Run this script for each file (path as argument, EXIF JSON on stdin) and use the subpath it prints instead of the date folders; empty output skips the file")
+	flag.DurationVar(&config.RouteScriptTimeout, "route-script-timeout", 30*time.Second, "Kill a -route-script that has not answered within this long")
+	flag.BoolVar(&config.DedupeAcrossDest, "dedupe-across-dest", false, "Skip files whose content already exists anywhere in the output, hashing the existing output at startup")
+	flag.BoolVar(&config.SniffMIME, "sniff-mime", false, "Classify files by their content instead of their extension for -media-type and -type-subfolder")
+	flag.BoolVar(&config.AllowDelete, "allow-delete", false, "Confirm that source files may be deleted after they are placed; required unless -copy is set")
+	flag.BoolVar(&config.ByOrientation, "by-orientation", false, "Add a Landscape, Portrait or Square folder under the date, from the image dimensions and rotation")
+	flag.Float64Var(&config.MinFreePercent, "min-free-percent", 0, "Stop the run when free space on a local output root drops below this percentage (checked every 10s)")
+	flag.StringVar(&config.ReportJSON, "report-json", "", "Write a versioned JSON report of the run (configuration, statistics, files per month, errors) to this file")
+	flag.StringVar(&config.NaiveTZ, "naive-tz", naiveTZLocal, "How to read dates without a time zone: local (camera clock, filed as written) or utc (converted to local time before filing)")
+	flag.IntVar(&config.FlattenSparseYears, "flatten-sparse-years", 0, "After the run, move the files of year folders with at most N month folders up into the year folder (dry-run only reports them)")
+	flag.StringVar(&config.FromSQLite, "from-sqlite", "", "Instead of walking the input, process the paths selected by -query from this SQLite database (opened read-only)")
+	flag.StringVar(&config.Query, "query", "", "With -from-sqlite, a query returning a single text column of file paths")
+	flag.BoolVar(&config.RollbackOnFailure, "rollback-on-failure", false, "Plan all targets first, and if a file then fails to transfer, stop and reverse every move and copy already made")
+	flag.StringVar(&config.OnlyMissingDate, "only-missing-date", "", "Audit only: write the files no date source can date to this file, one per line, without moving anything (-o not needed)")
+	flag.StringVar(&config.OnConflict, "on-conflict", onConflictSuffix, "What to do when a target already exists: suffix (name-1.ext), conflicts-dir (move it to a _conflicts folder in the target directory) or keep-newer-exif (keep whichever file has the newer EXIF ModifyDate, replacing the existing one)")
+	flag.IntVar(&config.ExifFieldsCache, "exif-fields-cache", internal.DefaultFieldsCacheSize, "Number of files whose metadata is kept, so each file is read by exiftool once however many -by-* options need it (0 = no cache)")
+	flag.IntVar(&config.Retries, "retries", 0, "Retry a failed remote command (ssh mkdir, rsync) up to N times, pausing 2s between attempts")
+	flag.StringVar(&config.RetryReport, "retry-report", "", "Write a CSV of every remote transfer that succeeded after retries or failed after exhausting them")
+	flag.BoolVar(&config.ByFaces, "by-faces", false, "Add a People or Other folder under the date, depending on whether the photo has face regions in its metadata")
+	flag.StringVar(&config.DedupeDryRun, "dedupe-dry-run", "", "Write a CSV of the files -dedupe-across-dest would skip and the output copy it would keep, while processing every file normally")
+	flag.Func("skip-system-dir", "Also skip folders with this name, in addition to built-in system folders such as .Spotlight-V100, @eaDir and $RECYCLE.BIN (repeatable)", func(s string) error {
+		if s == "" || strings.ContainsAny(s, `/\`) {
+			return fmt.Errorf("invalid folder name %q", s)
+		}
+		config.SkipSystemDirs = append(config.SkipSystemDirs, s)
+		return nil
+	})
+	flag.BoolVar(&config.MoveEmptyToTrash, "move-empty-to-trash-after", false, "After moving, send source folders the run left without files to the OS trash (dry-run only reports them)")
+	flag.Func("precision", "How deep the date tree goes: year (2021), month (2021/07), day (2021/07/04) or hour (2021/07/04/10) (default month)", func(s string) error {
+		p, err := parsePrecision(s)
+		config.Precision = p
+		return err
+	})
+	flag.Float64Var(&config.ThroughputCapMbps, "throughput-cap-mbps", 0, "Cap the combined throughput of local copies, across all workers, at this many megabits per second (0 = unlimited)")
+	flag.BoolVar(&config.BackfillExif, "backfill-exif", false, "Write a date taken from the file name, folder, modification time or a sidecar into the placed file's DateTimeOriginal (local output, uses exiftool)")
+	flag.StringVar(&config.InputZip, "input-zip", "", "Organize the files inside this .zip archive instead of an input directory, extracting each file to a temporary folder only while it is processed (replaces -i)")
+	flag.BoolVar(&config.DedupeHardlink, "dedupe-hardlink", false, "Place files whose content is already in the output as hard links to that copy, saving space while keeping every path (skipped if on another file system)")
+	flag.DurationVar(&config.MaxRuntimePerFile, "max-runtime-per-file", 0, "Log every file that takes longer than this to process (e.g. 30s) as a slow file, with its size, and list them at the end (0 = off)")
+	flag.Func("copy-with-progress", "Show a progress bar for each local copy of a file at least this large (e.g. 1GB)", func(s string) error {
+		size, err := parseSize(s)
+		config.CopyWithProgress = size
+		return err
+	})
+	flag.BoolVar(&config.Reverse, "reverse", false, "Un-organize: place every file of the input tree directly in the output root, suffixing name collisions (layout options are ignored)")
+	flag.IntVar(&config.SampleMetadata, "sample-metadata", 0, "Print which metadata tags are present in N random files, with example values, and exit (-o not needed)")
+	flag.BoolVar(&config.VideoUseMtime, "video-use-mtime", false, "Date videos by their file modification time instead of their EXIF, keeping EXIF for photos")
+	flag.IntVar(&config.CheckpointEvery, "checkpoint-every", 1, "Flush the undo script, manifest and reports every N files instead of after each one; faster, but a crash can lose the last N-1 entries")
+	flag.BoolVar(&config.ListDestinations, "list-destinations", false, "Print the distinct target folders the run would fill, with file counts, and exit without placing anything")
+	flag.Func("normalize-unicode", "Normalize target file names to this Unicode form, nfc or nfd, so names from macOS and other systems match", func(s string) error {
+		form, err := parseNormalization(s)
+		config.NormalizeUnicode = form
+		return err
+	})
+	flag.StringVar(&config.DryRunCompare, "dry-run-compare", "", "Compare the current settings with this existing organized tree: list the files that would move to another folder and the new ones, count the unchanged, and exit (-o not needed)")
+	flag.DurationVar(&config.WorkerStats, "worker-stats", 0, "Log files per second, queue depth, idle workers and files per worker at this interval (e.g. 10s)")
+	flag.BoolVar(&config.Reflink, "reflink", false, "With -copy, clone files on copy-on-write file systems (Btrfs, XFS, APFS) so copies are instant and share space, falling back to a regular copy")
+	flag.BoolVar(&config.QuarantineBadDates, "quarantine-unparseable-dates", false, "Route files that have a date tag in a format that cannot be parsed to "+badDatesDirName+", logging the raw value")
+	flag.IntVar(&config.MaxOpenFiles, "max-open-files", 0, "Maximum files open at once for copying and hashing across workers (0 = half the system limit, -1 = unlimited)")
+	flag.StringVar(&config.ByExtension, "by-extension", "", "Add a folder named after the file extension (e.g. jpg): first puts it above the date (jpg/2021/07), last under it (2021/07/jpg)")
+	flag.BoolVar(&config.ResumePartial, "resume-partial", false, "Copy local files through a .partial file renamed into place when complete, and continue a .partial left by an interrupted run instead of starting over")
+	flag.BoolVar(&config.Classify, "classify", false, "Print how many files and bytes of each extension and media type the input holds, and exit (-o not needed)")
+	flag.Func("remap-year", "Rewrite a year in the output path, e.g. 1980:2008 to file scans a camera dated 1980 under 2008 (repeatable)", func(s string) error {
+		if config.RemapYears == nil {
+			config.RemapYears = make(map[int]int)
+		}
+		return parseYearRemap(s, config.RemapYears)
+	})
+	flag.BoolVar(&config.NotifyOnAbort, "notify-on-abort", false, "Also POST to -notify-webhook when the run is stopped early, with status \"aborted\" and the cause")
+	flag.Func("date-tag-priority", "Comma-separated date tags to check before all others, in order, e.g. MediaCreateDate to prefer a vendor field over CreateDate", func(s string) error {
+		for _, tag := range strings.Split(s, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				config.DateTagPriority = append(config.DateTagPriority, tag)
+			}
+		}
+		return nil
+	})
 	// Use custom usage/help function
 			flag.Usage = showHelp
 
@@ -72,45 +467,432 @@ func NewConfig() *Config {
 
 	flag.Parse()
 
+	config.Flags = make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		config.Flags[f.Name] = f.Value.String()
+	})
+
+	if config.DryRunJSON != "" {
+		config.DryRun = true
+	}
+	if config.VerifyHash {
+		config.VerifyAfterMove = true
+	}
+	if config.Rehome && config.OutputPath == "" {
+		config.OutputPath = config.InputPath
+	}
+
+	if config.ShardBy != "" {
+		for _, root := range strings.Split(config.OutputPath, ",") {
+			if root = strings.TrimSpace(root); root != "" {
+				config.OutputRoots = append(config.OutputRoots, root)
+			}
+		}
+		if len(config.OutputRoots) > 0 {
+			config.OutputPath = config.OutputRoots[0]
+		}
+	}
+
 	config.IsRemote = strings.Contains(config.OutputPath, "@") && strings.Contains(config.OutputPath, ":")
 
 	return config
 }
 
 // setupLogging configures the logging settings for the application.
-func setupLogging(debug bool) {
-	logFile, err := os.OpenFile("sortbydate.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// With perRun, each run logs to its own timestamped file instead of appending to sortbydate.log.
+func setupLogging(level logrus.Level, perRun bool) {
+	logFile, err := openLogFile(".", perRun, time.Now())
 	if err != nil {
 		logrus.Fatalf("Failed to open log file: %v", err)
 	}
-	logrus.SetOutput(logFile)
-	if debug {
-		logrus.SetLevel(logrus.DebugLevel)
+	if level >= logrus.DebugLevel {
+		// Interactive debug runs also show the log, above the progress bar.
+		logrus.SetOutput(io.MultiWriter(logFile, terminal))
 	} else {
-		logrus.SetLevel(logrus.InfoLevel)
+		logrus.SetOutput(logFile)
+	}
+	logrus.SetLevel(level)
+}
+
+// openLogFile opens the log file in dir for appending: sortbydate.log, or with perRun
+// sortbydate-YYYYMMDD-HHMMSS.log for the run started at now.
+func openLogFile(dir string, perRun bool, now time.Time) (*os.File, error) {
+	name := "sortbydate.log"
+	if perRun {
+		name = "sortbydate-" + now.Format("20060102-150405") + ".log"
+	}
+	return os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// resolveLogLevel parses a -log-level value. -debug raises the level to at least debug.
+func resolveLogLevel(level string, debug bool) (logrus.Level, error) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return 0, fmt.Errorf("invalid -log-level %q: expected trace, debug, info, warn or error", level)
 	}
+	if debug && parsed < logrus.DebugLevel {
+		parsed = logrus.DebugLevel
+	}
+	return parsed, nil
 }
 
 func main() {
+	// Deferred first so it runs after every other deferred cleanup.
+	failed := false
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
 	config := NewConfig()
-	if config.InputPath == "" || config.OutputPath == "" {
+	if (config.InputPath == "" && config.InputZip == "") || (config.OutputPath == "" && config.Archive == "" && config.OnlyMissingDate == "" && config.SampleMetadata == 0 && config.DryRunCompare == "" && !config.Classify) {
 		logrus.Fatal("Input (-i) and output (-o) directories are required")
 	}
+	if config.InputZip != "" {
+		if config.InputPath != "" {
+			logrus.Fatal("-input-zip replaces -i; give only one of them")
+		}
+		if config.Rehome || config.FromSQLite != "" || config.ReprocessErrors != "" || config.MoveEmptyToTrash {
+			logrus.Fatal("-input-zip cannot be combined with -rehome, -from-sqlite, -reprocess-errors or -move-empty-to-trash-after")
+		}
+	}
+	if config.Archive != "" && config.OutputPath != "" {
+		logrus.Fatal("-archive replaces -o; give only one of them")
+	}
+	switch config.MediaType {
+	case "", internal.MediaImage, internal.MediaVideo, internal.MediaAudio, internal.MediaDocument:
+	default:
+		logrus.Fatalf("Invalid -media-type %q: expected image, video, audio or document", config.MediaType)
+	}
+	if config.Prefer != preferExif && config.Prefer != preferEarliestOfSources {
+		logrus.Fatalf("Invalid -prefer %q: expected %s or %s", config.Prefer, preferExif, preferEarliestOfSources)
+	}
+	if config.NaiveTZ != naiveTZLocal && config.NaiveTZ != naiveTZUTC {
+		logrus.Fatalf("Invalid -naive-tz %q: expected %s or %s", config.NaiveTZ, naiveTZLocal, naiveTZUTC)
+	}
+	switch config.ShardBy {
+	case "", shardByHash, shardByYear:
+	default:
+		logrus.Fatalf("Invalid -shard-by %q: expected %s or %s", config.ShardBy, shardByHash, shardByYear)
+	}
+	if config.ShardBy != "" && config.IsRemote {
+		logrus.Fatal("-shard-by only supports local output roots")
+	}
+	if len(config.Routes) > 0 && (config.IsRemote || config.Archive != "" || config.Rehome) {
+		logrus.Fatal("-route only supports local output roots and cannot be combined with -archive or -rehome")
+	}
+	if (config.DedupeAcrossDest || config.DedupeDryRun != "" || config.DedupeHardlink) && (config.IsRemote || config.Archive != "") {
+		logrus.Fatal("-dedupe-across-dest, -dedupe-dry-run and -dedupe-hardlink only support local output roots")
+	}
+	// Linked files share their content, so nothing may rewrite a placed file in place.
+	if config.DedupeHardlink && (config.DedupeAcrossDest || config.TagOrigin || config.BackfillExif || config.OnConflict == onConflictKeepNewerExif) {
+		logrus.Fatal("-dedupe-hardlink cannot be combined with -dedupe-across-dest, -tag-origin, -backfill-exif or -on-conflict keep-newer-exif")
+	}
+	if config.ByAlbum && config.MediaType != internal.MediaAudio {
+		logrus.Fatal("-by-album requires -media-type audio")
+	}
+	if config.OutputReadOnlyDirs && !config.OutputReadOnly {
+		logrus.Fatal("-output-readonly-dirs requires -output-readonly")
+	}
+	if config.Rehome {
+		if filepath.Clean(config.OutputPath) != filepath.Clean(config.InputPath) {
+			logrus.Fatal("-rehome works in place; -o must be omitted or equal to -i")
+		}
+		if config.CopyMode || config.ShardBy != "" || config.Archive != "" {
+			logrus.Fatal("-rehome moves files within one tree and cannot be combined with -copy, -shard-by or -archive")
+		}
+	}
+
+	if config.MinFreePercent < 0 || config.MinFreePercent >= 100 {
+		logrus.Fatalf("Invalid -min-free-percent %g: expected a percentage from 0 to 100", config.MinFreePercent)
+	}
+	if (config.FromSQLite == "") != (config.Query == "") {
+		logrus.Fatal("-from-sqlite and -query must be used together")
+	}
+	if config.FromSQLite != "" && config.ReprocessErrors != "" {
+		logrus.Fatal("-from-sqlite cannot be combined with -reprocess-errors")
+	}
+	switch config.OnConflict {
+	case onConflictSuffix:
+	case onConflictConflictsDir:
+		if config.IsRemote {
+			logrus.Fatal("-on-conflict conflicts-dir only supports local output roots and -archive")
+		}
+	case onConflictKeepNewerExif:
+		if config.IsRemote || config.Archive != "" {
+			logrus.Fatal("-on-conflict keep-newer-exif only supports local output roots")
+		}
+		if !config.AllowDelete && !config.DryRun {
+			logrus.Fatal("-on-conflict keep-newer-exif replaces older files in the output; pass -allow-delete to confirm")
+		}
+	default:
+		logrus.Fatalf("Invalid -on-conflict %q: expected %s, %s or %s", config.OnConflict, onConflictSuffix, onConflictConflictsDir, onConflictKeepNewerExif)
+	}
+	if config.RollbackOnFailure && (config.IsRemote || config.Archive != "") {
+		logrus.Fatal("-rollback-on-failure only supports local output roots")
+	}
+	if config.FlattenSparseYears < 0 {
+		logrus.Fatal("-flatten-sparse-years must not be negative")
+	}
+	if config.ByDecade && config.Precision != "" {
+		logrus.Fatal("-precision cannot be combined with -by-decade")
+	}
+	if config.FlattenSparseYears > 0 && (config.IsRemote || config.Archive != "" || config.Rehome || config.ByDecade || config.ByExtension == extensionFirst ||
+		(config.Precision != "" && config.Precision != PrecisionMonth) ||
+		config.UndoScript != "" || config.ManifestOut != "" || config.OutputReadOnly) {
+		logrus.Fatal("-flatten-sparse-years only supports a local YYYY/MM output and cannot be combined with -rehome, -undo-script, -manifest-out or -output-readonly")
+	}
+	if config.MoveEmptyToTrash && (config.CopyMode || config.Archive != "") {
+		logrus.Fatal("-move-empty-to-trash-after needs a move run; it cannot be combined with -copy or -archive")
+	}
+	if config.Reverse && (config.Rehome || config.RouteScript != "" || config.ShardBy == shardByYear || config.EventGap > 0 || config.DetectSkew ||
+		config.PreserveTreeUnder || config.FlattenSparseYears > 0) {
+		logrus.Fatal("-reverse places files in a flat output and cannot be combined with -rehome, -route-script, -shard-by year, -by-event, -detect-skew, -preserve-tree-under or -flatten-sparse-years")
+	}
+	if len(config.Inputs) > 1 && (config.Rehome || config.MoveEmptyToTrash) {
+		logrus.Fatal("-rehome and -move-empty-to-trash-after work on a single input; give -i only once")
+	}
+	labels := make(map[string]bool)
+	for _, in := range config.Inputs {
+		if in.Label != "" && labels[strings.ToLower(in.Label)] {
+			logrus.Fatalf("Input label %q is used twice", in.Label)
+		}
+		labels[strings.ToLower(in.Label)] = true
+	}
+	if config.CheckpointEvery < 1 {
+		logrus.Fatal("-checkpoint-every must be at least 1")
+	}
+	if config.Reflink && (!config.CopyMode || config.IsRemote || config.Archive != "") {
+		logrus.Fatal("-reflink clones local copies and requires -copy with a local output root")
+	}
+	if config.ByExtension != "" && config.ByExtension != extensionFirst && config.ByExtension != extensionLast {
+		logrus.Fatalf("Invalid -by-extension %q: expected %s or %s", config.ByExtension, extensionFirst, extensionLast)
+	}
+	if config.ResumePartial && (config.Sparse || config.IsRemote || config.Archive != "") {
+		logrus.Fatal("-resume-partial only applies to regular local copies and cannot be combined with -sparse, -archive or a remote output")
+	}
+	if config.NotifyOnAbort && config.NotifyWebhook == "" {
+		logrus.Fatal("-notify-on-abort requires -notify-webhook")
+	}
+	if config.MaxOpenFiles < -1 {
+		logrus.Fatal("-max-open-files must be a positive number, 0 for the default or -1 for unlimited")
+	}
+	if config.WorkerStats < 0 {
+		logrus.Fatal("-worker-stats must not be negative")
+	}
+	if config.ThroughputCapMbps < 0 {
+		logrus.Fatal("-throughput-cap-mbps must not be negative")
+	}
+	if config.Retries < 0 {
+		logrus.Fatal("-retries must not be negative")
+	}
+	if config.ExifFieldsCache < 0 {
+		logrus.Fatal("-exif-fields-cache must not be negative")
+	}
+	if err := checkDeleteAllowed(config); err != nil {
+		logrus.Fatal(err)
+	}
 
-	setupLogging(config.Debug)
+	level, err := resolveLogLevel(config.LogLevel, config.Debug)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	config.Debug = level >= logrus.DebugLevel
+	setupLogging(level, config.LogPerRun)
+
+	if writesOutputRoot(config) {
+		for _, root := range config.localRoots() {
+			if err := checkOutputRoot(root, config.CreateOutputRoot, config.DryRun); err != nil {
+				logrus.Fatal(err)
+			}
+			if !config.DryRun {
+				if err := writeOutputMarker(root); err != nil {
+					logrus.Warnf("Failed to write output marker: %v", err)
+				}
+			}
+		}
+	}
 
 	exifService, err := internal.NewExifToolService()
 	if err != nil {
 		logrus.Fatalf("Failed to initialize ExifToolService: %v", err)
 	}
 	defer exifService.Close()
+	exifService.SetExtraDateTags(config.ExtraDateTags)
+	exifService.SetDateTagPriority(config.DateTagPriority)
+	exifService.SetFieldsCacheSize(config.ExifFieldsCache)
 
 	app := &App{
 		Config:      config,
 		ExifService: exifService,
 	}
 
-	app.Run()
+	if config.Archive != "" && !config.DryRun {
+		archive, err := NewArchiveBackend(config.Archive)
+		if err != nil {
+			logrus.Fatalf("Failed to create archive: %v", err)
+		}
+		defer func() {
+			if err := archive.Close(); err != nil {
+				logrus.Errorf("Failed to finalize archive %s: %v", config.Archive, err)
+			}
+		}()
+		app.Archive = archive
+		// Targets are archive entry names, so only names used in this run can collide.
+		app.targets.inMemory = true
+	}
+
+	if config.UndoScript != "" {
+		if config.IsRemote {
+			logrus.Warn("-undo-script only records local operations; remote transfers will not be included")
+		}
+		undo, err := NewUndoWriter(config.UndoScript, config.CheckpointEvery)
+		if err != nil {
+			logrus.Fatalf("Failed to create undo script: %v", err)
+		}
+		defer undo.Close()
+		app.Undo = undo
+	}
+
+	if config.ErrorReport != "" {
+		if config.ErrorReport == config.ReprocessErrors {
+			logrus.Fatal("-error-report must not overwrite the -reprocess-errors input")
+		}
+		report, err := NewErrorReport(config.ErrorReport, config.CheckpointEvery)
+		if err != nil {
+			logrus.Fatalf("Failed to create error report: %v", err)
+		}
+		defer report.Close()
+		app.Errors = report
+	}
+
+	if config.RetryReport != "" {
+		if !config.IsRemote || config.Retries == 0 {
+			logrus.Warn("-retry-report only records remote transfers with -retries set")
+		}
+		retries, err := NewRetryReport(config.RetryReport, config.CheckpointEvery)
+		if err != nil {
+			logrus.Fatalf("Failed to create retry report: %v", err)
+		}
+		defer retries.Close()
+		app.Retries = retries
+	}
+
+	if config.DedupeDryRun != "" {
+		duplicates, err := NewDedupeReport(config.DedupeDryRun, config.CheckpointEvery)
+		if err != nil {
+			logrus.Fatalf("Failed to create dedupe report: %v", err)
+		}
+		defer duplicates.Close()
+		app.Duplicates = duplicates
+	}
+
+	if config.CollisionReport != "" {
+		collisions, err := NewCollisionReport(config.CollisionReport, config.CheckpointEvery)
+		if err != nil {
+			logrus.Fatalf("Failed to create collision report: %v", err)
+		}
+		defer collisions.Close()
+		app.Collisions = collisions
+	}
+
+	if config.ManifestOut != "" && !config.IsRemote && !config.DryRun {
+		manifest, err := NewManifest(config.ManifestOut, config.CheckpointEvery)
+		if err != nil {
+			logrus.Fatalf("Failed to create manifest: %v", err)
+		}
+		defer func() {
+			if err := manifest.Close(); err != nil {
+				logrus.Errorf("Failed to finalize manifest: %v", err)
+			}
+		}()
+		app.Manifest = manifest
+	}
+
+	if err := app.Run(); err != nil {
+		logrus.Errorf("Run stopped: %v", err)
+		failed = true
+	}
+}
+
+// localRoots returns every local output root: the -o or -shard-by roots, then the -route roots.
+func (config *Config) localRoots() []string {
+	roots := config.OutputRoots
+	if len(roots) == 0 {
+		roots = []string{config.OutputPath}
+	}
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		seen[root] = true
+	}
+	var routed []string
+	for _, root := range config.Routes {
+		if !seen[root] {
+			seen[root] = true
+			routed = append(routed, root)
+		}
+	}
+	sort.Strings(routed)
+	return append(append([]string(nil), roots...), routed...)
+}
+
+// writesOutputRoot reports whether the run places files in local output roots, which are
+// checked and marked before it starts. Audits and previews that only read the input do not,
+// nor do estimates, which copy their samples to a temporary folder.
+func writesOutputRoot(config *Config) bool {
+	return !config.IsRemote && config.Archive == "" && config.OnlyMissingDate == "" && config.SampleMetadata == 0 &&
+		!config.ListDestinations && config.DryRunCompare == "" && !config.Classify && config.Estimate == 0
+}
+
+// checkDeleteAllowed refuses a run that would delete source files unless -allow-delete was given.
+// Copies, archives, dry runs, estimates, samples, listings, comparisons, classifications and audits leave the sources alone, and -input-zip only
+// moves the files it extracted itself.
+func checkDeleteAllowed(config *Config) error {
+	if config.CopyMode || config.AllowDelete || config.DryRun || config.Archive != "" || config.Estimate > 0 ||
+		config.OnlyMissingDate != "" || config.InputZip != "" || config.SampleMetadata > 0 ||
+		config.ListDestinations || config.DryRunCompare != "" || config.Classify {
+		return nil
+	}
+	return errors.New("move mode deletes each source file once it is placed; " +
+		"pass -allow-delete to confirm, or -copy to keep the originals")
+}
+
+// checkOutputRoot verifies that a local output directory can be used. Unless create is set,
+// the parent of a missing output directory must already exist, so an unmounted drive is not
+// silently replaced by a tree on the local disk. A dry run only reports the directory it would create.
+func checkOutputRoot(output string, create, dryRun bool) error {
+	info, err := os.Stat(output)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("output %s exists and is not a directory", output)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("cannot access output %s: %w", output, err)
+	}
+
+	if create && dryRun {
+		logrus.Infof("Dry run: would create output root %s", output)
+		return nil
+	}
+	if create {
+		return os.MkdirAll(output, os.ModePerm)
+	}
+	parent := filepath.Dir(filepath.Clean(output))
+	if _, err := os.Stat(parent); err != nil {
+		return fmt.Errorf("parent of output %s does not exist (is the drive mounted?); use -create-output-root to create it: %w", output, err)
+	}
+	return nil
+}
+
+// writeOutputMarker creates the output root if needed and drops the output marker in it.
+func writeOutputMarker(output string) error {
+	if err := os.MkdirAll(output, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(output, outputMarker), []byte("Organized by media_organizer; skipped when used as input.\n"), 0644)
 }
 
 // showHelp prints a concise usage message and examples.
@@ -120,7 +902,7 @@ func showHelp() {
 Organize media files by date (YYYY/MM) using EXIF data, with optional remote rsync transfer.
 
 Required:
-	-i <dir>        Input directory
+	-i <dir>        Input directory; repeat as -i label=<dir> to keep several sources apart
 	-o <dir|dest>   Output: local directory or
 							remote destination formatted user@host:/remote/path with rsync module
 
@@ -129,19 +911,120 @@ Options:
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, `
 Examples:
-	%s -i /path/to/input -o /path/to/output
+	%s -i /path/to/input -o /path/to/output -allow-delete
 	%s -i /path/to/input -o user@host:/remote/path --copy
 	%s -i /path/to/input -o /path/to/output --dry-run
-`, os.Args[0], os.Args[0], os.Args[0])
+	%s -i Card-A=/media/card-a -i Card-B=/media/card-b -o /path/to/output --copy
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 }
 
 // Run starts the file organization process.
-func (app *App) Run() {
+// With -fail-fast it stops at the first failed file and returns that file's error; with
+// -run-timeout it stops issuing files once the time is up and returns a timeout error.
+// Files already in flight are always finished.
+func (app *App) Run() error {
 	startTime := time.Now()
+	app.ctx, app.cancel = context.WithCancelCause(context.Background())
+	defer app.cancel(nil)
+	if app.Config.RunTimeout > 0 {
+		var stopTimer context.CancelFunc
+		app.ctx, stopTimer = context.WithTimeoutCause(app.ctx, app.Config.RunTimeout, fmt.Errorf("run timed out after %s", app.Config.RunTimeout))
+		defer stopTimer()
+	}
+
+	if app.Config.ReportJSON != "" {
+		app.reportFiles = newReportCollector()
+	}
+	app.targets.conflictsDir = app.Config.OnConflict == onConflictConflictsDir
 
 	// Step 1: Walk the input directory to count files and collect paths.
-	paths, total := app.collectFiles()
+	var paths []string
+	var total int
+	if app.Config.ReprocessErrors != "" {
+		var err error
+		if paths, err = app.failedFiles(app.Config.ReprocessErrors); err != nil {
+			return fmt.Errorf("failed to read failure report: %w", err)
+		}
+		total = len(paths)
+		logrus.Infof("Reprocessing %d files from %s", total, app.Config.ReprocessErrors)
+	} else if app.Config.InputZip != "" {
+		var cleanup func()
+		var err error
+		if paths, cleanup, err = app.openInputZip(); err != nil {
+			return err
+		}
+		defer cleanup()
+		total = len(paths)
+	} else if app.Config.FromSQLite != "" {
+		var err error
+		if paths, err = app.sqliteFiles(); err != nil {
+			return err
+		}
+		total = len(paths)
+		logrus.Infof("Selected %d files from %s", total, app.Config.FromSQLite)
+	} else {
+		paths, total = app.collectFiles()
+	}
 	logrus.Infof("Estimated total files: %d", total)
+	if app.Config.LivePhotoSubfolder {
+		app.livePhotos = findLivePhotoPairs(paths)
+		logrus.Infof("Found %d Live Photo pairs", len(app.livePhotos.videos))
+	}
+
+	if limit := app.dryRunLimit(); limit > 0 && total >= limit {
+		// Walked inputs already stop at the limit; other sources are cut here.
+		logrus.Infof("Previewing the first %d files (-dry-run-limit)", limit)
+		paths, total = paths[:limit], limit
+	}
+
+	if app.Config.OnlyMissingDate != "" {
+		missing, err := app.auditMissingDates(paths, app.Config.OnlyMissingDate)
+		if err != nil {
+			return fmt.Errorf("failed to write missing-date report: %w", err)
+		}
+		logrus.Infof("Found %d of %d files without a date; listed them in %s", missing, total, app.Config.OnlyMissingDate)
+		return nil
+	}
+
+	if app.Config.Estimate > 0 {
+		e, err := app.runEstimate(paths, app.Config.Estimate)
+		if err != nil {
+			logrus.Errorf("Failed to estimate run time: %v", err)
+			return nil
+		}
+		printEstimate(e, total)
+		return nil
+	}
+
+	if app.Config.Classify {
+		printClassification(os.Stdout, app.classifyFiles(paths))
+		return nil
+	}
+
+	if app.Config.SampleMetadata > 0 {
+		samples, read := app.sampleMetadata(paths, app.Config.SampleMetadata)
+		printTagSamples(os.Stdout, samples, read)
+		return nil
+	}
+
+	if app.Config.ListDestinations {
+		list, failed := app.listDestinations(paths)
+		printDestinations(os.Stdout, list, failed)
+		return nil
+	}
+
+	if app.Config.DryRunCompare != "" {
+		entries, failed, err := app.compareWithTree(paths, app.Config.DryRunCompare)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", app.Config.DryRunCompare, err)
+		}
+		printComparison(os.Stdout, app.Config.DryRunCompare, entries, failed)
+		return nil
+	}
+
+	if app.Config.DedupeAcrossDest || app.Duplicates != nil || app.Config.DedupeHardlink {
+		app.indexDestination()
+	}
 
 	bar := progressbar.NewOptions(total,
 		progressbar.OptionSetDescription("Processing"),
@@ -149,36 +1032,138 @@ func (app *App) Run() {
 		progressbar.OptionShowCount(),
 		progressbar.OptionShowIts(),
 		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetWriter(terminal.Bar()),
 	)
 
-	// Step 2: Set up a worker pool to process files concurrently.
-	jobs := make(chan string, app.Config.Buffer)
-	var wg sync.WaitGroup
-
-	for w := 1; w <= app.Config.Workers; w++ {
-		wg.Add(1)
-		go app.worker(w, jobs, &wg, bar)
+	if app.Config.ProgressFile != "" {
+		stop := app.startProgressFile(app.Config.ProgressFile, total)
+		defer stop()
+	}
+	if app.Config.WorkerStats > 0 {
+		stop := app.startWorkerStats(app.Config.WorkerStats)
+		defer stop()
 	}
 
-	// Step 3: Push file paths to the jobs channel.
-	for _, path := range paths {
-		jobs <- path
+	// Step 2: Process files concurrently, planning every target first when the run must be deterministic.
+	if app.usePlan() {
+		app.runPlanned(paths, bar)
+	} else {
+		app.runPool(paths, bar, func(path string) error {
+			return app.track(app.processFile(path))
+		})
 	}
-	close(jobs)
 
-	// Step 4: Wait for all workers to finish.
-	wg.Wait()
+	if app.Config.OutputReadOnlyDirs {
+		app.lockPlacedDirs()
+	}
+	if app.Config.FlattenSparseYears > 0 && app.stopErr() == nil {
+		app.flattenSparseYears()
+	}
+	if app.Config.MoveEmptyToTrash {
+		app.trashEmptiedDirs()
+	}
 
 	elapsed := time.Since(startTime)
 	logrus.Infof("Processing finished. Total files: %d, Elapsed time: %s", total, elapsed)
-}
+	if skipped := app.stats.skipped.Load(); skipped > 0 {
+		logrus.Infof("Skipped %d files whose existing target has a newer EXIF ModifyDate", skipped)
+	}
+	app.logSlowFiles()
+	if err := app.stopErr(); err != nil {
+		processed, failed, skipped := app.stats.processed.Load(), app.stats.failed.Load(), app.stats.skipped.Load()
+		logrus.Warnf("Run stopped early (%v): %d processed, %d failed, %d skipped, %d not started", err,
+			processed, failed, skipped, int64(total)-processed-failed-skipped)
+	}
 
-// collectFiles walks the input directory, counts the files, and returns a slice of file paths.
-func (app *App) collectFiles() ([]string, int) {
-	var paths []string
-	var count int
-	filepath.WalkDir(app.Config.InputPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
+	if app.Config.ReportJSON != "" {
+		if err := writeReport(app.Config.ReportJSON, app.report(startTime, total, elapsed)); err != nil {
+			logrus.Errorf("Failed to write run report %s: %v", app.Config.ReportJSON, err)
+		} else {
+			logrus.Infof("Wrote run report to %s", app.Config.ReportJSON)
+		}
+	}
+
+	if app.Config.NotifyWebhook != "" && (app.stopErr() == nil || app.Config.NotifyOnAbort) {
+		summary := app.summary(total, elapsed)
+		if err := postWebhook(app.Config.NotifyWebhook, summary, webhookTimeout); err != nil {
+			logrus.Errorf("Failed to notify webhook %s: %v", app.Config.NotifyWebhook, err)
+		}
+	}
+	return app.stopErr()
+}
+
+// stop aborts the run with err when -fail-fast is set. Only the first error is kept.
+func (app *App) stop(path string, err error) {
+	if app.Config.FailFast && app.cancel != nil {
+		app.cancel(fmt.Errorf("%s: %w", path, err))
+	}
+}
+
+// abort stops the run with err whether or not -fail-fast is set. Only the first error is kept.
+func (app *App) abort(err error) {
+	logrus.Errorf("Stopping the run: %v", err)
+	if app.cancel != nil {
+		app.cancel(err)
+	}
+}
+
+// stopErr returns the error that aborted the run, or nil if it was not aborted.
+func (app *App) stopErr() error {
+	if app.ctx == nil {
+		return nil
+	}
+	return context.Cause(app.ctx)
+}
+
+// track records the outcome of processing one file in the run statistics.
+func (app *App) track(err error) error {
+	switch {
+	case errors.Is(err, errKeptExisting):
+		app.stats.skipped.Add(1)
+		return nil
+	case err != nil:
+		app.stats.failed.Add(1)
+	default:
+		app.stats.processed.Add(1)
+	}
+	return err
+}
+
+// collectFiles walks the input directories, counts the files, and returns a slice of file paths.
+// With -dry-run-limit the walk stops once that many files are found.
+func (app *App) collectFiles() ([]string, int) {
+	limit := app.dryRunLimit()
+	var seen map[string]bool
+	if len(app.Config.Inputs) > 1 {
+		// Nested inputs would otherwise list the same file twice.
+		seen = make(map[string]bool)
+	}
+	var paths []string
+	for _, in := range app.inputs() {
+		paths = app.walkInput(in.Path, paths, seen, limit)
+		if limit > 0 && len(paths) >= limit {
+			break
+		}
+	}
+	return paths, len(paths)
+}
+
+// dryRunLimit returns how many files a dry run previews, or 0 for all of them.
+func (app *App) dryRunLimit() int {
+	if !app.Config.DryRun {
+		return 0
+	}
+	return app.Config.DryRunLimit
+}
+
+// walkInput appends the files to process under root to paths, leaving out those in seen
+// when it is not nil, and stops once paths holds limit files if limit is positive.
+func (app *App) walkInput(root string, paths []string, seen map[string]bool, limit int) []string {
+	walkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if limit > 0 && len(paths) >= limit {
+			return fs.SkipAll
+		}
+		if err != nil {
 			if os.IsPermission(err) {
 				logrus.Warnf("⚠️ Skipping directory due to permission error: %s", path)
 				return fs.SkipDir
@@ -188,76 +1173,447 @@ func (app *App) collectFiles() ([]string, int) {
 		}
 
 		base := d.Name()
-		if d.IsDir() && (base == ".DocumentRevisions-V100" || base == ".Spotlight-V100" || base == ".fseventsd") {
+		if d.IsDir() && app.isSystemDir(base) {
 			logrus.Warnf("ℹ️ Skipping system folder: %s", path)
 			return fs.SkipDir
 		}
+		if app.Config.SkipHidden && path != root && (strings.HasPrefix(base, ".") || hasHiddenAttribute(path)) {
+			logrus.Debugf("Skipping hidden %s", path)
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && !(app.Config.Rehome && path == root) {
+			if _, err := os.Stat(filepath.Join(path, outputMarker)); err == nil {
+				logrus.Warnf("ℹ️ Skipping organized output folder: %s", path)
+				return fs.SkipDir
+			}
+		}
 
 		if !d.IsDir() {
+			if !app.acceptFile(path, d.Info) {
+				return nil
+			}
+			if seen != nil {
+				if seen[path] {
+					return nil
+				}
+				seen[path] = true
+			}
 			paths = append(paths, path)
-			count++
 		}
 		return nil
 	})
-	return paths, count
+	return paths
+}
+
+// acceptFile applies the per-file input filters: the output marker, -input-glob, -media-type,
+// -modified-after and -min-size/-max-size. info is only called when a filter needs it.
+func (app *App) acceptFile(path string, info func() (fs.FileInfo, error)) bool {
+	base := filepath.Base(path)
+	if base == outputMarker {
+		return false
+	}
+	if !app.matchesInputGlobs(base) {
+		logrus.Debugf("Skipping %s: no -input-glob matches", path)
+		return false
+	}
+	if app.Config.MediaType != "" && app.classifyMedia(path) != app.Config.MediaType {
+		logrus.Debugf("Skipping %s: not of media type %s", path, app.Config.MediaType)
+		return false
+	}
+	if !app.Config.ModifiedAfter.IsZero() || app.Config.MinSize > 0 || app.Config.MaxSize > 0 {
+		fi, err := info()
+		if err != nil {
+			logrus.Warnf("⚠️ Cannot stat %s: %v", path, err)
+			return false
+		}
+		if !app.Config.ModifiedAfter.IsZero() && fi.ModTime().Before(app.Config.ModifiedAfter) {
+			logrus.Debugf("Skipping %s: modified %s, before -modified-after", path, fi.ModTime())
+			return false
+		}
+		if !app.sizeInRange(fi.Size()) {
+			logrus.Infof("Filtered %s: size %d bytes outside -min-size/-max-size", path, fi.Size())
+			return false
+		}
+	}
+	return true
+}
+
+// runPool runs handle for every path on a pool of workers and waits for them to finish.
+func (app *App) runPool(paths []string, bar *progressbar.ProgressBar, handle func(string) error) {
+	// Set up a worker pool to process files concurrently.
+	jobs := make(chan string, app.Config.Buffer)
+	var wg sync.WaitGroup
+	app.pool.start(jobs, app.Config.Workers)
+
+	for w := 1; w <= app.Config.Workers; w++ {
+		wg.Add(1)
+		go app.worker(w, jobs, &wg, bar, handle)
+	}
+
+	// Push file paths to the jobs channel until the run is stopped.
+	for _, path := range paths {
+		if app.stopErr() != nil {
+			break
+		}
+		jobs <- path
+	}
+	close(jobs)
+
+	// Wait for all workers to finish.
+	wg.Wait()
+}
+
+// systemDirs are folders that operating systems and NAS software keep for themselves.
+var systemDirs = []string{
+	".DocumentRevisions-V100", ".Spotlight-V100", ".fseventsd", ".Trashes", ".TemporaryItems", // macOS
+	"$RECYCLE.BIN", "System Volume Information", // Windows
+	"@eaDir", "#recycle", // Synology
+}
+
+// isSystemDir reports whether a folder name is a built-in system folder or a -skip-system-dir.
+// Names are compared case-insensitively, as Windows and NAS shares do not preserve their case reliably.
+func (app *App) isSystemDir(name string) bool {
+	for _, dirs := range [][]string{systemDirs, app.Config.SkipSystemDirs} {
+		for _, dir := range dirs {
+			if strings.EqualFold(name, dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesInputGlobs reports whether a file name matches any -input-glob, or true if none are set.
+func (app *App) matchesInputGlobs(name string) bool {
+	if len(app.Config.InputGlobs) == 0 {
+		return true
+	}
+	for _, pattern := range app.Config.InputGlobs {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeInRange reports whether a file size passes the -min-size and -max-size filters.
+func (app *App) sizeInRange(size int64) bool {
+	if app.Config.MinSize > 0 && size < app.Config.MinSize {
+		return false
+	}
+	if app.Config.MaxSize > 0 && size > app.Config.MaxSize {
+		return false
+	}
+	return true
+}
+
+// parseSize parses a human-readable size such as "50KB", "1.5MB" or "4GB" into bytes.
+// Units are binary (1KB = 1024 bytes); a bare number is taken as bytes.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+
+	str := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(str, unit.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * multiplier), nil
 }
 
 // worker is a routine that processes files from the jobs channel.
-func (app *App) worker(id int, jobs <-chan string, wg *sync.WaitGroup, bar *progressbar.ProgressBar) {
+func (app *App) worker(id int, jobs <-chan string, wg *sync.WaitGroup, bar *progressbar.ProgressBar, handle func(string) error) {
 	defer wg.Done()
 	for path := range jobs {
+		app.checkFreeSpace()
+		if app.stopErr() != nil {
+			continue
+		}
+		app.stats.current.Store(path)
 		if app.Config.Debug {
 			logrus.Debugf("Worker %d handling %s", id, path)
 		}
-		if err := app.processFile(path); err != nil {
+		app.pool.busy.Add(1)
+		if err := app.handleInput(path, handle); err != nil {
 			logrus.Errorf("Failed processing %s: %v", path, err)
+			app.recordFailure(path, err)
+			app.stop(path, err)
 		}
+		app.pool.busy.Add(-1)
+		app.pool.finished(id)
 		bar.Add(1)
 	}
 }
 
+// handleInput runs handle for path, with an -input-zip entry extracted while it runs.
+func (app *App) handleInput(path string, handle func(string) error) error {
+	release, err := app.acquireInput(path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handle(path)
+}
+
+// recordFailure adds a failed file to the -error-report and -report-json outputs.
+func (app *App) recordFailure(path string, failure error) {
+	if app.Errors != nil {
+		if err := app.Errors.Record(path, failure); err != nil {
+			logrus.Errorf("Failed to record failure of %s: %v", path, err)
+		}
+	}
+	if app.reportFiles != nil {
+		app.reportFiles.RecordError(path, failure)
+	}
+}
+
+// recordPlaced counts a placed file by month for -report-json.
+func (app *App) recordPlaced(date time.Time) {
+	if app.reportFiles != nil {
+		app.reportFiles.RecordPlaced(date)
+	}
+}
+
 // processFile handles the logic for a single file: extracting the date, determining the destination, and moving/copying.
 func (app *App) processFile(path string) error {
+	defer app.timeFile(path)()
+	if app.skipDuplicate(path) {
+		return nil
+	}
+	dirs, t, err := app.resolveDirs(path)
+	if errors.Is(err, errSkipFile) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = app.placeFileIn(app.rootFor(path, t), path, dirs)
+	if errors.Is(err, errSkipFile) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	app.recordPlaced(t)
+	return nil
+}
+
+// resolveDirs determines the destination folders for a file, relative to the output root,
+// along with the date they were derived from (zero when the layout is not date based).
+func (app *App) resolveDirs(path string) ([]string, time.Time, error) {
+	if app.Config.Reverse {
+		return nil, time.Time{}, nil
+	}
+	if still, ok := app.livePhotos.videos[path]; ok {
+		dirs, t, err := app.resolveStill(still)
+		if err == nil {
+			return append(append([]string(nil), dirs...), livePhotoDir), t, nil
+		}
+		logrus.Warnf("Cannot place Live Photo video %s with its still %s, using its own date: %v", path, still, err)
+	}
+	if _, ok := app.livePhotos.stills[path]; ok {
+		return app.resolveStill(path)
+	}
+	return app.resolveOwnDirs(path)
+}
+
+// resolveOwnDirs is resolveDirs for a file on its own, ignoring Live Photo pairing.
+func (app *App) resolveOwnDirs(path string) ([]string, time.Time, error) {
+	if app.Config.RouteScript != "" {
+		dirs, err := app.scriptDirs(path)
+		return dirs, time.Time{}, err
+	}
+	dirs, t, err := app.baseDirs(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	in := app.inputFor(path)
+	if app.Config.PreserveTreeUnder {
+		dirs = append([]string{treePrefix(in.Path, path)}, dirs...)
+	}
+	if in.Label != "" {
+		dirs = append([]string{in.Label}, dirs...)
+	}
+	return dirs, t, nil
+}
+
+// baseDirs determines the album or date folders for a file.
+func (app *App) baseDirs(path string) ([]string, time.Time, error) {
+	if app.Config.ByAlbum && internal.ClassifyMedia(path) == internal.MediaAudio {
+		fields, err := app.ExifService.ExtractFields(path)
+		if err != nil {
+			logrus.Warnf("Cannot read audio tags for %s, falling back to date: %v", path, err)
+		} else if dirs, ok := albumDirs(fields); ok {
+			return dirs, time.Time{}, nil
+		}
+	}
+
 	t, err := app.extractDate(path)
 	if err != nil {
+		if _, bad := app.badDates.Load(path); bad && app.Config.QuarantineBadDates {
+			logrus.Warnf("Routing %s to %s: its date cannot be parsed", path, badDatesDirName)
+			return []string{badDatesDirName}, time.Time{}, nil
+		}
 		logrus.Warnf("Cannot extract date for %s: %v", path, err)
-		return err
+		return nil, time.Time{}, err
 	}
 
-	year := fmt.Sprintf("%04d", t.Year())
-	month := fmt.Sprintf("%02d", int(t.Month()))
-	var targetDir string
-	if app.Config.IsRemote {
-		remoteParts := strings.Split(app.Config.OutputPath, ":")
-		remoteHost := remoteParts[0]
-		remoteBaseDir := remoteParts[1]
-		targetDir = filepath.Join(remoteBaseDir, year, month)
-		sshCmd := exec.Command("ssh", remoteHost, "mkdir", "-p", targetDir)
-		if app.Config.Debug {
-			logrus.Debugf("Executing: %s", sshCmd.String())
+	dirs := app.dateDirs(t)
+	if app.Config.ByLens || app.Config.BySoftware || app.Config.ByOrientation || app.Config.ByFaces {
+		fields, err := app.ExifService.ExtractFields(path)
+		if err != nil {
+			logrus.Warnf("Cannot read lens, software, dimension or face tags for %s: %v", path, err)
 		}
-		if err := sshCmd.Run(); err != nil {
-			return fmt.Errorf("failed to create remote dir %s: %w", targetDir, err)
+		if app.Config.ByLens {
+			dirs = append([]string{lensFolder(fields)}, dirs...)
 		}
-	} else {
-		targetDir = filepath.Join(app.Config.OutputPath, year, month)
-		if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
-			return fmt.Errorf("failed to create dir %s: %w", targetDir, err)
+		if app.Config.BySoftware {
+			dirs = append([]string{softwareFolder(fields)}, dirs...)
+		}
+		if app.Config.ByOrientation {
+			dirs = append(dirs, orientationFolder(fields))
+		}
+		if app.Config.ByFaces {
+			dirs = append(dirs, facesFolder(fields))
+		}
+	}
+	if app.Config.TypeSubfolder {
+		dirs = append(dirs, typeFolder(app.classifyMedia(path)))
+	}
+	switch app.Config.ByExtension {
+	case extensionFirst:
+		dirs = append([]string{extensionFolder(path)}, dirs...)
+	case extensionLast:
+		dirs = append(dirs, extensionFolder(path))
+	}
+	return dirs, t, nil
+}
+
+// placeFile moves or copies a file into the folder given by dirs, relative to the output root.
+// Local targets that already exist, or are claimed by another file in this run, get a numeric suffix
+// or, with -on-conflict conflicts-dir, go to the _conflicts folder of the target directory.
+func (app *App) placeFile(path string, dirs []string) error {
+	return app.placeFileIn(app.Config.OutputPath, path, dirs)
+}
+
+// placeFileIn is placeFile for a specific local output root.
+func (app *App) placeFileIn(root, path string, dirs []string) error {
+	targetDir, targetPath := app.targetFor(root, path, dirs)
+	if app.alreadyPlaced(path, targetDir) {
+		targetPath = path
+	} else if !app.Config.IsRemote {
+		var err error
+		if targetPath, err = app.claimTarget(path, targetPath); err != nil {
+			return err
 		}
+		targetDir = filepath.Dir(targetPath)
 	}
+	return app.transfer(root, path, targetDir, targetPath)
+}
 
-	var targetPath string
+// alreadyPlaced reports whether, in -rehome mode, a file already sits in its target directory.
+func (app *App) alreadyPlaced(path, targetDir string) bool {
+	return app.Config.Rehome && filepath.Dir(path) == filepath.Clean(targetDir)
+}
+
+// targetFor computes the target directory and path for a file placed under dirs in root.
+// For remote outputs the directory is the remote-side path and the target is an rsync destination.
+func (app *App) targetFor(root, path string, dirs []string) (string, string) {
 	if app.Config.IsRemote {
-		targetPath = app.Config.OutputPath + "/" + year + "/" + month + "/" + filepath.Base(path)
-	} else {
-		targetPath = filepath.Join(targetDir, filepath.Base(path))
+		remoteBaseDir := strings.SplitN(app.Config.OutputPath, ":", 2)[1]
+		targetDir := filepath.Join(append([]string{remoteBaseDir}, dirs...)...)
+		return targetDir, app.Config.OutputPath + "/" + strings.Join(dirs, "/") + "/" + app.targetName(path)
 	}
+	targetDir := filepath.Join(append([]string{root}, dirs...)...)
+	if app.Config.MergeDuplicateDirs && app.Archive == nil {
+		targetDir = app.canonicalDir(targetDir)
+	}
+	return targetDir, filepath.Join(targetDir, app.targetName(path))
+}
 
+// transfer creates targetDir and moves or copies path to targetPath. root is the local output
+// root targetDir is in, which holds the review folder for -continue-on-mkdir-error.
+func (app *App) transfer(root, path, targetDir, targetPath string) error {
+	if path == targetPath {
+		logrus.Debugf("%s is already in place", path)
+		return nil
+	}
 	if app.Config.DryRun {
+		// Nothing is created in a dry run, but a file blocking the target directory is reported now
+		// rather than failing the real run later.
+		if !app.Config.IsRemote && app.Config.Archive == "" {
+			if err := validateTargetDir(targetDir); err != nil {
+				logrus.Warnf("[DRY-RUN] %s cannot be placed: %v", path, err)
+			}
+		}
 		logrus.Infof("[DRY-RUN] Move: %s → %s (copy=%v)", path, targetPath, app.Config.CopyMode)
+		if !app.Config.CopyMode && app.Config.Archive == "" {
+			app.recordMoved(path)
+		}
 		return nil
 	}
 
+	if app.Config.DirConcurrency > 0 {
+		release := app.dirSlots.Acquire(targetDir, app.Config.DirConcurrency)
+		defer release()
+	}
+
+	if app.Archive != nil {
+		logrus.Infof("Archive: %s → %s", path, targetPath)
+		if err := app.Archive.EnsureDir(targetDir); err != nil {
+			return err
+		}
+		return app.Archive.Upload(path, targetPath)
+	}
+
+	// retries counts the retries of this file's remote commands for -retry-report.
+	retries := 0
+	if app.Config.IsRemote {
+		remoteHost := strings.Split(app.Config.OutputPath, ":")[0]
+		// Check for symlinks first: mkdir -p would already create folders behind one.
+		if !app.Config.FollowDstSymlinks {
+			if err := app.checkRemoteSymlinks(remoteHost, targetDir); err != nil {
+				return err
+			}
+		}
+		// ssh joins its arguments into one shell command, so the path must be quoted.
+		_, n, err := app.runRetried("ssh", remoteHost, "mkdir", "-p", shellQuote(targetDir))
+		retries += n
+		if err != nil {
+			app.recordRetries(path, retries, err)
+			return fmt.Errorf("failed to create remote dir %s: %w", targetDir, err)
+		}
+	} else {
+		if err := mkdirAll(targetDir, os.ModePerm); err != nil {
+			if !app.Config.ContinueOnMkdirError {
+				return fmt.Errorf("failed to create dir %s: %w", targetDir, err)
+			}
+			reviewDir := filepath.Join(root, reviewDirName)
+			logrus.Warnf("Failed to create dir %s, routing %s to %s: %v", targetDir, path, reviewDir, err)
+			if err := mkdirAll(reviewDir, os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create review dir %s: %w", reviewDir, err)
+			}
+			targetPath = app.reserveTarget(filepath.Join(reviewDir, app.targetName(path)))
+		}
+	}
+
 	logrus.Infof("Move: %s → %s (copy=%v)", path, targetPath, app.Config.CopyMode)
 
 	if app.Config.Debug {
@@ -265,35 +1621,152 @@ func (app *App) processFile(path string) error {
 	}
 
 	if app.Config.IsRemote {
-		args := []string{"-aHAXv"}
+		output, n, err := app.runRetried("rsync", app.rsyncArgs(path, targetPath)...)
+		retries += n
+		app.recordRetries(path, retries, err)
+		if err != nil {
+			return fmt.Errorf("failed to rsync %s: %w, output: %s", path, err, string(output))
+		}
 		if !app.Config.CopyMode {
-			args = append(args, "--remove-source-files")
+			app.recordMoved(path)
 		}
-		args = append(args, path, targetPath)
-		rsyncCmd := exec.Command("rsync", args...)
-		if app.Config.Debug {
-			logrus.Debugf("Executing: %s", rsyncCmd.String())
+	} else {
+		if app.Config.DedupeHardlink {
+			canonical, release := app.canonicalCopy(path)
+			defer release()
+			if canonical != "" {
+				return app.linkDuplicate(path, canonical, targetPath)
+			}
 		}
-		if output, err := rsyncCmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to rsync %s: %w, output: %s", path, err, string(output))
+
+		var source fingerprint
+		var err error
+		if app.Config.VerifyAfterMove {
+			if source, err = app.fingerprint(path); err != nil {
+				return err
+			}
 		}
-	} else {
+
 		if app.Config.CopyMode {
-			return copyFile(path, targetPath)
+			err = app.copyTo(path, targetPath)
+		} else {
+			err = app.moveFile(path, targetPath)
+		}
+		if err != nil {
+			return err
 		}
-		return os.Rename(path, targetPath)
+		if !app.Config.CopyMode {
+			app.recordMoved(path)
+		}
+
+		if app.Config.VerifyAfterMove {
+			err = app.verifyPlaced(source, targetPath)
+		}
+		app.afterPlace(path, targetPath)
+		return err
 	}
 
 	return nil
 }
 
-// extractDate extracts the date from a file's metadata.
-func (app *App) extractDate(path string) (time.Time, error) {
-	t, tag, err := app.ExifService.ExtractDate(path, app.Config.Debug, app.Config.UseFileModifyDate)
-	if err != nil {
-		logrus.Errorf("Failed to extract date for %s: %v", path, err)
-		return time.Time{}, err
+// afterPlace runs the bookkeeping for a file that was placed locally at targetPath.
+func (app *App) afterPlace(path, targetPath string) {
+	// Write metadata first: it changes the file content the manifest hashes.
+	rewritten := app.Config.TagOrigin
+	if app.Config.TagOrigin {
+		app.tagOrigin(path, targetPath)
+	}
+	if date, ok := app.inferredDates.Load(path); ok {
+		app.backfillExif(targetPath, date.(time.Time))
+		rewritten = true
+	}
+	if app.Undo != nil {
+		if err := app.Undo.Record(path, targetPath, app.Config.CopyMode); err != nil {
+			logrus.Errorf("Failed to record undo entry for %s: %v", path, err)
+		}
+	}
+	if app.Config.RollbackOnFailure {
+		app.journal.Record(path, targetPath, app.Config.CopyMode)
+	}
+	if app.Manifest != nil {
+		hash, ok := app.hashes.Hash(path)
+		var err error
+		if !ok || rewritten {
+			hash, err = app.hashFile(targetPath)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to hash %s for the manifest: %v", targetPath, err)
+		} else if err := app.Manifest.Add(hash, app.relativeToRoot(targetPath)); err != nil {
+			logrus.Errorf("Failed to record %s in the manifest: %v", targetPath, err)
+		}
+	}
+	if app.Config.DedupeHardlink {
+		if hash, ok := app.hashes.Hash(path); ok {
+			app.destHashes.Add(targetPath, hash)
+		}
+	}
+	if app.Config.OutputReadOnly {
+		if err := os.Chmod(targetPath, 0444); err != nil {
+			logrus.Errorf("Failed to make %s read-only: %v", targetPath, err)
+		}
+		app.placedDirs.Store(filepath.Dir(targetPath), true)
+	}
+}
+
+// lockPlacedDirs makes every folder a file was placed in read-only, for -output-readonly-dirs.
+// It runs once all files are placed, since a read-only folder cannot receive more files.
+func (app *App) lockPlacedDirs() {
+	app.placedDirs.Range(func(key, _ any) bool {
+		if err := os.Chmod(key.(string), 0555); err != nil {
+			logrus.Errorf("Failed to make %s read-only: %v", key, err)
+		}
+		return true
+	})
+}
+
+// validateTargetDir checks, without creating anything, that dir could be created:
+// the nearest existing path component must be a directory.
+func validateTargetDir(dir string) error {
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		info, err := os.Stat(current)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s exists and is not a directory", current)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return nil
+		}
 	}
+}
+
+// dateDirs returns the folder components for a date, YYYY/MM by default, as deep as -precision,
+// or a decade folder with -by-decade, after any -remap-year.
+func (app *App) dateDirs(t time.Time) []string {
+	t = app.remapYear(t)
+	if app.Config.ByDecade {
+		year := fmt.Sprintf("%04d", t.Year())
+		if app.Config.DecadeYears {
+			return []string{decadeFolder(t.Year()), year}
+		}
+		return []string{decadeFolder(t.Year())}
+	}
+	return strings.Split(datePath(t, app.Config.Precision), "/")
+}
+
+// decadeFolder returns the decade folder name for a year, e.g. 1954 → "1950s".
+func decadeFolder(year int) string {
+	return fmt.Sprintf("%ds", (year/10)*10)
+}
+
+// extractDate extracts the date from a file using the configured date sources.
+func (app *App) extractDate(path string) (time.Time, error) {
+	t, tag, chainErr := extractFromChain(app.dateChain(), path)
+	t = app.resolveNaiveDate(t)
 
 	hasDateTimeOriginal := tag == "DateTimeOriginal"
 	if app.Config.OnlyDateTimeOriginal && !hasDateTimeOriginal {
@@ -301,15 +1774,107 @@ func (app *App) extractDate(path string) (time.Time, error) {
 		return time.Time{}, fmt.Errorf("DateTimeOriginal not found")
 	}
 
+	if app.Config.Prefer == preferEarliestOfSources {
+		if ft, ok := internal.ParseFilenameDate(filepath.Base(path)); ok {
+			ft = app.resolveNaiveDate(ft)
+			if earliest := earliestOf(t, ft); !earliest.Equal(t) {
+				logrus.Debugf("Using filename date %s over %s date %s for %s", ft, tag, t, path)
+				t = earliest
+				tag = "Filename"
+			}
+		}
+	}
+
+	if t.IsZero() && chainErr != nil {
+		logrus.Warnf("No valid date found for %s: %v", path, chainErr)
+		return time.Time{}, fmt.Errorf("no valid date found: %w", chainErr)
+	}
 	if t.IsZero() {
 		logrus.Warnf("No valid date found for %s", path)
 		return time.Time{}, fmt.Errorf("no valid date found in EXIF or file system")
 	}
+	if app.Config.ApplyOffset != 0 {
+		t = t.Add(app.Config.ApplyOffset)
+	}
+	if app.Config.BackfillExif && !app.Config.DryRun && isInferredDate(tag) {
+		app.inferredDates.Store(path, t)
+	}
 	return t, nil
 }
 
+// localZone is the time zone naive dates are filed in; tests pin it.
+var localZone = time.Local
+
+// resolveNaiveDate applies -naive-tz to a date parsed without a time zone, which the parsers
+// return in UTC (see internal.IsNaive). With local it keeps the written wall clock as local time, so the file is filed
+// as written; with utc it treats the date as a UTC instant and converts it to local time, which
+// can move it to a neighboring day, month or year. Dates with a zone are returned unchanged.
+func (app *App) resolveNaiveDate(t time.Time) time.Time {
+	if !internal.IsNaive(t) {
+		return t
+	}
+	if app.Config.NaiveTZ == naiveTZUTC {
+		return t.In(localZone)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), localZone)
+}
+
+// earliestOf returns the earlier of two dates, ignoring zero values.
+func earliestOf(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// moveFile renames src to dst, falling back to a copy and remove when they are on different file systems.
+func (app *App) moveFile(src, dst string) error {
+	err := renameFile(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	logrus.Debugf("Cannot rename %s across file systems, copying instead", src)
+	if err := app.copyLocal(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := app.removeSource(src); err != nil {
+		return fmt.Errorf("copied to %s but failed to remove the source: %w", dst, err)
+	}
+	return nil
+}
+
+// removeSource deletes a moved file's source. With -force-remove, a source that cannot be
+// removed for lack of permission is made writable and removed again.
+func (app *App) removeSource(path string) error {
+	err := removeFile(path)
+	if err == nil || !errors.Is(err, fs.ErrPermission) || !app.Config.ForceRemove {
+		return err
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil {
+		return err
+	}
+	logrus.Warnf("Adding write permission to read-only source %s to remove it (-force-remove)", path)
+	if err := os.Chmod(path, info.Mode().Perm()|0200); err != nil {
+		return fmt.Errorf("failed to make %s writable: %w", path, err)
+	}
+	return removeFile(path)
+}
+
 // copyFile copies a file from a source to a destination.
-func copyFile(src, dst string) error {
+func (app *App) copyFile(src, dst string) error {
+	defer app.acquireFiles(2)()
+	return app.copyContents(src, dst)
+}
+
+// copyContents is copyFile for a caller that already holds the open file slots.
+func (app *App) copyContents(src, dst string) error {
+	if app.Config.ResumePartial {
+		return app.copyResumable(src, dst)
+	}
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -322,7 +1887,13 @@ func copyFile(src, dst string) error {
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, in); err != nil {
+	var w io.Writer = out
+	if info, err := in.Stat(); err == nil {
+		var done func()
+		w, done = app.withCopyProgress(out, src, info.Size())
+		defer done()
+	}
+	if _, err := io.Copy(w, app.throttled(in)); err != nil {
 		return err
 	}
 
@@ -2,8 +2,20 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"media_organizer/src/internal"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -119,3 +131,831 @@ func TestNewConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDecadeFolder(t *testing.T) {
+	testCases := []struct {
+		year     int
+		expected string
+	}{
+		{1954, "1950s"},
+		{1960, "1960s"},
+		{1999, "1990s"},
+		{2001, "2000s"},
+	}
+
+	for _, tc := range testCases {
+		if got := decadeFolder(tc.year); got != tc.expected {
+			t.Errorf("Expected decade folder %v for %d, but got %v", tc.expected, tc.year, got)
+		}
+	}
+}
+
+func TestDateDirs(t *testing.T) {
+	date := time.Date(1954, 7, 4, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		config   *Config
+		expected string
+	}{
+		{"Year and month", &Config{}, "1954/07"},
+		{"Decade", &Config{ByDecade: true}, "1950s"},
+		{"Decade with years", &Config{ByDecade: true, DecadeYears: true}, "1950s/1954"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &App{Config: tc.config}
+			if got := strings.Join(app.dateDirs(date), "/"); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEarliestOf(t *testing.T) {
+	exifDate := time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)
+	filenameDate := time.Date(2021, 6, 30, 8, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		a, b     time.Time
+		expected time.Time
+	}{
+		{"Filename date precedes EXIF", exifDate, filenameDate, filenameDate},
+		{"EXIF precedes filename date", filenameDate, exifDate, filenameDate},
+		{"Missing EXIF date", time.Time{}, filenameDate, filenameDate},
+		{"Missing filename date", exifDate, time.Time{}, exifDate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := earliestOf(tc.a, tc.b); !got.Equal(tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// fakeExif serves dates and fields from fixtures instead of running exiftool.
+type fakeExif struct {
+	dates  map[string]time.Time
+	fields map[string]map[string]interface{}
+}
+
+func (f *fakeExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	if t, ok := f.dates[filepath.Base(path)]; ok {
+		return t, "DateTimeOriginal", nil
+	}
+	return time.Time{}, "", nil
+}
+
+func (f *fakeExif) ExtractFields(path string) (map[string]interface{}, error) {
+	return f.fields[filepath.Base(path)], nil
+}
+
+// writeFiles creates files with their own relative path as content under dir.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+}
+
+// readTree maps each file's relative path under dir to its content.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		tree[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	return tree
+}
+
+func TestOpenLogFilePerRun(t *testing.T) {
+	dir := t.TempDir()
+	runs := []time.Time{
+		time.Date(2024, 7, 4, 10, 30, 0, 0, time.Local),
+		time.Date(2024, 7, 5, 10, 30, 0, 0, time.Local),
+	}
+	for _, perRun := range []bool{false, true} {
+		for _, start := range runs {
+			f, err := openLogFile(dir, perRun, start)
+			if err != nil {
+				t.Fatalf("openLogFile failed: %v", err)
+			}
+			f.WriteString("run\n")
+			f.Close()
+		}
+	}
+
+	expected := map[string]string{
+		"sortbydate.log":                 "run\nrun\n",
+		"sortbydate-20240704-103000.log": "run\n",
+		"sortbydate-20240705-103000.log": "run\n",
+	}
+	if got := readTree(t, dir); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected log files %v, but got %v", expected, got)
+	}
+}
+
+func TestResolveLogLevel(t *testing.T) {
+	testCases := []struct {
+		level    string
+		debug    bool
+		expected logrus.Level
+		hasError bool
+	}{
+		{"trace", false, logrus.TraceLevel, false},
+		{"debug", false, logrus.DebugLevel, false},
+		{"info", false, logrus.InfoLevel, false},
+		{"warn", false, logrus.WarnLevel, false},
+		{"error", false, logrus.ErrorLevel, false},
+		{"info", true, logrus.DebugLevel, false},
+		{"trace", true, logrus.TraceLevel, false},
+		{"verbose", false, 0, true},
+	}
+
+	for _, tc := range testCases {
+		level, err := resolveLogLevel(tc.level, tc.debug)
+		if tc.hasError {
+			if err == nil {
+				t.Errorf("Expected an error for %q, but got nil", tc.level)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tc.level, err)
+		}
+		if level != tc.expected {
+			t.Errorf("Expected level %v for %q (debug=%v), but got %v", tc.expected, tc.level, tc.debug, level)
+		}
+	}
+}
+
+func TestCollectFilesModifiedAfter(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "old.jpg", "new.jpg")
+
+	old := time.Date(2020, 6, 1, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(inputDir, "old.jpg"), old, old); err != nil {
+		t.Fatalf("Failed to set file time: %v", err)
+	}
+
+	app := &App{Config: &Config{
+		InputPath:     inputDir,
+		ModifiedAfter: time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local),
+	}}
+	paths, count := app.collectFiles()
+
+	if count != 1 || len(paths) != 1 || filepath.Base(paths[0]) != "new.jpg" {
+		t.Errorf("Expected only new.jpg to be collected, but got %v", paths)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+		hasError bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"50KB", 50 * 1024, false},
+		{"50kb", 50 * 1024, false},
+		{"1.5MB", 1536 * 1024, false},
+		{"4GB", 4 << 30, false},
+		{"2T", 2 << 40, false},
+		{"10 MB", 10 << 20, false},
+		{"", 0, true},
+		{"big", 0, true},
+		{"-5MB", 0, true},
+	}
+
+	for _, tc := range testCases {
+		size, err := parseSize(tc.input)
+		if tc.hasError {
+			if err == nil {
+				t.Errorf("Expected an error for %q, but got nil", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tc.input, err)
+		}
+		if size != tc.expected {
+			t.Errorf("Expected %d bytes for %q, but got %d", tc.expected, tc.input, size)
+		}
+	}
+}
+
+func TestCollectFilesSizeFilter(t *testing.T) {
+	inputDir := t.TempDir()
+	for name, size := range map[string]int{"thumb.jpg": 100, "photo.jpg": 2048, "huge.mov": 8192} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	app := &App{Config: &Config{InputPath: inputDir, MinSize: 1024, MaxSize: 4096}}
+	paths, _ := app.collectFiles()
+
+	if len(paths) != 1 || filepath.Base(paths[0]) != "photo.jpg" {
+		t.Errorf("Expected only photo.jpg to pass the size filter, but got %v", paths)
+	}
+}
+
+func TestContinueOnMkdirError(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "good.jpg", "bad.jpg")
+
+	blocked := filepath.Join(outputDir, "2020", "01")
+	originalMkdirAll := mkdirAll
+	defer func() { mkdirAll = originalMkdirAll }()
+	mkdirAll = func(path string, perm os.FileMode) error {
+		if path == blocked {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrPermission}
+		}
+		return originalMkdirAll(path, perm)
+	}
+
+	dates := map[string]time.Time{
+		"good.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		"bad.jpg":  time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	for _, continueOnError := range []bool{false, true} {
+		app := &App{Config: &Config{OutputPath: outputDir, CopyMode: true, ContinueOnMkdirError: continueOnError}}
+		for name, date := range dates {
+			err := app.placeFile(filepath.Join(inputDir, name), app.dateDirs(date))
+			if name == "bad.jpg" && !continueOnError {
+				if err == nil {
+					t.Errorf("Expected mkdir failure to fail %s without the flag", name)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("Unexpected error for %s (continue=%v): %v", name, continueOnError, err)
+			}
+		}
+	}
+
+	tree := readTree(t, outputDir)
+	if tree["_review/bad.jpg"] != "bad.jpg" {
+		t.Errorf("Expected bad.jpg to be routed to the review dir, but got %v", tree)
+	}
+	if tree["2021/07/good.jpg"] != "good.jpg" {
+		t.Errorf("Expected good.jpg in its date folder, but got %v", tree)
+	}
+}
+
+func TestCheckOutputRoot(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, "file.txt")
+
+	testCases := []struct {
+		name     string
+		output   string
+		create   bool
+		dryRun   bool
+		hasError bool
+	}{
+		{"Existing directory", tempDir, false, false, false},
+		{"Missing output with existing parent", filepath.Join(tempDir, "photos"), false, false, false},
+		{"Missing parent", filepath.Join(tempDir, "backup", "photos"), false, false, true},
+		{"Missing parent with create", filepath.Join(tempDir, "created", "photos"), true, false, false},
+		{"Missing parent with create in a dry run", filepath.Join(tempDir, "planned", "photos"), true, true, false},
+		{"Output is a file", filepath.Join(tempDir, "file.txt"), false, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkOutputRoot(tc.output, tc.create, tc.dryRun)
+			if tc.hasError && err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+			if !tc.hasError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "backup")); !os.IsNotExist(err) {
+		t.Errorf("Expected missing parent not to be created, but got %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(tempDir, "created", "photos")); err != nil || !info.IsDir() {
+		t.Errorf("Expected output root to be created with -create-output-root, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "planned")); !os.IsNotExist(err) {
+		t.Errorf("Expected a dry run not to create the output root, but got %v", err)
+	}
+}
+
+func TestCheckDeleteAllowed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   Config
+		hasError bool
+	}{
+		{"Move without -allow-delete", Config{}, true},
+		{"Move with -allow-delete", Config{AllowDelete: true}, false},
+		{"Copy", Config{CopyMode: true}, false},
+		{"Dry run", Config{DryRun: true}, false},
+		{"Archive", Config{Archive: "out.zip"}, false},
+		{"Rehome without -allow-delete", Config{Rehome: true}, true},
+		{"Input zip", Config{InputZip: "photos.zip"}, false},
+		{"Sample metadata", Config{SampleMetadata: 10}, false},
+		{"List destinations", Config{ListDestinations: true}, false},
+		{"Dry-run compare", Config{DryRunCompare: "/photos"}, false},
+		{"Classify", Config{Classify: true}, false},
+		{"Estimate", Config{Estimate: 10}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDeleteAllowed(&tc.config)
+			if tc.hasError && (err == nil || !strings.Contains(err.Error(), "-allow-delete")) {
+				t.Errorf("Expected an error mentioning -allow-delete, but got %v", err)
+			}
+			if !tc.hasError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWritesOutputRoot(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   Config
+		expected bool
+	}{
+		{"Move", Config{}, true},
+		{"Dry run", Config{DryRun: true}, true},
+		{"Remote", Config{IsRemote: true}, false},
+		{"Archive", Config{Archive: "out.zip"}, false},
+		{"Missing-date audit", Config{OnlyMissingDate: "missing.csv"}, false},
+		{"Sample", Config{SampleMetadata: 5}, false},
+		{"List destinations", Config{ListDestinations: true}, false},
+		{"Dry-run compare", Config{DryRunCompare: "/photos"}, false},
+		{"Classify", Config{Classify: true}, false},
+	}
+
+	for _, tc := range testCases {
+		if got := writesOutputRoot(&tc.config); got != tc.expected {
+			t.Errorf("Expected %v for %s, but got %v", tc.expected, tc.name, got)
+		}
+	}
+}
+
+func TestCollectFilesSkipsOutputMarker(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "new.jpg", "organized/2021/07/old.jpg")
+	if err := writeOutputMarker(filepath.Join(inputDir, "organized")); err != nil {
+		t.Fatalf("writeOutputMarker failed: %v", err)
+	}
+
+	app := &App{Config: &Config{InputPath: inputDir}}
+	paths, _ := app.collectFiles()
+
+	if len(paths) != 1 || filepath.Base(paths[0]) != "new.jpg" {
+		t.Errorf("Expected only new.jpg to be collected, but got %v", paths)
+	}
+}
+
+func TestValidateTargetDir(t *testing.T) {
+	outputDir := t.TempDir()
+	writeFiles(t, outputDir, "2021", "2022/01/existing.jpg")
+
+	testCases := []struct {
+		name     string
+		dir      string
+		hasError bool
+	}{
+		{"Existing directory", filepath.Join(outputDir, "2022", "01"), false},
+		{"Missing directory", filepath.Join(outputDir, "2023", "05"), false},
+		{"File blocks year", filepath.Join(outputDir, "2021", "07"), true},
+		{"File blocks month", filepath.Join(outputDir, "2022", "01", "existing.jpg", "x"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTargetDir(tc.dir)
+			if tc.hasError && err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+			if !tc.hasError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDryRunCreatesNoDirectories(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.jpg")
+
+	app := &App{Config: &Config{OutputPath: outputDir, DryRun: true}}
+	if err := app.placeFile(filepath.Join(inputDir, "IMG_0001.jpg"), []string{"2021", "07"}); err != nil {
+		t.Fatalf("placeFile failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "2021")); !os.IsNotExist(err) {
+		t.Errorf("Expected dry run not to create directories, but got %v", err)
+	}
+}
+
+func TestApplyOffset(t *testing.T) {
+	exif := &fakeExif{dates: map[string]time.Time{
+		"late.jpg":  time.Date(2021, 7, 31, 22, 30, 0, 0, time.UTC),
+		"early.jpg": time.Date(2021, 8, 1, 1, 0, 0, 0, time.UTC),
+	}}
+
+	testCases := []struct {
+		name     string
+		file     string
+		offset   time.Duration
+		expected string
+	}{
+		{"No offset", "late.jpg", 0, "2021/07"},
+		{"Clock behind by 3h", "late.jpg", 3 * time.Hour, "2021/08"},
+		{"Clock ahead by 3h", "early.jpg", -3 * time.Hour, "2021/07"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &App{Config: &Config{ApplyOffset: tc.offset}, ExifService: exif}
+			dirs, _, err := app.resolveDirs("/input/" + tc.file)
+			if err != nil {
+				t.Fatalf("resolveDirs failed: %v", err)
+			}
+			if got := strings.Join(dirs, "/"); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNaiveTZ(t *testing.T) {
+	originalZone := localZone
+	defer func() { localZone = originalZone }()
+	localZone = time.FixedZone("CEST", 2*60*60)
+
+	exif := &fakeExif{dates: map[string]time.Time{
+		// Parsed without a time zone, as the EXIF parser returns it.
+		"naive.jpg": time.Date(2021, 7, 31, 23, 30, 0, 0, time.UTC),
+		"zoned.jpg": time.Date(2021, 7, 31, 23, 30, 0, 0, time.FixedZone("", -5*60*60)),
+	}}
+	// An explicit "Z" is a zone too, even though time.Parse reports it as UTC.
+	zulu, err := internal.ParseExifDate("2021-07-31T23:30:00Z")
+	if err != nil {
+		t.Fatalf("ParseExifDate failed: %v", err)
+	}
+	exif.dates["zulu.jpg"] = zulu
+
+	testCases := []struct {
+		name     string
+		file     string
+		policy   string
+		expected string
+	}{
+		{"Naive date as local", "naive.jpg", naiveTZLocal, "2021/07"},
+		{"Naive date as UTC", "naive.jpg", naiveTZUTC, "2021/08"},
+		{"Unset policy is local", "naive.jpg", "", "2021/07"},
+		{"Zoned date ignores policy", "zoned.jpg", naiveTZUTC, "2021/07"},
+		{"Z date ignores policy", "zulu.jpg", naiveTZUTC, "2021/07"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &App{Config: &Config{NaiveTZ: tc.policy}, ExifService: exif}
+			dirs, _, err := app.resolveDirs("/input/" + tc.file)
+			if err != nil {
+				t.Fatalf("resolveDirs failed: %v", err)
+			}
+			if got := strings.Join(dirs, "/"); got != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a_undated.jpg", "b.jpg", "c.jpg", "d.jpg")
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	exif := &fakeExif{dates: map[string]time.Time{"b.jpg": date, "c.jpg": date, "d.jpg": date}}
+
+	for _, failFast := range []bool{false, true} {
+		outputDir := t.TempDir()
+		app := &App{
+			Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 1, Buffer: 1, CopyMode: true, FailFast: failFast},
+			ExifService: exif,
+		}
+		err := app.Run()
+		placed := len(readTree(t, outputDir))
+
+		if !failFast {
+			if err != nil || placed != 3 {
+				t.Errorf("Expected no error and 3 placed files without -fail-fast, but got %v and %d", err, placed)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "a_undated.jpg") {
+			t.Errorf("Expected the error for a_undated.jpg, but got %v", err)
+		}
+		if placed != 0 {
+			t.Errorf("Expected the run to stop before placing files, but got %d placed", placed)
+		}
+	}
+}
+
+func TestRehome(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		root := t.TempDir()
+		writeFiles(t, root, "2021/07/misfiled.jpg", "2021/08/correct.jpg")
+		if err := writeOutputMarker(root); err != nil {
+			t.Fatalf("Failed to write output marker: %v", err)
+		}
+
+		august := time.Date(2021, 8, 1, 9, 0, 0, 0, time.UTC)
+		app := &App{
+			Config: &Config{
+				InputPath:     root,
+				OutputPath:    root,
+				Workers:       2,
+				Buffer:        1,
+				Rehome:        true,
+				Deterministic: deterministic,
+			},
+			ExifService: &fakeExif{dates: map[string]time.Time{"misfiled.jpg": august, "correct.jpg": august}},
+		}
+		app.Run()
+
+		expected := map[string]string{
+			"2021/08/misfiled.jpg": "2021/07/misfiled.jpg",
+			"2021/08/correct.jpg":  "2021/08/correct.jpg",
+		}
+		got := readTree(t, root)
+		delete(got, outputMarker)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected tree %v (deterministic=%v), but got %v", expected, deterministic, got)
+		}
+	}
+}
+
+func TestForceRemoveReadOnlySource(t *testing.T) {
+	originalRename, originalRemove := renameFile, removeFile
+	defer func() { renameFile, removeFile = originalRename, originalRemove }()
+
+	// Simulate a move across file systems, and a platform that refuses to delete read-only files.
+	renameFile = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	removeFile = func(path string) error {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0200 == 0 {
+			return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrPermission}
+		}
+		return originalRemove(path)
+	}
+
+	for _, force := range []bool{false, true} {
+		inputDir := t.TempDir()
+		outputDir := t.TempDir()
+		writeFiles(t, inputDir, "locked.jpg")
+		source := filepath.Join(inputDir, "locked.jpg")
+		if err := os.Chmod(source, 0444); err != nil {
+			t.Fatalf("Failed to make source read-only: %v", err)
+		}
+
+		app := &App{Config: &Config{OutputPath: outputDir, ForceRemove: force}}
+		err := app.placeFile(source, []string{"2021", "07"})
+
+		_, statErr := os.Stat(source)
+		if force {
+			if err != nil || !os.IsNotExist(statErr) {
+				t.Errorf("Expected the read-only source to be removed with -force-remove, but got %v (source stat: %v)", err, statErr)
+			}
+		} else if err == nil || statErr != nil {
+			t.Errorf("Expected the removal to fail and keep the source without -force-remove, but got %v (source stat: %v)", err, statErr)
+		}
+		if tree := readTree(t, outputDir); tree["2021/07/locked.jpg"] != "locked.jpg" {
+			t.Errorf("Expected the file to be copied to its target, but got %v", tree)
+		}
+	}
+}
+
+func TestCollectFilesSkipHidden(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "photo.jpg", ".hidden.jpg", ".thumbnails/photo.jpg", "album/shot.jpg")
+
+	for _, skip := range []bool{false, true} {
+		app := &App{Config: &Config{InputPath: inputDir, SkipHidden: skip}}
+		paths, _ := app.collectFiles()
+
+		var got []string
+		for _, path := range paths {
+			rel, _ := filepath.Rel(inputDir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		expected := []string{".hidden.jpg", ".thumbnails/photo.jpg", "album/shot.jpg", "photo.jpg"}
+		if skip {
+			expected = []string{"album/shot.jpg", "photo.jpg"}
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v with skip-hidden=%v, but got %v", expected, skip, got)
+		}
+	}
+}
+
+func TestOutputReadOnly(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.jpg", "b.jpg")
+	monthDir := filepath.Join(outputDir, "2021", "07")
+	// Let t.TempDir clean up the locked folder.
+	t.Cleanup(func() { os.Chmod(monthDir, 0755) })
+
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	app := &App{
+		Config: &Config{
+			InputPath:          inputDir,
+			OutputPath:         outputDir,
+			Workers:            2,
+			Buffer:             1,
+			CopyMode:           true,
+			OutputReadOnly:     true,
+			OutputReadOnlyDirs: true,
+		},
+		ExifService: &fakeExif{dates: map[string]time.Time{"a.jpg": date, "b.jpg": date}},
+	}
+	app.Run()
+
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		info, err := os.Stat(filepath.Join(monthDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be placed: %v", name, err)
+		}
+		if info.Mode().Perm() != 0444 {
+			t.Errorf("Expected %s to be 0444, but got %v", name, info.Mode().Perm())
+		}
+	}
+	if info, err := os.Stat(monthDir); err != nil {
+		t.Errorf("Failed to stat %s: %v", monthDir, err)
+	} else if info.Mode().Perm() != 0555 {
+		t.Errorf("Expected %s to be 0555, but got %v", monthDir, info.Mode().Perm())
+	}
+	if info, err := os.Stat(filepath.Join(inputDir, "a.jpg")); err != nil {
+		t.Errorf("Failed to stat the source: %v", err)
+	} else if info.Mode().Perm() == 0444 {
+		t.Errorf("Expected the source to keep its permissions, but got %v", info.Mode().Perm())
+	}
+}
+
+func TestCollectFilesInputGlob(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "IMG_0001.JPG", "album/IMG_0002.JPG", "DSC_0001.JPG", "IMG_0003.png", "clip.mp4")
+
+	testCases := []struct {
+		globs    []string
+		expected []string
+	}{
+		{nil, []string{"DSC_0001.JPG", "IMG_0001.JPG", "IMG_0002.JPG", "IMG_0003.png", "clip.mp4"}},
+		{[]string{"IMG_*.JPG"}, []string{"IMG_0001.JPG", "IMG_0002.JPG"}},
+		{[]string{"IMG_*.JPG", "*.mp4"}, []string{"IMG_0001.JPG", "IMG_0002.JPG", "clip.mp4"}},
+		{[]string{"*.heic"}, nil},
+	}
+
+	for _, tc := range testCases {
+		app := &App{Config: &Config{InputPath: inputDir, InputGlobs: tc.globs}}
+		paths, _ := app.collectFiles()
+		var got []string
+		for _, path := range paths {
+			got = append(got, filepath.Base(path))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Expected %v for globs %v, but got %v", tc.expected, tc.globs, got)
+		}
+	}
+}
+
+func TestCollectFilesSkipSystemDirs(t *testing.T) {
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "photo.jpg", ".Spotlight-V100/store.db", "@eaDir/photo.jpg/SYNOFILE_THUMB_M.jpg",
+		"$Recycle.Bin/old.jpg", "Lightroom Previews/preview.jpg", "album/shot.jpg")
+
+	testCases := []struct {
+		custom   []string
+		expected []string
+	}{
+		{nil, []string{"Lightroom Previews/preview.jpg", "album/shot.jpg", "photo.jpg"}},
+		{[]string{"Lightroom Previews"}, []string{"album/shot.jpg", "photo.jpg"}},
+	}
+
+	for _, tc := range testCases {
+		app := &App{Config: &Config{InputPath: inputDir, SkipSystemDirs: tc.custom}}
+		paths, _ := app.collectFiles()
+		var got []string
+		for _, path := range paths {
+			rel, _ := filepath.Rel(inputDir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Expected %v with -skip-system-dir %v, but got %v", tc.expected, tc.custom, got)
+		}
+	}
+}
+
+// slowExif is fakeExif with a fixed delay per file.
+type slowExif struct {
+	fakeExif
+	delay time.Duration
+}
+
+func (s *slowExif) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
+	time.Sleep(s.delay)
+	return s.fakeExif.ExtractDate(path, debug, useFileModifyDate)
+}
+
+func TestRunTimeout(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	dates := make(map[string]time.Time)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("IMG_%04d.jpg", i)
+		writeFiles(t, inputDir, name)
+		dates[name] = time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	}
+
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 1, Buffer: 1, CopyMode: true, RunTimeout: 50 * time.Millisecond},
+		ExifService: &slowExif{fakeExif: fakeExif{dates: dates}, delay: 20 * time.Millisecond},
+	}
+	err := app.Run()
+
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, but got %v", err)
+	}
+	processed := app.stats.processed.Load()
+	if processed == 0 || processed >= 20 {
+		t.Errorf("Expected a partial run, but %d of 20 files were processed", processed)
+	}
+	// Every file that was started is finished, so nothing is left half-copied.
+	if placed := len(readTree(t, outputDir)); int64(placed) != processed {
+		t.Errorf("Expected %d placed files, but got %d", processed, placed)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeFiles(t, inputDir, "2021/07/IMG_0001.jpg", "2021/08/IMG_0001.jpg", "2022/01/IMG_0002.jpg", "_review/clip.mov")
+
+	// No dates are known: -reverse must not need them.
+	app := &App{
+		Config:      &Config{InputPath: inputDir, OutputPath: outputDir, Workers: 4, Buffer: 10, CopyMode: true, Reverse: true},
+		ExifService: &fakeExif{},
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	got := readTree(t, outputDir)
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 files in the output, but got %v", got)
+	}
+	contents := make(map[string]bool)
+	for rel, content := range got {
+		if filepath.Dir(rel) != "." {
+			t.Errorf("Expected %s directly in the output root", rel)
+		}
+		contents[content] = true
+	}
+	for _, want := range []string{"2021/07/IMG_0001.jpg", "2021/08/IMG_0001.jpg", "2022/01/IMG_0002.jpg", "_review/clip.mov"} {
+		if !contents[want] {
+			t.Errorf("Expected the content of %s in the output, but got %v", want, got)
+		}
+	}
+	if _, ok := got["IMG_0001-1.jpg"]; !ok {
+		t.Errorf("Expected the second IMG_0001.jpg to be suffixed, but got %v", got)
+	}
+}
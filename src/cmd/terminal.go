@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// terminal is shared by the progress bars and, in debug mode, the log, so log lines
+// are printed above the bar instead of over it.
+var terminal = &terminalWriter{out: os.Stdout}
+
+// terminalWriter serializes log lines and progress bar renders on one output.
+// Before a log line is written the bar's line is cleared, and the bar is redrawn below it.
+type terminalWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+	// barLine is the last rendered bar, or empty if no bar is on screen.
+	barLine []byte
+}
+
+// Write writes a log line above the progress bar.
+func (w *terminalWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.barLine) > 0 {
+		io.WriteString(w.out, "\r\033[K")
+	}
+	n, err := w.out.Write(p)
+	if err == nil && len(w.barLine) > 0 {
+		w.out.Write(w.barLine)
+	}
+	return n, err
+}
+
+// Bar returns the writer progress bars render through.
+func (w *terminalWriter) Bar() io.Writer {
+	return barWriter{w}
+}
+
+// barWriter records each bar render so it can be redrawn after a log line.
+type barWriter struct {
+	w *terminalWriter
+}
+
+func (b barWriter) Write(p []byte) (int, error) {
+	b.w.mu.Lock()
+	defer b.w.mu.Unlock()
+
+	// Renders start with '\r'; a newline means the bar is finished and stays on screen.
+	line := p
+	if i := bytes.LastIndexAny(line, "\r\n"); i >= 0 {
+		if line[i] == '\n' {
+			b.w.barLine = nil
+			return b.w.out.Write(p)
+		}
+		line = line[i+1:]
+	} else {
+		line = append(append([]byte(nil), b.w.barLine...), line...)
+	}
+	b.w.barLine = append([]byte(nil), bytes.TrimRight(line, " ")...)
+	return b.w.out.Write(p)
+}
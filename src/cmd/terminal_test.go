@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTerminalWriterKeepsLogLinesOffTheBar(t *testing.T) {
+	var buf bytes.Buffer
+	term := &terminalWriter{out: &buf}
+
+	bar := progressbar.NewOptions(100,
+		progressbar.OptionSetDescription("Processing"),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetWriter(term.Bar()),
+	)
+	logger := logrus.New()
+	logger.SetOutput(term)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableColors: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				logger.Infof("worker %d file %d", worker, j)
+				bar.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	logLines := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.Contains(line, "msg=") {
+			continue
+		}
+		logLines++
+		// Whatever the bar drew on this line must have been cleared before the log text.
+		visible := line[strings.LastIndex(line, "\r")+1:]
+		visible = strings.TrimPrefix(visible, "\033[K")
+		if !strings.HasPrefix(visible, "level=info msg=") {
+			t.Errorf("Expected a log line on its own, but got %q", visible)
+		}
+	}
+	if logLines != 100 {
+		t.Errorf("Expected 100 log lines, but got %d", logLines)
+	}
+	if !strings.Contains(buf.String(), "100/100") {
+		t.Errorf("Expected the bar to reach 100/100, but got %q", buf.String())
+	}
+}
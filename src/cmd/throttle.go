@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// copyThrottle caps the throughput of local copies for -throughput-cap-mbps. Its bucket is built
+// from the config on first use and shared by all workers; nil means unlimited.
+type copyThrottle struct {
+	once   sync.Once
+	bucket *tokenBucket
+}
+
+// throttleBurst is how much unused throughput the bucket saves up while copies are idle.
+const throttleBurst = 100 * time.Millisecond
+
+// tokenBucket is a token-bucket rate limiter in bytes per second. Readers take tokens for the
+// bytes they have read and sleep off any debt, so the combined rate of all readers stays at the cap.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a bucket allowing bytesPerSecond, starting empty.
+func newTokenBucket(bytesPerSecond float64) *tokenBucket {
+	return &tokenBucket{rate: bytesPerSecond, last: time.Now()}
+}
+
+// Take accounts for n bytes, sleeping until the bucket is no longer in debt.
+func (b *tokenBucket) Take(n int) {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	if limit := throttleBurst.Seconds() * b.rate; b.tokens > limit {
+		b.tokens = limit
+	}
+	b.tokens -= float64(n)
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// throttledReader is a reader that draws from a tokenBucket.
+type throttledReader struct {
+	r      io.Reader
+	bucket *tokenBucket
+}
+
+// throttleChunk bounds a single read, so one worker cannot take a large share of the bucket at once.
+const throttleChunk = 64 * 1024
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.bucket.Take(n)
+	}
+	return n, err
+}
+
+// throttled wraps r with the run's copy throttle, or returns it unchanged when there is no cap.
+func (app *App) throttled(r io.Reader) io.Reader {
+	t := &app.throttle
+	t.once.Do(func() {
+		if app.Config.ThroughputCapMbps > 0 {
+			t.bucket = newTokenBucket(app.Config.ThroughputCapMbps * 1e6 / 8)
+		}
+	})
+	if t.bucket == nil {
+		return r
+	}
+	return &throttledReader{r: r, bucket: t.bucket}
+}
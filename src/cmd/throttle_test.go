@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThroughputCap(t *testing.T) {
+	const rate = 2 << 20 // bytes per second
+
+	app := &App{Config: &Config{ThroughputCapMbps: rate * 8 / 1e6}}
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("x"), 200<<10)
+	const files = 3
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		src := filepath.Join(dir, fmt.Sprintf("src-%d", i))
+		if err := os.WriteFile(src, data, 0644); err != nil {
+			t.Fatalf("Failed to write source: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.copyFile(src, src+".copy"); err != nil {
+				t.Errorf("copyFile failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := float64(files * len(data))
+	if got := total / elapsed.Seconds(); got > rate*1.05 {
+		t.Errorf("Expected at most %d bytes/s across workers, but got %.0f over %v", rate, got, elapsed)
+	}
+	for i := 0; i < files; i++ {
+		copied, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("src-%d.copy", i)))
+		if err != nil || !bytes.Equal(copied, data) {
+			t.Errorf("Expected copy %d to match its source, but got %d bytes (%v)", i, len(copied), err)
+		}
+	}
+}
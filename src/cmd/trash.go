@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// moveDirToTrash moves a folder to the OS trash; tests substitute a stub.
+var moveDirToTrash = trashDir
+
+// recordMoved remembers a source that was moved away, or would be in a dry run,
+// for -move-empty-to-trash-after.
+func (app *App) recordMoved(path string) {
+	if app.Config.MoveEmptyToTrash {
+		app.movedSources.Store(path, true)
+	}
+}
+
+// trashEmptiedDirs moves the input folders that the run left without files to the OS trash.
+// Only folders that held a moved file, and their parents below the input root, are considered;
+// a folder whose parent is trashed goes with it. A dry run only reports them.
+func (app *App) trashEmptiedDirs() {
+	root := filepath.Clean(app.Config.InputPath)
+	candidates := make(map[string]bool)
+	app.movedSources.Range(func(key, _ any) bool {
+		for dir := filepath.Dir(key.(string)); ; dir = filepath.Dir(dir) {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || candidates[dir] {
+				break
+			}
+			candidates[dir] = true
+		}
+		return true
+	})
+
+	dirs := make([]string, 0, len(candidates))
+	for dir := range candidates {
+		dirs = append(dirs, dir)
+	}
+	// Parents sort before their children.
+	sort.Strings(dirs)
+
+	var trashed []string
+	for _, dir := range dirs {
+		if len(trashed) > 0 && isWithin(dir, trashed[len(trashed)-1]) {
+			continue
+		}
+		if !app.onlyMovedFiles(dir) {
+			continue
+		}
+		if app.Config.DryRun {
+			logrus.Infof("[DRY-RUN] Would move emptied folder %s to the trash", dir)
+			trashed = append(trashed, dir)
+			continue
+		}
+		if err := moveDirToTrash(dir); err != nil {
+			logrus.Warnf("Failed to move emptied folder %s to the trash: %v", dir, err)
+			continue
+		}
+		logrus.Infof("Moved emptied folder %s to the trash", dir)
+		trashed = append(trashed, dir)
+	}
+	if len(trashed) > 0 {
+		logrus.Infof("%d emptied source folders moved to the trash", len(trashed))
+	}
+}
+
+// onlyMovedFiles reports whether every file below dir was moved by the run, so that
+// after the run dir holds at most empty folders.
+func (app *App) onlyMovedFiles(dir string) bool {
+	only := true
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, moved := app.movedSources.Load(path); !moved {
+			only = false
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return only && err == nil
+}
+
+// isWithin reports whether path is dir or below it.
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// trashDir moves a folder to the user's ~/.Trash, adding a number if the name is taken.
+func trashDir(path string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	base := filepath.Base(path)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = base + " " + strconv.Itoa(n)
+		}
+		target := filepath.Join(home, ".Trash", name)
+		if _, err := os.Lstat(target); errors.Is(err, os.ErrNotExist) {
+			return os.Rename(path, target)
+		}
+	}
+}
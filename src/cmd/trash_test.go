@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMoveEmptyToTrash(t *testing.T) {
+	originalTrash := moveDirToTrash
+	defer func() { moveDirToTrash = originalTrash }()
+
+	date := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	// undated.jpg has no date, fails and keeps its folder.
+	exif := &fakeExif{dates: map[string]time.Time{"1.jpg": date, "2.jpg": date, "3.jpg": date, "4.jpg": date}}
+
+	for _, dryRun := range []bool{false, true} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "4.jpg", "trip/1.jpg", "trip/day2/2.jpg", "mixed/3.jpg", "mixed/undated.jpg")
+		if err := os.MkdirAll(filepath.Join(inputDir, "trip", "empty"), 0755); err != nil {
+			t.Fatalf("Failed to create empty dir: %v", err)
+		}
+
+		var trashed []string
+		moveDirToTrash = func(path string) error {
+			trashed = append(trashed, path)
+			return os.RemoveAll(path)
+		}
+
+		app := &App{
+			Config: &Config{
+				InputPath:        inputDir,
+				OutputPath:       t.TempDir(),
+				Workers:          2,
+				Buffer:           1,
+				DryRun:           dryRun,
+				MoveEmptyToTrash: true,
+			},
+			ExifService: exif,
+		}
+		app.Run()
+
+		var expected []string
+		if !dryRun {
+			// trip goes as a whole, with its emptied and already empty subfolders.
+			expected = []string{filepath.Join(inputDir, "trip")}
+		}
+		sort.Strings(trashed)
+		if !reflect.DeepEqual(trashed, expected) {
+			t.Errorf("Expected %v to be trashed with dry-run=%v, but got %v", expected, dryRun, trashed)
+		}
+		if _, err := os.Stat(filepath.Join(inputDir, "mixed", "undated.jpg")); err != nil {
+			t.Errorf("Expected the folder with a remaining file to be kept, but got %v", err)
+		}
+		if _, err := os.Stat(inputDir); err != nil {
+			t.Errorf("Expected the input root to be kept, but got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(inputDir, "trip")); dryRun && err != nil {
+			t.Errorf("Expected a dry run to keep trip, but got %v", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// trashDir moves a folder to the home trash of the freedesktop.org trash specification,
+// writing the .trashinfo file that lets desktop file managers restore it.
+func trashDir(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	trash := os.Getenv("XDG_DATA_HOME")
+	if trash == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		trash = filepath.Join(home, ".local", "share")
+	}
+	trash = filepath.Join(trash, "Trash")
+	for _, dir := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(trash, dir), 0700); err != nil {
+			return err
+		}
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	base := filepath.Base(abs)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = base + "." + strconv.Itoa(n)
+		}
+		// Claiming the info file first reserves the name, as the specification requires.
+		infoPath := filepath.Join(trash, "info", name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(info)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(abs, filepath.Join(trash, "files", name))
+		}
+		if err != nil {
+			os.Remove(infoPath)
+		}
+		return err
+	}
+}
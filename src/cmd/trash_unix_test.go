@@ -0,0 +1,39 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrashDirFreedesktop(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	for i := 0; i < 2; i++ {
+		dir := filepath.Join(t.TempDir(), "old album")
+		writeFiles(t, dir, "sub/.keep")
+		if err := trashDir(dir); err != nil {
+			t.Fatalf("trashDir failed: %v", err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be gone, but got %v", dir, err)
+		}
+	}
+
+	for _, name := range []string{"old album", "old album.2"} {
+		if _, err := os.Stat(filepath.Join(dataHome, "Trash", "files", name, "sub", ".keep")); err != nil {
+			t.Errorf("Expected %s in the trash, but got %v", name, err)
+		}
+		info, err := os.ReadFile(filepath.Join(dataHome, "Trash", "info", name+".trashinfo"))
+		if err != nil {
+			t.Fatalf("Failed to read trash info: %v", err)
+		}
+		if !strings.HasPrefix(string(info), "[Trash Info]\nPath=/") || !strings.Contains(string(info), "old%20album\n") {
+			t.Errorf("Unexpected trash info for %s: %q", name, info)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// trashDir sends a folder to the Recycle Bin through the .NET FileSystem API, run by PowerShell.
+func trashDir(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	script := "Add-Type -AssemblyName Microsoft.VisualBasic; " +
+		"[Microsoft.VisualBasic.FileIO.FileSystem]::DeleteDirectory('" + strings.ReplaceAll(abs, "'", "''") +
+		"', 'OnlyErrorDialogs', 'SendToRecycleBin')"
+	if output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UndoWriter records every completed move or copy as a shell command that reverses it.
+// Each entry is written straight to the file so the script stays usable if the run is interrupted.
+type UndoWriter struct {
+	out *SafeWriter
+}
+
+// NewUndoWriter creates an executable undo script at path.
+func NewUndoWriter(path string, checkpointEvery int) (*UndoWriter, error) {
+	out, err := CreateSafeWriter(path, 0755, checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteLine("#!/bin/sh\n# Generated by media_organizer: reverses the operations of a run."); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return &UndoWriter{out: out}, nil
+}
+
+// Record appends the command reversing a src → dst operation.
+// Moves are undone with mv, copies by removing the copy.
+// dst must be the final path the file was written to, so renamed targets are undone correctly.
+func (u *UndoWriter) Record(src, dst string, copied bool) error {
+	if copied {
+		return u.out.WriteLine(fmt.Sprintf("rm -f -- %s", shellQuote(dst)))
+	}
+	return u.out.WriteLine(fmt.Sprintf("mv -n -- %s %s", shellQuote(dst), shellQuote(src)))
+}
+
+// Close closes the underlying script file.
+func (u *UndoWriter) Close() error {
+	return u.out.Close()
+}
+
+// shellQuote quotes s for safe use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// undoEntry is one completed operation held in an undoJournal.
+type undoEntry struct {
+	src, dst string
+	copied   bool
+}
+
+// undoJournal keeps the completed operations of a run in memory so -rollback-on-failure can
+// reverse them. The zero value is ready to use.
+type undoJournal struct {
+	mu      sync.Mutex
+	entries []undoEntry
+}
+
+// Record adds a completed src → dst operation.
+func (j *undoJournal) Record(src, dst string, copied bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, undoEntry{src: src, dst: dst, copied: copied})
+}
+
+// Rollback reverses the recorded operations, newest first: moved files are moved back and
+// copies are removed. It returns how many were reversed; failures are logged and skipped.
+func (app *App) rollback() int {
+	j := &app.journal
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	reversed := 0
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := j.entries[i]
+		var err error
+		if entry.copied {
+			err = removeFile(entry.dst)
+		} else if err = mkdirAll(filepath.Dir(entry.src), os.ModePerm); err == nil {
+			err = app.moveFile(entry.dst, entry.src)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to roll back %s → %s: %v", entry.src, entry.dst, err)
+			continue
+		}
+		reversed++
+	}
+	j.entries = nil
+	return reversed
+}
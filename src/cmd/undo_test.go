@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUndoScriptReversesMoves(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("Skipping test: sh not available")
+	}
+
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	outputDir := filepath.Join(tempDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input dir: %v", err)
+	}
+
+	files := map[string]time.Time{
+		"a.jpg":         time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+		"b.jpg":         time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		"it's here.jpg": time.Date(2022, 1, 2, 10, 0, 0, 0, time.UTC),
+	}
+	for name := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	scriptPath := filepath.Join(tempDir, "undo.sh")
+	undo, err := NewUndoWriter(scriptPath, 1)
+	if err != nil {
+		t.Fatalf("NewUndoWriter failed: %v", err)
+	}
+
+	app := &App{Config: &Config{OutputPath: outputDir}, Undo: undo}
+	for name, date := range files {
+		if err := app.placeFile(filepath.Join(inputDir, name), app.dateDirs(date)); err != nil {
+			t.Fatalf("placeFile failed for %s: %v", name, err)
+		}
+	}
+	undo.Close()
+
+	if _, err := os.Stat(filepath.Join(outputDir, "2021", "07", "a.jpg")); err != nil {
+		t.Fatalf("Expected a.jpg to be moved: %v", err)
+	}
+
+	if output, err := exec.Command("sh", scriptPath).CombinedOutput(); err != nil {
+		t.Fatalf("Undo script failed: %v, output: %s", err, string(output))
+	}
+
+	for name := range files {
+		data, err := os.ReadFile(filepath.Join(inputDir, name))
+		if err != nil {
+			t.Errorf("Expected %s to be restored, but got %v", name, err)
+			continue
+		}
+		if string(data) != name {
+			t.Errorf("Expected restored content %q, but got %q", name, string(data))
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"/a/b.jpg", `'/a/b.jpg'`},
+		{"it's.jpg", `'it'\''s.jpg'`},
+	}
+
+	for _, tc := range testCases {
+		if got := shellQuote(tc.input); got != tc.expected {
+			t.Errorf("Expected %v, but got %v", tc.expected, got)
+		}
+	}
+}
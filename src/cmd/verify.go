@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// statFile stats local files for -verify-after-move; tests replace it to simulate a
+// destination that differs from its source.
+var statFile = os.Stat
+
+// fingerprint is what -verify-after-move compares between a source and its destination:
+// the size, and with -verify-hash the SHA-256 of the content.
+type fingerprint struct {
+	size int64
+	hash string
+}
+
+// fingerprint records a source file before it is moved or copied.
+func (app *App) fingerprint(path string) (fingerprint, error) {
+	info, err := statFile(path)
+	if err != nil {
+		return fingerprint{}, err
+	}
+	fp := fingerprint{size: info.Size()}
+	if app.Config.VerifyHash {
+		hash, ok := app.hashes.Hash(path)
+		if !ok {
+			if hash, err = app.hashFile(path); err != nil {
+				return fingerprint{}, err
+			}
+		}
+		fp.hash = hash
+	}
+	return fp, nil
+}
+
+// verifyPlaced checks that the file at targetPath matches the source fingerprint.
+func (app *App) verifyPlaced(source fingerprint, targetPath string) error {
+	info, err := statFile(targetPath)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", targetPath, err)
+	}
+	if info.Size() != source.size {
+		return fmt.Errorf("verify %s: size is %d bytes, expected %d", targetPath, info.Size(), source.size)
+	}
+	if source.hash != "" {
+		hash, err := app.hashFile(targetPath)
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", targetPath, err)
+		}
+		if hash != source.hash {
+			return fmt.Errorf("verify %s: SHA-256 %s, expected %s", targetPath, hash, source.hash)
+		}
+	}
+	return nil
+}
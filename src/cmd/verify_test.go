@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sizedInfo reports a different size for a file.
+type sizedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i sizedInfo) Size() int64 { return i.size }
+
+func TestVerifyAfterMove(t *testing.T) {
+	originalStat := statFile
+	defer func() { statFile = originalStat }()
+
+	testCases := []struct {
+		name      string
+		copyMode  bool
+		hash      bool
+		corrupt   bool
+		truncated bool
+		wantErr   string
+	}{
+		{"Move matches", false, false, false, false, ""},
+		{"Copy matches with hash", true, true, false, false, ""},
+		{"Size mismatch", false, false, false, true, "size is"},
+		{"Hash mismatch", true, true, true, false, "SHA-256"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			outputDir := t.TempDir()
+			writeFiles(t, inputDir, "IMG_0001.jpg")
+			target := filepath.Join(outputDir, "2021", "07", "IMG_0001.jpg")
+
+			statFile = func(path string) (os.FileInfo, error) {
+				info, err := originalStat(path)
+				if err == nil && tc.truncated && path == target {
+					return sizedInfo{info, info.Size() - 1}, nil
+				}
+				if err == nil && tc.corrupt && path == target {
+					// Same size, different content.
+					os.WriteFile(path, []byte(strings.Repeat("x", int(info.Size()))), 0644)
+				}
+				return info, err
+			}
+
+			app := &App{
+				Config: &Config{OutputPath: outputDir, CopyMode: tc.copyMode, VerifyAfterMove: true, VerifyHash: tc.hash},
+				ExifService: &fakeExif{dates: map[string]time.Time{
+					"IMG_0001.jpg": time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC),
+				}},
+			}
+			err := app.processFile(filepath.Join(inputDir, "IMG_0001.jpg"))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected verification to pass, but got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected a %q verification error, but got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
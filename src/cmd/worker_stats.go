@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// workerStatsTicker delivers the times -worker-stats logs at; tests replace it to control the clock.
+var workerStatsTicker = func(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// poolStats tracks the worker pool that is currently running, for -worker-stats.
+type poolStats struct {
+	mu   sync.Mutex
+	jobs chan string
+	// handled counts the files each worker has finished, indexed by worker id - 1.
+	handled []atomic.Int64
+	busy    atomic.Int64
+}
+
+// start records the queue and worker count of a new pool.
+func (p *poolStats) start(jobs chan string, workers int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs = jobs
+	p.handled = make([]atomic.Int64, workers)
+	p.busy.Store(0)
+}
+
+// finished counts a file finished by worker id.
+func (p *poolStats) finished(id int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id >= 1 && id <= len(p.handled) {
+		p.handled[id-1].Add(1)
+	}
+}
+
+// workerStatsLine describes the pool: files per second since the previous line, how full the
+// queue is, how many workers are idle, and how many files each worker has finished.
+func (app *App) workerStatsLine(processed int64, elapsed time.Duration) string {
+	p := &app.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(processed) / elapsed.Seconds()
+	}
+	queued, capacity := 0, 0
+	if p.jobs != nil {
+		queued, capacity = len(p.jobs), cap(p.jobs)
+	}
+	perWorker := make([]string, len(p.handled))
+	for i := range p.handled {
+		perWorker[i] = fmt.Sprint(p.handled[i].Load())
+	}
+	idle := int64(len(p.handled)) - p.busy.Load()
+	return fmt.Sprintf("Worker stats: %.1f files/s, queue %d/%d, %d of %d workers idle, files per worker [%s]",
+		rate, queued, capacity, idle, len(p.handled), strings.Join(perWorker, " "))
+}
+
+// startWorkerStats logs a workerStatsLine every interval until the returned function is called.
+func (app *App) startWorkerStats(interval time.Duration) func() {
+	ticks, stopTicker := workerStatsTicker(interval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer stopTicker()
+		var last time.Time
+		var lastProcessed int64
+		for {
+			select {
+			case now := <-ticks:
+				processed := app.stats.processed.Load() + app.stats.failed.Load()
+				elapsed := interval
+				if !last.IsZero() {
+					elapsed = now.Sub(last)
+				}
+				logrus.Info(app.workerStatsLine(processed-lastProcessed, elapsed))
+				last, lastProcessed = now, processed
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
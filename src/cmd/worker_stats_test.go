@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWorkerStats(t *testing.T) {
+	ticks := make(chan time.Time)
+	origTicker := workerStatsTicker
+	defer func() { workerStatsTicker = origTicker }()
+	workerStatsTicker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+
+	var logs bytes.Buffer
+	origOut := logrus.StandardLogger().Out
+	defer logrus.SetOutput(origOut)
+	logrus.SetOutput(&logs)
+
+	app := &App{Config: &Config{}}
+	jobs := make(chan string, 4)
+	jobs <- "/in/a.jpg"
+	jobs <- "/in/b.jpg"
+	app.pool.start(jobs, 3)
+	app.pool.busy.Add(1)
+	for _, id := range []int{1, 1, 2} {
+		app.pool.finished(id)
+	}
+
+	stop := app.startWorkerStats(10 * time.Second)
+	start := time.Date(2021, 7, 4, 10, 0, 0, 0, time.UTC)
+	ticks <- start
+	app.stats.processed.Add(15)
+	app.stats.failed.Add(5)
+	ticks <- start.Add(10 * time.Second)
+	stop()
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 stats lines, but got %q", logs.String())
+	}
+	if !strings.Contains(lines[0], "0.0 files/s") {
+		t.Errorf("Expected no throughput before any file finished, but got %q", lines[0])
+	}
+	// 20 files finished in the 10s between the ticks.
+	if want := "2.0 files/s, queue 2/4, 2 of 3 workers idle, files per worker [2 1 0]"; !strings.Contains(lines[1], want) {
+		t.Errorf("Expected %q in the stats line, but got %q", want, lines[1])
+	}
+}
@@ -4,6 +4,11 @@ package internal
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,10 +18,24 @@ import (
 
 // ExifToolService wraps the go-exiftool library to provide a thread-safe service for extracting dates from media files.
 type ExifToolService struct {
-	et *exiftool.Exiftool
-	mu sync.Mutex
+	et        metadataExtractor
+	mu        sync.Mutex
+	extraTags []string
+	// priorityTags are checked before all others (-date-tag-priority).
+	priorityTags []string
+	cache        *fieldsCache
 }
 
+// metadataExtractor is the part of *exiftool.Exiftool the service uses.
+type metadataExtractor interface {
+	ExtractMetadata(files ...string) []exiftool.FileMetadata
+	Close() error
+}
+
+// VideoVendorDateTags are nonstandard date fields some phones write into MP4 containers
+// (e.g. Samsung and Pixel videos). They are checked for videos after the standard tags.
+var VideoVendorDateTags = []string{"ContentCreateDate", "MediaCreateDate", "TrackCreateDate", "Date"}
+
 // NewExifToolService creates and initializes a new ExifToolService.
 // It starts the underlying exiftool process.
 func NewExifToolService() (*ExifToolService, error) {
@@ -24,23 +43,44 @@ func NewExifToolService() (*ExifToolService, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize exiftool: %w", err)
 	}
-	return &ExifToolService{et: et}, nil
+	return newExifToolService(et), nil
+}
+
+func newExifToolService(et metadataExtractor) *ExifToolService {
+	return &ExifToolService{et: et, cache: newFieldsCache(DefaultFieldsCacheSize)}
+}
+
+// SetFieldsCacheSize sets how many files' metadata is kept, so that the date and every
+// field-based routing decision for a file come from a single exiftool call. 0 disables the cache.
+func (s *ExifToolService) SetFieldsCacheSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = newFieldsCache(size)
+}
+
+// SetExtraDateTags adds user-supplied date tags, checked after the built-in ones.
+func (s *ExifToolService) SetExtraDateTags(tags []string) {
+	s.extraTags = tags
+}
+
+// SetDateTagPriority sets date tags checked before all others, in the given order, so a vendor
+// or extra tag can outrank the built-in ones. Tags not listed keep their default order after them.
+func (s *ExifToolService) SetDateTagPriority(tags []string) {
+	s.priorityTags = tags
 }
 
 // ExtractDate extracts the date from a media file using exiftool.
-// It checks for common date tags ("DateTimeOriginal", "CreateDate", "DateCreated")
-// and optionally "FileModifyDate".
+// It checks the tags returned by DateTags in order.
 // The first valid date found is returned.
 func (s *ExifToolService) ExtractDate(path string, debug bool, useFileModifyDate bool) (time.Time, string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	fileInfos := s.et.ExtractMetadata(path)
-	if len(fileInfos) == 0 {
+	fi, ok := s.metadata(path)
+	if !ok {
 		logrus.Warnf("[EXIF] No metadata extracted for %s", path)
 		return time.Time{}, "", nil
 	}
-	fi := fileInfos[0]
 
 	// Log all metadata as JSON if debug mode is enabled
 	if debug {
@@ -52,48 +92,239 @@ func (s *ExifToolService) ExtractDate(path string, debug bool, useFileModifyDate
 		}
 	}
 
-	// Define the list of tags to check for a date
+	tags := s.DateTags(path, useFileModifyDate)
+	if t, tag := DateFromFields(fi.Fields, tags, path); !t.IsZero() {
+		return t, tag, nil
+	}
+	if err := UnparseableDate(fi.Fields, tags); err != nil {
+		return time.Time{}, "", err
+	}
+
+	logrus.Infof("[EXIF] No valid date found in metadata for %s", path)
+	return time.Time{}, "", nil
+}
+
+// ExtractDateFromReader extracts the date from media read from r, such as an archive entry.
+// exiftool needs a path, so the data is written to a temporary file, removed before returning,
+// whose extension is taken from name so the file type picks the same date tags as on disk.
+// FileModifyDate is never used, as it would be the time of the temporary file.
+func (s *ExifToolService) ExtractDateFromReader(r io.Reader, name string) (time.Time, string, error) {
+	tmp, err := os.CreateTemp("", "exif-*"+filepath.Ext(name))
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to create temp file for %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to write temp file for %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The temporary path is never seen again, so it bypasses the fields cache.
+	fileInfos := s.et.ExtractMetadata(tmp.Name())
+	if len(fileInfos) == 0 {
+		logrus.Warnf("[EXIF] No metadata extracted for %s", name)
+		return time.Time{}, "", nil
+	}
+	if fileInfos[0].Err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to read metadata of %s: %w", name, fileInfos[0].Err)
+	}
+
+	tags := s.DateTags(name, false)
+	if t, tag := DateFromFields(fileInfos[0].Fields, tags, name); !t.IsZero() {
+		return t, tag, nil
+	}
+	if err := UnparseableDate(fileInfos[0].Fields, tags); err != nil {
+		return time.Time{}, "", err
+	}
+	logrus.Infof("[EXIF] No valid date found in metadata for %s", name)
+	return time.Time{}, "", nil
+}
+
+// UnparseableDateError reports a file that has a date tag whose value ParseExifDate does not understand,
+// as opposed to a file with no date tag at all.
+type UnparseableDateError struct {
+	Tag   string
+	Value string
+}
+
+func (e *UnparseableDateError) Error() string {
+	return fmt.Sprintf("unparseable %s date %q", e.Tag, e.Value)
+}
+
+// UnparseableDate returns an *UnparseableDateError for the first of tags that holds a date string
+// ParseExifDate rejects, or nil. Zeroed placeholders such as "0000:00:00 00:00:00", which cameras
+// write when they have no date, count as no date rather than a bad one.
+func UnparseableDate(fields map[string]interface{}, tags []string) error {
+	for _, tag := range tags {
+		value, ok := fields[tag].(string)
+		if !ok || strings.Trim(value, "0: ") == "" {
+			continue
+		}
+		if _, err := ParseExifDate(value); err != nil {
+			return &UnparseableDateError{Tag: tag, Value: value}
+		}
+	}
+	return nil
+}
+
+// DateTags returns the date tags checked for a file, in priority order: the common tags
+// ("DateTimeOriginal", "CreateDate", "DateCreated"), with QuickTime "CreationDate" ahead of
+// "CreateDate" for videos, "ModifyDate" for documents, vendor fields for videos, any extra tags,
+// and optionally "FileModifyDate". Tags set with SetDateTagPriority come first.
+func (s *ExifToolService) DateTags(path string, useFileModifyDate bool) []string {
 	tags := []string{"DateTimeOriginal", "CreateDate", "DateCreated"}
+	media := ClassifyMedia(path)
+	if media == MediaVideo {
+		// iPhone videos store CreateDate in UTC, but CreationDate in local time with its offset
+		// (e.g. "2021:07:04 22:30:00-04:00"), so only CreationDate files them on the right day.
+		tags = []string{"DateTimeOriginal", "CreationDate", "CreateDate", "DateCreated"}
+	}
+	switch media {
+	case MediaDocument:
+		// PDFs only carry CreateDate/ModifyDate; ModifyDate is still better than no date at all.
+		tags = append(tags, "ModifyDate")
+	case MediaVideo:
+		tags = append(tags, VideoVendorDateTags...)
+	}
+	tags = append(tags, s.extraTags...)
 	if useFileModifyDate {
 		tags = append(tags, "FileModifyDate")
 	}
+	if len(s.priorityTags) == 0 {
+		return tags
+	}
+	ordered := append([]string(nil), s.priorityTags...)
+	for _, tag := range tags {
+		if !slices.Contains(s.priorityTags, tag) {
+			ordered = append(ordered, tag)
+		}
+	}
+	return ordered
+}
 
-	// Iterate through the tags and try to parse the date
+// DateFromFields returns the first date among tags that parses, and the tag it came from.
+// path is only used for logging.
+func DateFromFields(fields map[string]interface{}, tags []string, path string) (time.Time, string) {
 	for _, tag := range tags {
-		if val, found := fi.Fields[tag]; found {
+		if val, found := fields[tag]; found {
 			if dateStr, ok := val.(string); ok {
 				if t, err := ParseExifDate(dateStr); err == nil {
-					return t, tag, nil
+					return t, tag
 				} else {
 					logrus.Warnf("[EXIF] Error parsing date '%s' for tag '%s' in file %s: %v", dateStr, tag, path, err)
 				}
 			}
 		}
 	}
+	return time.Time{}, ""
+}
 
-	logrus.Infof("[EXIF] No valid date found in metadata for %s", path)
-	return time.Time{}, "", nil
+// ExtractFields returns the raw metadata fields exiftool reports for a file.
+func (s *ExifToolService) ExtractFields(path string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fi, ok := s.metadata(path)
+	if !ok {
+		return nil, fmt.Errorf("no metadata extracted for %s", path)
+	}
+	if fi.Err != nil {
+		return nil, fi.Err
+	}
+	return fi.Fields, nil
+}
+
+// metadata returns the metadata of a file, running exiftool only if it is not cached.
+// The caller must hold s.mu.
+func (s *ExifToolService) metadata(path string) (exiftool.FileMetadata, bool) {
+	if fi, ok := s.cache.get(path); ok {
+		return fi, true
+	}
+	fileInfos := s.et.ExtractMetadata(path)
+	if len(fileInfos) == 0 {
+		return exiftool.FileMetadata{}, false
+	}
+	s.cache.put(path, fileInfos[0])
+	return fileInfos[0], true
+}
+
+// ZonedUTC is the location of dates written with an explicit UTC zone such as "Z".
+// time.Parse returns those in time.UTC, which is also where dates written without a zone end up,
+// so they are moved to a location of their own to tell the two apart.
+var ZonedUTC = time.FixedZone("UTC", 0)
+
+// IsNaive reports whether t was parsed from a date written without a time zone.
+// The parsers return those in time.UTC.
+func IsNaive(t time.Time) bool {
+	return !t.IsZero() && t.Location() == time.UTC
+}
+
+// dateLayout is a layout tried by the date parsers; zoned layouts carry a time zone.
+type dateLayout struct {
+	layout string
+	zoned  bool
+}
+
+// parseLayouts returns the first layout that parses value. Dates in UTC from a zoned layout
+// are returned in ZonedUTC.
+func parseLayouts(layouts []dateLayout, value string) (time.Time, bool) {
+	for _, l := range layouts {
+		if t, err := time.Parse(l.layout, value); err == nil {
+			if l.zoned && t.Location() == time.UTC {
+				t = t.In(ZonedUTC)
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
 
 // ParseExifDate parses a date string from EXIF metadata.
-// It supports multiple common date formats.
+// It supports multiple common date formats, including raw PDF dates (D:YYYYMMDDHHMMSS).
+// Dates without a time zone are returned in time.UTC; see IsNaive.
 func ParseExifDate(dateStr string) (time.Time, error) {
-	// List of supported date formats
-	layouts := []string{
-		"2006:01:02 15:04:05-07:00", // With timezone
-		"2006:01:02 15:04:05",       // Without timezone
-		"2006:01:02",                // Date only
+	if strings.HasPrefix(dateStr, "D:") {
+		return parsePDFDate(dateStr)
 	}
 
-	for _, layout := range layouts {
-		if t, err := time.Parse(layout, dateStr); err == nil {
-			return t, nil
-		}
+	// List of supported date formats
+	layouts := []dateLayout{
+		{"2006:01:02 15:04:05-07:00", true},    // With timezone
+		{"2006:01:02 15:04:05", false},         // Without timezone
+		{"2006:01:02", false},                  // Date only
+		{"2006-01-02T15:04:05Z07:00", true},    // ISO 8601, as in MP4 vendor date fields
+		{"2006-01-02T15:04:05.000Z0700", true}, // ISO 8601 with milliseconds
+		{"20060102T150405.000Z", true},         // Compact Android MP4 date, always UTC
 	}
 
+	if t, ok := parseLayouts(layouts, dateStr); ok {
+		return t, nil
+	}
 	return time.Time{}, fmt.Errorf("unrecognized date format: %s", dateStr)
 }
 
+// parsePDFDate parses a PDF date such as "D:20210704103000+02'00'".
+// The timezone may be "Z", "+HH'mm'" or absent.
+func parsePDFDate(dateStr string) (time.Time, error) {
+	value := strings.ReplaceAll(strings.TrimPrefix(dateStr, "D:"), "'", "")
+	layouts := []dateLayout{
+		{"20060102150405Z0700", true}, // With timezone or Z
+		{"20060102150405", false},     // Without timezone
+		{"20060102", false},           // Date only
+	}
+
+	if t, ok := parseLayouts(layouts, value); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized PDF date format: %s", dateStr)
+}
+
 // Close terminates the underlying exiftool process.
 func (s *ExifToolService) Close() {
 	s.et.Close()
@@ -2,11 +2,15 @@
 package internal
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/barasher/go-exiftool"
 )
 
 func TestExtractDate(t *testing.T) {
@@ -104,6 +108,12 @@ func TestParseExifDate(t *testing.T) {
 			expected: time.Date(2023, 1, 1, 12, 0, 0, 0, time.FixedZone("", -5*60*60)),
 			hasError: false,
 		},
+		{
+			name:     "QuickTime CreationDate with subseconds",
+			dateStr:  "2021:07:04 22:30:00.123-04:00",
+			expected: time.Date(2021, 7, 4, 22, 30, 0, 123000000, time.FixedZone("", -4*60*60)),
+			hasError: false,
+		},
 		{
 			name:     "Valid date without timezone",
 			dateStr:  "2023:01:01 12:00:00",
@@ -116,6 +126,35 @@ func TestParseExifDate(t *testing.T) {
 			expected: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
 			hasError: false,
 		},
+		{
+			name:     "PDF date",
+			dateStr:  "D:20210704103000",
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC),
+			hasError: false,
+		},
+		{
+			name:     "PDF date with timezone",
+			dateStr:  "D:20210704103000+02'00'",
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.FixedZone("", 2*60*60)),
+			hasError: false,
+		},
+		{
+			name:     "PDF date in UTC",
+			dateStr:  "D:20210704103000Z",
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC),
+			hasError: false,
+		},
+		{
+			name:     "PDF date only",
+			dateStr:  "D:20210704",
+			expected: time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC),
+			hasError: false,
+		},
+		{
+			name:     "Invalid PDF date",
+			dateStr:  "D:2021",
+			hasError: true,
+		},
 		{
 			name:     "Invalid date format",
 			dateStr:  "2023-01-01 12:00:00",
@@ -142,3 +181,249 @@ func TestParseExifDate(t *testing.T) {
 		})
 	}
 }
+
+func TestDateFromFieldsVendorVideo(t *testing.T) {
+	service := &ExifToolService{}
+
+	testCases := []struct {
+		name     string
+		path     string
+		fields   map[string]interface{}
+		extra    []string
+		priority []string
+		expected time.Time
+		tag      string
+	}{
+		{
+			name: "Samsung MP4",
+			path: "/videos/20210704_103000.mp4",
+			fields: map[string]interface{}{
+				"CreateDate":      "0000:00:00 00:00:00",
+				"MediaCreateDate": "2021:07:04 10:30:00",
+				"Make":            "samsung",
+			},
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC),
+			tag:      "MediaCreateDate",
+		},
+		{
+			name: "Pixel MP4",
+			path: "/videos/PXL_20210704_103000000.mp4",
+			fields: map[string]interface{}{
+				"AndroidVersion": "12",
+				"Date":           "2021-07-04T10:30:00.000+0200",
+			},
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.FixedZone("", 2*60*60)),
+			tag:      "Date",
+		},
+		{
+			name: "iPhone MOV",
+			path: "/videos/IMG_0001.MOV",
+			fields: map[string]interface{}{
+				"Make":         "Apple",
+				"CreateDate":   "2021:07:05 02:30:00",
+				"CreationDate": "2021:07:04 22:30:00-04:00",
+			},
+			expected: time.Date(2021, 7, 4, 22, 30, 0, 0, time.FixedZone("", -4*60*60)),
+			tag:      "CreationDate",
+		},
+		{
+			name: "Vendor fields ignored for images",
+			path: "/photos/IMG_0001.jpg",
+			fields: map[string]interface{}{
+				"Date": "2021-07-04T10:30:00Z",
+			},
+			tag: "",
+		},
+		{
+			name: "User-supplied extra tag",
+			path: "/videos/clip.mp4",
+			fields: map[string]interface{}{
+				"RecordedDate": "2020:02:03 04:05:06",
+			},
+			extra:    []string{"RecordedDate"},
+			expected: time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC),
+			tag:      "RecordedDate",
+		},
+		{
+			name: "Vendor field raised above CreateDate",
+			path: "/videos/20210704_103000.mp4",
+			fields: map[string]interface{}{
+				"CreateDate":      "2021:07:04 08:30:00",
+				"MediaCreateDate": "2021:07:04 10:30:00",
+			},
+			priority: []string{"MediaCreateDate"},
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC),
+			tag:      "MediaCreateDate",
+		},
+		{
+			name: "Extra tag raised above the built-in ones",
+			path: "/videos/clip.mp4",
+			fields: map[string]interface{}{
+				"DateTimeOriginal": "2021:07:04 10:30:00",
+				"RecordedDate":     "2020:02:03 04:05:06",
+			},
+			extra:    []string{"RecordedDate"},
+			priority: []string{"RecordedDate"},
+			expected: time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC),
+			tag:      "RecordedDate",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service.SetExtraDateTags(tc.extra)
+			service.SetDateTagPriority(tc.priority)
+			got, tag := DateFromFields(tc.fields, service.DateTags(tc.path, false), tc.path)
+			if tag != tc.tag || !got.Equal(tc.expected) {
+				t.Errorf("Expected %v from %q, but got %v from %q", tc.expected, tc.tag, got, tag)
+			}
+			// The date must keep its own offset, or it is filed on the wrong day.
+			if got.Day() != tc.expected.Day() {
+				t.Errorf("Expected day %d, but got %d (%v)", tc.expected.Day(), got.Day(), got)
+			}
+		})
+	}
+}
+
+func TestExtractDateIPhoneVideo(t *testing.T) {
+	// Metadata exiftool reports for a .MOV recorded on an iPhone at 22:30 in New York.
+	et := &countingExtractor{
+		fields: map[string]map[string]interface{}{
+			"/in/IMG_0420.MOV": {
+				"Make":              "Apple",
+				"Model":             "iPhone 12",
+				"CreateDate":        "2021:07:05 02:30:00",
+				"MediaCreateDate":   "2021:07:05 02:30:00",
+				"CreationDate":      "2021:07:04 22:30:00-04:00",
+				"MajorBrand":        "Apple QuickTime (.MOV/QT)",
+				"CompressorName":    "HEVC",
+				"FileModifyDate":    "2021:07:05 02:31:12+00:00",
+				"TrackCreateDate":   "2021:07:05 02:30:00",
+				"ContentIdentifier": "2B1C6E3A-3D21-4F0B-9E5A-8C1D2E3F4A5B",
+			},
+		},
+		calls: map[string]int{},
+	}
+	s := newExifToolService(et)
+
+	date, tag, err := s.ExtractDate("/in/IMG_0420.MOV", false, false)
+	if err != nil {
+		t.Fatalf("ExtractDate failed: %v", err)
+	}
+	if tag != "CreationDate" {
+		t.Errorf("Expected the date from CreationDate, but got %q", tag)
+	}
+	if date.Year() != 2021 || date.Month() != time.July || date.Day() != 4 || date.Hour() != 22 {
+		t.Errorf("Expected 2021-07-04 22:30 local time, but got %v", date)
+	}
+	if _, offset := date.Zone(); offset != -4*60*60 {
+		t.Errorf("Expected a -04:00 offset, but got %d seconds", offset)
+	}
+}
+
+func TestExtractDateUnparseable(t *testing.T) {
+	et := &countingExtractor{
+		fields: map[string]map[string]interface{}{
+			"/in/bad.jpg":      {"Make": "Oddcam", "DateTimeOriginal": "04/07/2021 10:30"},
+			"/in/zeroed.jpg":   {"DateTimeOriginal": "0000:00:00 00:00:00"},
+			"/in/missing.jpg":  {"Make": "Canon"},
+			"/in/fallback.jpg": {"DateTimeOriginal": "04/07/2021 10:30", "CreateDate": "2021:07:04 10:30:00"},
+		},
+		calls: map[string]int{},
+	}
+	s := newExifToolService(et)
+
+	_, _, err := s.ExtractDate("/in/bad.jpg", false, false)
+	var bad *UnparseableDateError
+	if !errors.As(err, &bad) || bad.Tag != "DateTimeOriginal" || bad.Value != "04/07/2021 10:30" {
+		t.Errorf("Expected an unparseable DateTimeOriginal, but got %v", err)
+	}
+
+	for _, path := range []string{"/in/zeroed.jpg", "/in/missing.jpg"} {
+		if date, _, err := s.ExtractDate(path, false, false); err != nil || !date.IsZero() {
+			t.Errorf("Expected no date and no error for %s, but got %v (%v)", path, date, err)
+		}
+	}
+
+	if date, tag, err := s.ExtractDate("/in/fallback.jpg", false, false); err != nil || tag != "CreateDate" || date.IsZero() {
+		t.Errorf("Expected the parseable CreateDate to be used, but got %v from %q (%v)", date, tag, err)
+	}
+}
+
+// contentExtractor stands in for exiftool and returns the fields registered for a file's content.
+type contentExtractor struct {
+	fields map[string]map[string]interface{}
+	paths  []string
+}
+
+func (e *contentExtractor) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	var infos []exiftool.FileMetadata
+	for _, file := range files {
+		e.paths = append(e.paths, file)
+		data, err := os.ReadFile(file)
+		infos = append(infos, exiftool.FileMetadata{File: file, Fields: e.fields[string(data)], Err: err})
+	}
+	return infos
+}
+
+func (e *contentExtractor) Close() error { return nil }
+
+func TestExtractDateFromReader(t *testing.T) {
+	fixture := []byte{0xff, 0xd8, 0xff, 0xe1, 'E', 'x', 'i', 'f', 0x00, 0x00, 0xff, 0xd9}
+	et := &contentExtractor{fields: map[string]map[string]interface{}{
+		string(fixture): {"DateTimeOriginal": "2021:07:04 10:30:00", "FileModifyDate": "2026:01:01 00:00:00+00:00"},
+	}}
+	s := newExifToolService(et)
+
+	date, tag, err := s.ExtractDateFromReader(bytes.NewReader(fixture), "DCIM/IMG_0001.JPG")
+	if err != nil {
+		t.Fatalf("ExtractDateFromReader failed: %v", err)
+	}
+	expected := time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC)
+	if !date.Equal(expected) || tag != "DateTimeOriginal" {
+		t.Errorf("Expected %v from DateTimeOriginal, but got %v from %q", expected, date, tag)
+	}
+
+	if len(et.paths) != 1 {
+		t.Fatalf("Expected exiftool to read one file, but got %v", et.paths)
+	}
+	if filepath.Ext(et.paths[0]) != ".JPG" {
+		t.Errorf("Expected the temp file to keep the .JPG extension, but got %s", et.paths[0])
+	}
+	if _, err := os.Stat(et.paths[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the temp file %s to be removed, but got %v", et.paths[0], err)
+	}
+
+	// The temp file's own modification date must never stand in for a missing date.
+	undated := []byte("no date")
+	et.fields[string(undated)] = map[string]interface{}{"FileModifyDate": "2026:01:01 00:00:00+00:00"}
+	if date, _, err := s.ExtractDateFromReader(bytes.NewReader(undated), "b.jpg"); err != nil || !date.IsZero() {
+		t.Errorf("Expected no date for an undated stream, but got %v (%v)", date, err)
+	}
+}
+
+func TestParseExifDateNaive(t *testing.T) {
+	tests := []struct {
+		dateStr string
+		naive   bool
+	}{
+		{"2021:07:04 10:30:00", true},
+		{"2021:07:04", true},
+		{"D:20210704103000", true},
+		{"2021:07:04 10:30:00+00:00", false},
+		{"2021:07:04 10:30:00-04:00", false},
+		{"2021-07-04T10:30:00Z", false},
+		{"2021-07-04T10:30:00.000Z", false},
+		{"20210704T103000.000Z", false},
+		{"D:20210704103000Z", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseExifDate(tt.dateStr)
+		if err != nil {
+			t.Fatalf("ParseExifDate(%q) failed: %v", tt.dateStr, err)
+		}
+		if IsNaive(got) != tt.naive {
+			t.Errorf("Expected IsNaive %v for %q, but got %v", tt.naive, tt.dateStr, IsNaive(got))
+		}
+	}
+}
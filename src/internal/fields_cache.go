@@ -0,0 +1,38 @@
+package internal
+
+import "github.com/barasher/go-exiftool"
+
+// DefaultFieldsCacheSize is the number of files whose metadata is kept by default.
+// It only needs to cover the files being processed at the same time.
+const DefaultFieldsCacheSize = 64
+
+// fieldsCache keeps the metadata of the most recently read files, evicting the oldest.
+// It is not safe for concurrent use; the service guards it with its mutex.
+type fieldsCache struct {
+	size    int
+	order   []string
+	entries map[string]exiftool.FileMetadata
+}
+
+func newFieldsCache(size int) *fieldsCache {
+	return &fieldsCache{size: size, entries: make(map[string]exiftool.FileMetadata)}
+}
+
+func (c *fieldsCache) get(path string) (exiftool.FileMetadata, bool) {
+	fi, ok := c.entries[path]
+	return fi, ok
+}
+
+func (c *fieldsCache) put(path string, fi exiftool.FileMetadata) {
+	if c.size <= 0 {
+		return
+	}
+	if _, ok := c.entries[path]; !ok {
+		if len(c.order) >= c.size {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, path)
+	}
+	c.entries[path] = fi
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/barasher/go-exiftool"
+)
+
+// countingExtractor stands in for exiftool and counts how often each file is read.
+type countingExtractor struct {
+	fields map[string]map[string]interface{}
+	calls  map[string]int
+}
+
+func (e *countingExtractor) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	var infos []exiftool.FileMetadata
+	for _, file := range files {
+		e.calls[file]++
+		infos = append(infos, exiftool.FileMetadata{File: file, Fields: e.fields[file]})
+	}
+	return infos
+}
+
+func (e *countingExtractor) Close() error { return nil }
+
+func TestExifToolInvokedOncePerFile(t *testing.T) {
+	et := &countingExtractor{
+		fields: map[string]map[string]interface{}{
+			"/in/a.jpg": {"DateTimeOriginal": "2021:07:04 10:00:00", "LensModel": "EF50mm", "Software": "Ver.1.0", "Orientation": "Rotate 90 CW"},
+			"/in/b.jpg": {"CreateDate": "2022:01:01 09:00:00", "Model": "iPhone 12"},
+		},
+		calls: map[string]int{},
+	}
+	s := newExifToolService(et)
+
+	for _, path := range []string{"/in/a.jpg", "/in/b.jpg"} {
+		if date, _, err := s.ExtractDate(path, false, false); err != nil || date.IsZero() {
+			t.Fatalf("Expected a date for %s, but got %v (%v)", path, date, err)
+		}
+		// Each enabled routing dimension (lens, software, orientation, ...) reads the fields again.
+		for i := 0; i < 4; i++ {
+			if _, err := s.ExtractFields(path); err != nil {
+				t.Fatalf("ExtractFields failed for %s: %v", path, err)
+			}
+		}
+		if et.calls[path] != 1 {
+			t.Errorf("Expected exiftool to be invoked once for %s, but got %d", path, et.calls[path])
+		}
+	}
+}
+
+func TestFieldsCacheEviction(t *testing.T) {
+	et := &countingExtractor{fields: map[string]map[string]interface{}{}, calls: map[string]int{}}
+	s := newExifToolService(et)
+	s.SetFieldsCacheSize(2)
+
+	for _, path := range []string{"a", "b", "c", "a"} {
+		s.ExtractFields(path)
+	}
+	if et.calls["a"] != 2 || et.calls["b"] != 1 || et.calls["c"] != 1 {
+		t.Errorf("Expected the oldest entry to be evicted, but got calls %v", et.calls)
+	}
+
+	s.SetFieldsCacheSize(0)
+	s.ExtractFields("b")
+	s.ExtractFields("b")
+	if et.calls["b"] != 3 {
+		t.Errorf("Expected no caching with size 0, but got %d calls for b", et.calls["b"])
+	}
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// filenameDatePattern matches a YYYYMMDD date, optionally separated by '-', '_' or '.',
+// followed by an optional HHMMSS time, as written by most cameras and phones
+// (e.g. IMG_20210704_103000.jpg, PXL_20210704_103000123.jpg, 2021-07-04 10.30.00.png).
+var filenameDatePattern = regexp.MustCompile(`(?:^|\D)((?:19|20)\d{2})[-_.]?(\d{2})[-_.]?(\d{2})(?:[-_. T]?(\d{2})[-_.:]?(\d{2})[-_.:]?(\d{2}))?`)
+
+// ParseFilenameDate extracts a date embedded in a file name.
+// It returns false if no valid date is found.
+func ParseFilenameDate(name string) (time.Time, bool) {
+	for _, m := range filenameDatePattern.FindAllStringSubmatch(name, -1) {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		var hour, minute, second int
+		if m[4] != "" {
+			hour, _ = strconv.Atoi(m[4])
+			minute, _ = strconv.Atoi(m[5])
+			second, _ = strconv.Atoi(m[6])
+		}
+
+		t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+		// Reject values time.Date normalized, such as month 13 or day 32.
+		if t.Month() != time.Month(month) || t.Day() != day || t.Hour() != hour || t.Minute() != minute || t.Second() != second {
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
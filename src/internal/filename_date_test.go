@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilenameDate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected time.Time
+		ok       bool
+	}{
+		{"IMG_20210704_103000.jpg", time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC), true},
+		{"PXL_20210704_103000123.jpg", time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC), true},
+		{"2021-07-04 10.30.00.png", time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC), true},
+		{"VID-20210704-WA0001.mp4", time.Date(2021, 7, 4, 0, 0, 0, 0, time.UTC), true},
+		{"scan_1999.12.31.tif", time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC), true},
+		{"IMG_20211304_103000.jpg", time.Time{}, false},
+		{"DSC01234.JPG", time.Time{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseFilenameDate(tc.name)
+			if ok != tc.ok {
+				t.Fatalf("Expected ok %v, but got %v", tc.ok, ok)
+			}
+			if ok && !got.Equal(tc.expected) {
+				t.Errorf("Expected date %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// HEIF files (HEIC, AVIF) are ISO base media files. The EXIF block is stored as an item
+// of type "Exif" inside the "meta" box: "iinf" names the item and "iloc" says where its bytes are.
+
+// maxHEIFMetaSize bounds how much of the meta box is read into memory.
+const maxHEIFMetaSize = 4 << 20
+
+// EXIF tags read by ReadHEIFDate.
+const (
+	tiffTagDateTime         = 0x0132
+	tiffTagExifIFD          = 0x8769
+	tiffTagDateTimeOriginal = 0x9003
+	tiffTagCreateDate       = 0x9004
+)
+
+// ReadHEIFDate reads the capture date from the EXIF block of a HEIC, HEIF or AVIF file
+// without calling exiftool. It returns the date and the exiftool name of the tag it came from.
+func ReadHEIFDate(path string) (time.Time, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	defer f.Close()
+
+	exif, err := readHEIFExif(f)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return dateFromTIFF(exif)
+}
+
+// heifBox is the header of one box.
+type heifBox struct {
+	typ        string
+	dataOffset int64
+	dataSize   int64
+}
+
+// readBoxHeader reads the box header at offset. end is the end of the enclosing box.
+func readBoxHeader(r io.ReaderAt, offset, end int64) (heifBox, error) {
+	var hdr [16]byte
+	if _, err := r.ReadAt(hdr[:8], offset); err != nil {
+		return heifBox{}, err
+	}
+	size := int64(binary.BigEndian.Uint32(hdr[:4]))
+	box := heifBox{typ: string(hdr[4:8]), dataOffset: offset + 8}
+	switch size {
+	case 0:
+		size = end - offset
+	case 1:
+		if _, err := r.ReadAt(hdr[8:16], offset+8); err != nil {
+			return heifBox{}, err
+		}
+		size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		box.dataOffset += 8
+	}
+	// A 64-bit size may not fit in an int64; comparing with the space left, rather than
+	// offset+size with end, cannot overflow.
+	if size < box.dataOffset-offset || size > end-offset {
+		return heifBox{}, fmt.Errorf("invalid size for %q box at offset %d", box.typ, offset)
+	}
+	box.dataSize = offset + size - box.dataOffset
+	return box, nil
+}
+
+// readHEIFExif returns the TIFF-formatted EXIF data of a HEIF file.
+func readHEIFExif(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var meta []byte
+	for offset := int64(0); offset < info.Size(); {
+		box, err := readBoxHeader(f, offset, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 && box.typ != "ftyp" {
+			return nil, errors.New("not an ISO base media file")
+		}
+		if box.typ == "meta" {
+			if box.dataSize > maxHEIFMetaSize {
+				return nil, fmt.Errorf("meta box too large (%d bytes)", box.dataSize)
+			}
+			meta = make([]byte, box.dataSize)
+			if _, err := f.ReadAt(meta, box.dataOffset); err != nil {
+				return nil, err
+			}
+			break
+		}
+		offset = box.dataOffset + box.dataSize
+	}
+	if len(meta) < 4 {
+		return nil, errors.New("no meta box")
+	}
+
+	// meta is a full box: skip version and flags.
+	children, err := childBoxes(meta[4:])
+	if err != nil {
+		return nil, err
+	}
+	id, err := exifItemID(children["iinf"])
+	if err != nil {
+		return nil, err
+	}
+	offset, length, err := itemLocation(children["iloc"], id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, offset); err != nil {
+		return nil, fmt.Errorf("reading EXIF item: %w", err)
+	}
+	// The item starts with the offset of the TIFF header, skipping an optional "Exif\0\0" prefix.
+	if len(data) < 4 {
+		return nil, errors.New("EXIF item too short")
+	}
+	start := 4 + int64(binary.BigEndian.Uint32(data[:4]))
+	if start >= int64(len(data)) {
+		return nil, errors.New("invalid EXIF header offset")
+	}
+	return data[start:], nil
+}
+
+// childBoxes splits data into boxes, keyed by type. Later duplicates are ignored.
+func childBoxes(data []byte) (map[string][]byte, error) {
+	r := bytes.NewReader(data)
+	boxes := make(map[string][]byte)
+	for offset := int64(0); offset < int64(len(data)); {
+		box, err := readBoxHeader(r, offset, int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := boxes[box.typ]; !ok {
+			boxes[box.typ] = data[box.dataOffset : box.dataOffset+box.dataSize]
+		}
+		offset = box.dataOffset + box.dataSize
+	}
+	return boxes, nil
+}
+
+// exifItemID finds the ID of the "Exif" item in an iinf box.
+func exifItemID(iinf []byte) (uint32, error) {
+	if len(iinf) < 6 {
+		return 0, errors.New("no iinf box")
+	}
+	header := 6
+	if iinf[0] != 0 {
+		header = 8
+	}
+	if len(iinf) < header {
+		return 0, errors.New("iinf box too short")
+	}
+
+	r := bytes.NewReader(iinf)
+	for offset := int64(header); offset < int64(len(iinf)); {
+		box, err := readBoxHeader(r, offset, int64(len(iinf)))
+		if err != nil {
+			return 0, err
+		}
+		offset = box.dataOffset + box.dataSize
+		infe := iinf[box.dataOffset : box.dataOffset+box.dataSize]
+		if box.typ != "infe" || len(infe) < 4 || infe[0] < 2 {
+			continue
+		}
+
+		// Version 2 has a 16-bit item ID, version 3 a 32-bit one; both are followed
+		// by a 16-bit protection index and the item type.
+		var id uint32
+		var typ []byte
+		if infe[0] == 2 && len(infe) >= 12 {
+			id = uint32(binary.BigEndian.Uint16(infe[4:6]))
+			typ = infe[8:12]
+		} else if infe[0] == 3 && len(infe) >= 14 {
+			id = binary.BigEndian.Uint32(infe[4:8])
+			typ = infe[10:14]
+		}
+		if string(typ) == "Exif" {
+			return id, nil
+		}
+	}
+	return 0, errors.New("no Exif item")
+}
+
+// itemLocation returns the file offset and length of item id from an iloc box.
+// Only items stored in the file itself (construction method 0) are supported.
+func itemLocation(iloc []byte, id uint32) (int64, int64, error) {
+	if len(iloc) < 8 {
+		return 0, 0, errors.New("no iloc box")
+	}
+	version := iloc[0]
+	offsetSize := int(iloc[4] >> 4)
+	lengthSize := int(iloc[4] & 0x0f)
+	baseOffsetSize := int(iloc[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(iloc[5] & 0x0f)
+	}
+
+	p := &byteParser{data: iloc, pos: 6}
+	var count uint64
+	if version < 2 {
+		count = p.uint(2)
+	} else {
+		count = p.uint(4)
+	}
+	for i := uint64(0); i < count && p.err == nil; i++ {
+		var itemID uint64
+		if version < 2 {
+			itemID = p.uint(2)
+		} else {
+			itemID = p.uint(4)
+		}
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = p.uint(2) & 0x0f
+		}
+		p.uint(2) // data reference index
+		base := p.uint(baseOffsetSize)
+		extents := p.uint(2)
+
+		var offset, length uint64
+		for e := uint64(0); e < extents; e++ {
+			p.uint(indexSize)
+			extentOffset := p.uint(offsetSize)
+			extentLength := p.uint(lengthSize)
+			if e == 0 {
+				offset, length = base+extentOffset, extentLength
+			}
+		}
+		if uint32(itemID) != id || p.err != nil {
+			continue
+		}
+		if method != 0 {
+			return 0, 0, fmt.Errorf("unsupported construction method %d for Exif item", method)
+		}
+		if extents == 0 || length == 0 || length > maxHEIFMetaSize {
+			return 0, 0, errors.New("invalid Exif item extent")
+		}
+		return int64(offset), int64(length), nil
+	}
+	if p.err != nil {
+		return 0, 0, p.err
+	}
+	return 0, 0, errors.New("no location for Exif item")
+}
+
+// byteParser reads big-endian integers of varying width, remembering the first error.
+type byteParser struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// uint reads an n-byte big-endian integer. A zero width reads nothing and returns 0.
+func (p *byteParser) uint(n int) uint64 {
+	if p.err != nil {
+		return 0
+	}
+	if n != 0 && n != 2 && n != 4 && n != 8 {
+		p.err = fmt.Errorf("unsupported field size %d", n)
+		return 0
+	}
+	if p.pos+n > len(p.data) {
+		p.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	var v uint64
+	for _, b := range p.data[p.pos : p.pos+n] {
+		v = v<<8 | uint64(b)
+	}
+	p.pos += n
+	return v
+}
+
+// dateFromTIFF reads DateTimeOriginal, then CreateDate from the EXIF IFD,
+// then DateTime (exiftool's ModifyDate) from IFD0.
+func dateFromTIFF(data []byte) (time.Time, string, error) {
+	if len(data) < 8 {
+		return time.Time{}, "", errors.New("TIFF header too short")
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, "", errors.New("invalid TIFF byte order")
+	}
+
+	ifd0 := readIFD(data, order, order.Uint32(data[4:8]))
+	if ptr, ok := ifd0[tiffTagExifIFD]; ok {
+		exif := readIFD(data, order, order.Uint32(ptr[8:12]))
+		for _, tag := range []struct {
+			id   uint16
+			name string
+		}{{tiffTagDateTimeOriginal, "DateTimeOriginal"}, {tiffTagCreateDate, "CreateDate"}} {
+			if t, ok := tiffDate(data, order, exif[tag.id]); ok {
+				return t, tag.name, nil
+			}
+		}
+	}
+	if t, ok := tiffDate(data, order, ifd0[tiffTagDateTime]); ok {
+		return t, "ModifyDate", nil
+	}
+	return time.Time{}, "", errors.New("no date in EXIF data")
+}
+
+// readIFD returns the raw 12-byte entries of the IFD at offset, keyed by tag.
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	entries := make(map[uint16][]byte)
+	if int64(offset)+2 > int64(len(data)) {
+		return entries
+	}
+	count := int(order.Uint16(data[offset:]))
+	for i := 0; i < count; i++ {
+		start := int64(offset) + 2 + int64(i)*12
+		if start+12 > int64(len(data)) {
+			break
+		}
+		entry := data[start : start+12]
+		entries[order.Uint16(entry)] = entry
+	}
+	return entries
+}
+
+// tiffDate parses an ASCII date entry.
+func tiffDate(data []byte, order binary.ByteOrder, entry []byte) (time.Time, bool) {
+	const typeASCII = 2
+	if entry == nil || order.Uint16(entry[2:4]) != typeASCII {
+		return time.Time{}, false
+	}
+	n := int64(order.Uint32(entry[4:8]))
+	var value []byte
+	if n <= 4 {
+		value = entry[8 : 8+n]
+	} else {
+		offset := int64(order.Uint32(entry[8:12]))
+		if offset+n > int64(len(data)) {
+			return time.Time{}, false
+		}
+		value = data[offset : offset+n]
+	}
+	t, err := ParseExifDate(strings.TrimRight(string(value), "\x00 "))
+	return t, err == nil && !t.IsZero()
+}
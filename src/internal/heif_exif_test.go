@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// box builds an ISO BMFF box.
+func box(typ string, payload ...[]byte) []byte {
+	var data []byte
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(out, typ...), data...)
+}
+
+// tiffWithDates builds a little-endian TIFF block with DateTime in IFD0 and
+// DateTimeOriginal in the EXIF IFD. Empty values are left out.
+func tiffWithDates(modify, original string) []byte {
+	le := binary.LittleEndian
+	data := []byte("II*\x00")
+	data = le.AppendUint32(data, 8)
+
+	// IFD0 at 8: DateTime and the EXIF IFD pointer. Values follow at 8+2+2*12+4 = 38.
+	exifIFD := uint32(38 + 20)
+	data = le.AppendUint16(data, 2)
+	data = append(data, tiffEntry(tiffTagDateTime, 2, uint32(len(modify)+1), 38)...)
+	data = append(data, tiffEntry(tiffTagExifIFD, 4, 1, exifIFD)...)
+	data = le.AppendUint32(data, 0)
+	data = append(data, padTo(modify+"\x00", 20)...)
+
+	// EXIF IFD: DateTimeOriginal, value after the IFD.
+	entries := 0
+	if original != "" {
+		entries = 1
+	}
+	valueOffset := exifIFD + 2 + uint32(entries)*12 + 4
+	data = le.AppendUint16(data, uint16(entries))
+	if original != "" {
+		data = append(data, tiffEntry(tiffTagDateTimeOriginal, 2, uint32(len(original)+1), valueOffset)...)
+	}
+	data = le.AppendUint32(data, 0)
+	return append(data, original+"\x00"...)
+}
+
+func tiffEntry(tag, typ uint16, count, value uint32) []byte {
+	le := binary.LittleEndian
+	entry := le.AppendUint16(nil, tag)
+	entry = le.AppendUint16(entry, typ)
+	entry = le.AppendUint32(entry, count)
+	return le.AppendUint32(entry, value)
+}
+
+func padTo(s string, n int) []byte {
+	b := make([]byte, n)
+	copy(b, s)
+	return b
+}
+
+// heicFixture builds a minimal HEIC file whose only item is an EXIF block.
+func heicFixture(exif []byte) []byte {
+	item := append([]byte{0, 0, 0, 6}, "Exif\x00\x00"...)
+	item = append(item, exif...)
+
+	ftyp := box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	infe := box("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif"), []byte("\x00"))
+	iinf := box("iinf", []byte{0, 0, 0, 0, 0, 1}, infe)
+	hdlr := box("hdlr", make([]byte, 8), []byte("pict"), make([]byte, 13))
+
+	// iloc version 0: 4-byte offsets and lengths, no base offset, one item with one extent.
+	iloc := func(offset uint32) []byte {
+		payload := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 1, 0, 1, 0, 0, 0, 1}
+		payload = binary.BigEndian.AppendUint32(payload, offset)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(item)))
+		return box("iloc", payload)
+	}
+	meta := func(offset uint32) []byte {
+		return box("meta", []byte{0, 0, 0, 0}, hdlr, iinf, iloc(offset))
+	}
+
+	// The item sits in mdat after ftyp and meta; the iloc size does not depend on the offset.
+	offset := uint32(len(ftyp) + len(meta(0)) + 8)
+	file := append(ftyp, meta(offset)...)
+	return append(file, box("mdat", item)...)
+}
+
+func TestReadHEIFDate(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		data     []byte
+		expected time.Time
+		tag      string
+		wantErr  bool
+	}{
+		{
+			name:     "IMG_0001.heic",
+			data:     heicFixture(tiffWithDates("2022:01:01 00:00:00", "2021:07:04 10:30:00")),
+			expected: time.Date(2021, 7, 4, 10, 30, 0, 0, time.UTC),
+			tag:      "DateTimeOriginal",
+		},
+		{
+			name:     "IMG_0002.heic",
+			data:     heicFixture(tiffWithDates("2022:01:01 08:15:00", "")),
+			expected: time.Date(2022, 1, 1, 8, 15, 0, 0, time.UTC),
+			tag:      "ModifyDate",
+		},
+		{
+			name:    "not_heif.heic",
+			data:    []byte("this is not an ISO base media file"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		got, tag, err := ReadHEIFDate(path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error for %s, but got date %v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %s, but got %v", tt.name, err)
+			continue
+		}
+		if !got.Equal(tt.expected) || tag != tt.tag {
+			t.Errorf("Expected %v from %s for %s, but got %v from %s", tt.expected, tt.tag, tt.name, got, tag)
+		}
+	}
+}
+
+func TestReadHEIFDateFixture(t *testing.T) {
+	// An iPhone-style HEIC: two image tiles and an Exif item located through an
+	// iloc version 1 box with a base offset, and big-endian EXIF data.
+	got, tag, err := ReadHEIFDate(filepath.Join("testdata", "IMG_4521.HEIC"))
+	if err != nil {
+		t.Fatalf("ReadHEIFDate failed: %v", err)
+	}
+	expected := time.Date(2021, 7, 4, 18, 2, 10, 0, time.UTC)
+	if !got.Equal(expected) || tag != "DateTimeOriginal" {
+		t.Errorf("Expected %v from DateTimeOriginal, but got %v from %s", expected, got, tag)
+	}
+}
+
+func TestReadHEIFDateHugeBoxSize(t *testing.T) {
+	// A meta child with a 64-bit size near the int64 maximum, after a free box, used to
+	// overflow the bounds check and panic when slicing the box.
+	bad := []byte{0, 0, 0, 1, 'i', 'i', 'n', 'f', 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	data := append(box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic")),
+		box("meta", []byte{0, 0, 0, 0}, box("free"), bad)...)
+	path := filepath.Join(t.TempDir(), "corrupt.heic")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	if got, _, err := ReadHEIFDate(path); err == nil {
+		t.Errorf("Expected an error for a corrupt box size, but got date %v", got)
+	}
+
+	// A 64-bit size with the top bit set is negative as an int64.
+	negative := append([]byte(nil), bad...)
+	negative[8] = 0xff
+	if _, err := childBoxes(append(box("free"), negative...)); err == nil {
+		t.Errorf("Expected an error for a negative box size, but got nil")
+	}
+}
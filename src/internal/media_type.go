@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Media types returned by ClassifyMedia.
+const (
+	MediaImage    = "image"
+	MediaVideo    = "video"
+	MediaAudio    = "audio"
+	MediaDocument = "document"
+	MediaOther    = "other"
+)
+
+// mediaExtensions maps lower-case file extensions to their media type.
+var mediaExtensions = map[string]string{
+	".jpg": MediaImage, ".jpeg": MediaImage, ".png": MediaImage, ".gif": MediaImage,
+	".heic": MediaImage, ".heif": MediaImage, ".avif": MediaImage, ".webp": MediaImage,
+	".tif": MediaImage, ".tiff": MediaImage, ".bmp": MediaImage, ".dng": MediaImage,
+	".cr2": MediaImage, ".cr3": MediaImage, ".nef": MediaImage, ".arw": MediaImage,
+	".orf": MediaImage, ".raf": MediaImage, ".rw2": MediaImage,
+
+	".mp4": MediaVideo, ".mov": MediaVideo, ".m4v": MediaVideo, ".avi": MediaVideo,
+	".mts": MediaVideo, ".m2ts": MediaVideo, ".mkv": MediaVideo, ".3gp": MediaVideo,
+	".wmv": MediaVideo, ".mpg": MediaVideo, ".mpeg": MediaVideo,
+
+	".mp3": MediaAudio, ".m4a": MediaAudio, ".aac": MediaAudio, ".flac": MediaAudio,
+	".wav": MediaAudio, ".ogg": MediaAudio, ".opus": MediaAudio, ".wma": MediaAudio,
+	".aiff": MediaAudio,
+
+	".pdf": MediaDocument,
+}
+
+// ClassifyMedia returns the media type of a file based on its extension.
+func ClassifyMedia(path string) string {
+	if mediaType, ok := mediaExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return mediaType
+	}
+	return MediaOther
+}
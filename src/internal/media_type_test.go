@@ -0,0 +1,24 @@
+package internal
+
+import "testing"
+
+func TestClassifyMedia(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/photos/IMG_0001.JPG", MediaImage},
+		{"/photos/IMG_0001.heic", MediaImage},
+		{"/videos/clip.MOV", MediaVideo},
+		{"/music/song.mp3", MediaAudio},
+		{"/scans/letter.pdf", MediaDocument},
+		{"/misc/notes.txt", MediaOther},
+		{"/misc/noext", MediaOther},
+	}
+
+	for _, tc := range testCases {
+		if got := ClassifyMedia(tc.path); got != tc.expected {
+			t.Errorf("Expected media type %v for %s, but got %v", tc.expected, tc.path, got)
+		}
+	}
+}